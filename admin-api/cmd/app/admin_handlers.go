@@ -0,0 +1,158 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/admin-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandlers let admins manage the role and status of users, every change needs a reason code,
+// search the audit log and decide on erasure requests.
+type AdminHandlers struct {
+	service service.UserService
+	audit   service.AuditService
+	erasure service.ErasureService
+}
+
+// ChangeRoleHandler gives a specific user by ID a new role.
+func (ah AdminHandlers) ChangeRoleHandler(c *gin.Context) {
+	var req domain.ChangeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, apiErr := ah.service.ChangeRole(c.Request.Context(), adminFromContext(c), c.Param("user_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// ActivateUserHandler activates an inactive user by ID.
+func (ah AdminHandlers) ActivateUserHandler(c *gin.Context) {
+	var req domain.ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, apiErr := ah.service.Activate(c.Request.Context(), adminFromContext(c), c.Param("user_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// DeactivateUserHandler deactivates an active user by ID.
+func (ah AdminHandlers) DeactivateUserHandler(c *gin.Context) {
+	var req domain.ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, apiErr := ah.service.Deactivate(c.Request.Context(), adminFromContext(c), c.Param("user_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// FindInactiveUsersHandler lists inactive users a page at a time, with the limit and cursor query parameters.
+func (ah AdminHandlers) FindInactiveUsersHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+		return
+	}
+
+	page, apiErr := ah.service.FindInactive(c.Request.Context(), c.Query("cursor"), limit)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// FindAuditLogHandler searches the audit log, filtered by the actor, target, action, from and to query parameters,
+// a page at a time with the limit and cursor query parameters.
+func (ah AdminHandlers) FindAuditLogHandler(c *gin.Context) {
+	var req domain.FindAuditLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, apiErr := ah.audit.FindAuditLog(c.Request.Context(), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// FindErasureRequestsHandler lists erasure requests, filtered by the status query parameter,
+// a page at a time with the limit and cursor query parameters.
+func (ah AdminHandlers) FindErasureRequestsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+		return
+	}
+
+	page, apiErr := ah.erasure.FindRequests(c.Request.Context(), c.Query("status"), c.Query("cursor"), limit)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ApproveErasureHandler approves a pending erasure request by ID, the note is optional.
+func (ah AdminHandlers) ApproveErasureHandler(c *gin.Context) {
+	var req domain.ErasureDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	er, apiErr := ah.erasure.Approve(c.Request.Context(), adminFromContext(c), c.Param("request_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"erasureRequest": er})
+}
+
+// RejectErasureHandler rejects a pending erasure request by ID, with a note telling why.
+func (ah AdminHandlers) RejectErasureHandler(c *gin.Context) {
+	var req domain.ErasureDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	er, apiErr := ah.erasure.Reject(c.Request.Context(), adminFromContext(c), c.Param("request_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"erasureRequest": er})
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/admin-api/service"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/ashtishad/instabid-wallet/lib/revocation"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
+	"github.com/gin-gonic/gin"
+)
+
+func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
+	if os.Getenv("GIN_MODE") != "" {
+		gin.SetMode(os.Getenv("GIN_MODE"))
+	}
+
+	var r = gin.New()
+	srv.Handler = r
+
+	// client IPs are taken from X-Forwarded-For only behind the configured proxies
+	if err := r.SetTrustedProxies(lib.TrustedProxies()); err != nil {
+		l.Error("invalid TRUSTED_PROXIES", "err", err.Error())
+		os.Exit(1)
+	}
+
+	// wire up the handler
+	erasureService := service.NewErasureService(domain.NewErasureRepoDB(dbClient, l), l)
+	ah := AdminHandlers{
+		service: service.NewUserService(domain.NewUserRepoDB(dbClient, l), l),
+		audit:   service.NewAuditService(audit.NewStoreDB(dbClient, l), l),
+		erasure: erasureService,
+	}
+
+	// approved erasure requests are carried out once their cooling-off period ends
+	erasureService.StartErasure(context.Background(), domain.ErasureInterval)
+
+	// role permissions are loaded from the database and kept in sync
+	permissions := policy.NewRolePermissions(dbClient, l)
+	if apiErr := permissions.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load role permissions", "err", apiErr.WithCauses())
+	}
+
+	permissions.StartSync(context.Background(), policy.DefaultSyncInterval)
+
+	// revoked tokens are rejected, the revocation list is loaded from the database and kept in sync
+	revocations := revocation.NewStore(dbClient, l)
+	if apiErr := revocations.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load token revocation list", "err", apiErr.WithCauses())
+	}
+
+	revocations.StartSync(context.Background(), revocation.DefaultSyncInterval)
+	jwtutils.UseRevocationList(revocations)
+
+	// tokens are verified locally, remote verification by auth-api only as a configured fallback
+	remoteFallback, _ := strconv.ParseBool(os.Getenv("VERIFY_REMOTE_FALLBACK"))
+	v := verifier.New(permissions, verifier.Config{
+		CacheTTL:       verifier.DefaultCacheTTL,
+		RemoteFallback: remoteFallback,
+	})
+
+	// route url mappings
+	setAdminAPIRoutes(r, ah, v, l)
+
+	// start server
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Error("could not start server: %v\n", "err", err.Error(), "srv", srv.Addr)
+		}
+	}()
+}
+
+func setAdminAPIRoutes(r *gin.Engine, ah AdminHandlers, v *verifier.Verifier, l *slog.Logger) {
+	userRoutes := r.Group("/users")
+	userRoutes.Use(requireAdmin(v, l))
+	{
+		userRoutes.GET("/inactive", ah.FindInactiveUsersHandler)
+		userRoutes.PUT("/:user_id/role", ah.ChangeRoleHandler)
+		userRoutes.PUT("/:user_id/activate", ah.ActivateUserHandler)
+		userRoutes.PUT("/:user_id/deactivate", ah.DeactivateUserHandler)
+	}
+
+	r.GET("/audit", requireAdmin(v, l), ah.FindAuditLogHandler)
+	r.GET("/debug/vars", requireAdmin(v, l), gin.WrapH(expvar.Handler()))
+
+	erasureRoutes := r.Group("/erasure-requests")
+	erasureRoutes.Use(requireAdmin(v, l))
+	{
+		erasureRoutes.GET("", ah.FindErasureRequestsHandler)
+		erasureRoutes.PUT("/:request_id/approve", ah.ApproveErasureHandler)
+		erasureRoutes.PUT("/:request_id/reject", ah.RejectErasureHandler)
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AuthHeader = "Authorization"
+	Bearer     = "Bearer"
+
+	adminKey = "admin"
+)
+
+var ErrBearerTokenNotFound = errors.New("bearer token not found in auth header")
+
+// requireAdmin is a Gin middleware that authorizes requests with the bearer access token of an admin
+// for the route, the admin is set in the Gin context for the changes made to be attributed to.
+// Service client tokens and API keys are rejected, every change must be made by a person.
+// Otherwise, it responds with a 401 Unauthorized or 403 Forbidden status and aborts the request.
+func requireAdmin(v *verifier.Verifier, l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var scheme, tokenStr string
+		if _, err := fmt.Sscanf(c.GetHeader(AuthHeader), "%s %s", &scheme, &tokenStr); err != nil || scheme != Bearer {
+			l.Info("unable to extract token", "err", ErrBearerTokenNotFound.Error())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+
+			return
+		}
+
+		routeName := fmt.Sprintf("%s:%s", c.Request.Method, c.FullPath())
+
+		claims, err := v.Verify(tokenStr, routeName, "")
+		if err != nil {
+			if errors.Is(err, verifier.ErrForbidden) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+
+			return
+		}
+
+		userID, _ := claims["UserID"].(string)
+		role, _ := claims["Role"].(string)
+
+		if userID == "" || role != domain.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		c.Set(adminKey, domain.Admin{UserID: userID, IP: c.ClientIP()})
+		c.Next()
+	}
+}
+
+// adminFromContext returns the admin set by requireAdmin.
+func adminFromContext(c *gin.Context) domain.Admin {
+	admin, _ := c.MustGet(adminKey).(domain.Admin)
+	return admin
+}
@@ -0,0 +1,81 @@
+// Command audit-verify verifies the hash chain of the audit log and prints a JSON report of it,
+// with the entries that were changed or follow removed entries. It exits with status 1 if the chain is broken,
+// or the head given with -head, kept from an earlier run, is no longer part of it.
+//
+//	go run ./admin-api/cmd/audit-verify -head <hash>
+//
+// It connects to the database of the DB_* environment variables.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/ashtishad/instabid-wallet/db/conn"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+type report struct {
+	*audit.Report
+	HeadFound *bool `json:"headFound,omitempty"`
+}
+
+func main() {
+	head := flag.String("head", "", "hash of the chain head from an earlier verification, which must still be in it")
+	flag.Parse()
+
+	// the report is written to stdout, logs go to stderr
+	l := slog.New(slog.NewTextHandler(os.Stderr, lib.GetSlogConf()))
+
+	os.Exit(run(l, *head))
+}
+
+// run verifies the chain and writes the report, it returns the exit status.
+func run(l *slog.Logger, head string) int {
+	ctx := context.Background()
+
+	dbClient := conn.GetDBClient(l)
+	defer func() {
+		if err := dbClient.Close(); err != nil {
+			l.Error("unable to close db", "err", err.Error())
+		}
+	}()
+
+	store := audit.NewStoreDB(dbClient, l)
+
+	r, apiErr := store.Verify(ctx)
+	if apiErr != nil {
+		l.Error("unable to verify the audit log", "err", apiErr.WithCauses())
+		return 2
+	}
+
+	res := report{Report: r}
+
+	if head != "" {
+		found, containsErr := store.Contains(ctx, head)
+		if containsErr != nil {
+			l.Error("unable to find the head in the audit log", "err", containsErr.WithCauses())
+			return 2
+		}
+
+		res.HeadFound = &found
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(res); err != nil {
+		l.Error("unable to write the report", "err", err.Error())
+		return 2
+	}
+
+	if !r.OK() || (res.HeadFound != nil && !*res.HeadFound) {
+		return 1
+	}
+
+	return 0
+}
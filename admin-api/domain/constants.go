@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleMerchant  = "merchant"
+	RoleUser      = "user"
+
+	StatusActive   = "active"
+	StatusInactive = "inactive"
+	StatusDeleted  = "deleted"
+
+	ActionChangeRole = "change_role"
+	ActionActivate   = "activate"
+	ActionDeactivate = "deactivate"
+
+	ReasonOther = "other"
+
+	ErasurePending   = "pending"
+	ErasureApproved  = "approved"
+	ErasureRejected  = "rejected"
+	ErasureCancelled = "cancelled"
+	ErasureCompleted = "completed"
+
+	// ErasureCoolingOff is how long after approval personal data is erased, the user can still cancel until then.
+	ErasureCoolingOff = 30 * 24 * time.Hour
+	// ErasureInterval is how often due erasure requests are carried out.
+	ErasureInterval = 10 * time.Minute
+	// ErasureRetryAfter is how long after a failed erasure it's attempted again.
+	ErasureRetryAfter = time.Hour
+
+	MaxNoteLen      = 512
+	MaxAuditIDLen   = 128
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ReasonCodes are the reasons admins may give for changing a user, with what they mean.
+// A note is required with ReasonOther.
+var ReasonCodes = map[string]string{
+	"user_request":      "the user asked for the change",
+	"kyc_verified":      "the user's identity was verified",
+	"kyc_failed":        "the user's identity couldn't be verified",
+	"fraud_suspected":   "the account shows signs of fraud",
+	"policy_violation":  "the user broke the terms of service",
+	"dormant_account":   "the account wasn't used for a long time",
+	"staff_change":      "the user joined, left or moved within the staff",
+	"merchant_approved": "the user was approved to sell",
+	"merchant_revoked":  "the user may no longer sell",
+	"issue_resolved":    "the reason of an earlier change no longer applies",
+	ReasonOther:         "explained in the note",
+}
+
+// Roles are the roles users can be given.
+var Roles = []string{RoleAdmin, RoleModerator, RoleMerchant, RoleUser}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// ErasureRequest is a request to erase the personal data of a user, requested in the user-api.
+type ErasureRequest struct {
+	RequestID      string     `json:"requestId"`
+	UserID         string     `json:"userId"`
+	RequestedBy    *string    `json:"requestedBy"`
+	ImpersonatedBy *string    `json:"impersonatedBy,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	Status         string     `json:"status"`
+	DecidedBy      *string    `json:"decidedBy,omitempty"`
+	DecidedAt      *time.Time `json:"decidedAt,omitempty"`
+	DecisionNote   string     `json:"decisionNote,omitempty"`
+	EraseAfter     *time.Time `json:"eraseAfter,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	// EraseFailures counts the failed attempts to carry out an approved request, LastError tells why the last failed.
+	EraseFailures int        `json:"eraseFailures,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	FailedAt      *time.Time `json:"failedAt,omitempty"`
+}
+
+// ErasureRun reports a run of carrying out the due erasure requests, how many were erased and how many failed.
+type ErasureRun struct {
+	Erased int
+	Failed int
+}
+
+// Decision is an admin's approval or rejection of a pending erasure request, Status is the new status.
+type Decision struct {
+	AdminID    string
+	IP         string
+	RequestID  string
+	Status     string
+	Note       string
+	EraseAfter time.Time
+}
+
+// ErasureDecisionRequest is the note of approving or rejecting an erasure request, required to reject it.
+type ErasureDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+// ErasureRequestsPage is a page of erasure requests, the next page starts after NextCursor if it's set.
+type ErasureRequestsPage struct {
+	Requests   []ErasureRequest `json:"requests"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
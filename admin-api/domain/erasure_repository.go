@@ -0,0 +1,308 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+const sqlErasureRequestColumns = `request_id, user_id, requested_by, impersonated_by, reason, status, decided_by,
+								  decided_at, decision_note, erase_after, completed_at, created_at,
+								  erase_failures, last_error, failed_at`
+
+type ErasureRepository interface {
+	FindRequests(ctx context.Context, status string, afterRequestID string, limit int) ([]ErasureRequest,
+		lib.APIError)
+	FindRequest(ctx context.Context, requestID string) (*ErasureRequest, lib.APIError)
+	Decide(ctx context.Context, d Decision) (*ErasureRequest, lib.APIError)
+	EraseDue(ctx context.Context) (ErasureRun, lib.APIError)
+}
+
+type ErasureRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewErasureRepoDB(db *sql.DB, l *slog.Logger) *ErasureRepoDB {
+	return &ErasureRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// FindRequests returns up to limit+1 erasure requests with the status, every status if it's empty,
+// oldest first after the request with afterRequestID, so the caller can tell whether there's a next page.
+func (d *ErasureRepoDB) FindRequests(ctx context.Context, status string, afterRequestID string,
+	limit int) ([]ErasureRequest, lib.APIError) {
+	sqlFind := `SELECT ` + sqlErasureRequestColumns + ` FROM erasure_requests
+				WHERE ($1 = '' OR status = nullif($1, '')::erasure_status)
+				  AND ($2 = '' OR (created_at, request_id) >
+					  (SELECT created_at, request_id FROM erasure_requests WHERE request_id = nullif($2, '')::uuid))
+				ORDER BY created_at, request_id LIMIT $3`
+
+	rows, err := d.db.QueryContext(ctx, sqlFind, status, afterRequestID, limit+1)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query erasure requests", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	requests := make([]ErasureRequest, 0, limit+1)
+
+	for rows.Next() {
+		var er ErasureRequest
+		if er, err = scanErasureRequest(rows); err != nil {
+			d.l.ErrorContext(ctx, "unable to scan erasure request", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		requests = append(requests, er)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, "unable to iterate erasure requests", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return requests, nil
+}
+
+// FindRequest returns an erasure request by id, returns 404 if there's none.
+func (d *ErasureRepoDB) FindRequest(ctx context.Context, requestID string) (*ErasureRequest, lib.APIError) {
+	sqlFind := `SELECT ` + sqlErasureRequestColumns + ` FROM erasure_requests WHERE request_id = $1`
+
+	er, err := scanErasureRequest(d.db.QueryRowContext(ctx, sqlFind, requestID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("erasure request not found by id")
+		}
+
+		d.l.ErrorContext(ctx, "unable to find erasure request", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &er, nil
+}
+
+// Decide approves or rejects a pending erasure request and records the decision in the audit log,
+// returns 409 if the request isn't pending anymore, it was decided or cancelled meanwhile.
+func (d *ErasureRepoDB) Decide(ctx context.Context, decision Decision) (*ErasureRequest, lib.APIError) {
+	sqlDecide := `UPDATE erasure_requests
+				  SET status = $2::erasure_status, decided_by = $3, decided_at = now(), decision_note = $4,
+					  erase_after = CASE WHEN $2 = 'approved' THEN $5::timestamptz END
+				  WHERE request_id = $1 AND status = 'pending'
+				  RETURNING ` + sqlErasureRequestColumns
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var er ErasureRequest
+
+	er, err = scanErasureRequest(tx.QueryRowContext(ctx, sqlDecide, decision.RequestID, decision.Status,
+		decision.AdminID, decision.Note, decision.EraseAfter))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.ConflictError("erasure request is no longer pending")
+		}
+
+		d.l.ErrorContext(ctx, "unable to decide erasure request", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	diff := audit.Diff{"requestId": {After: er.RequestID}, "status": {Before: ErasurePending, After: er.Status}}
+	if er.EraseAfter != nil {
+		diff.Add("eraseAfter", nil, er.EraseAfter.UTC().Format(time.RFC3339))
+	}
+
+	if _, err = audit.AppendTx(ctx, tx, audit.Entry{Actor: decision.AdminID, Target: er.UserID,
+		Action: audit.ActionErasureDecide, Diff: diff, IP: decision.IP}); err != nil {
+		d.l.ErrorContext(ctx, "unable to append audit log entry", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &er, nil
+}
+
+// EraseDue erases the personal data of users whose approved erasure requests are due, one request per transaction,
+// and reports how many it erased and how many failed. A failed request is recorded with its error and skipped
+// for ErasureRetryAfter, so it doesn't hold up the requests due after it. Requests taken by another instance
+// are skipped. It stops with an error if the next request can't be found or a failure can't be recorded.
+func (d *ErasureRepoDB) EraseDue(ctx context.Context) (ErasureRun, lib.APIError) {
+	var run ErasureRun
+
+	for {
+		requestID, err := d.eraseNext(ctx)
+		if requestID == "" {
+			if err != nil {
+				d.l.ErrorContext(ctx, "unable to find due erasure request", "err", err.Error())
+				return run, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+			}
+
+			return run, nil
+		}
+
+		if err == nil {
+			run.Erased++
+			continue
+		}
+
+		d.l.ErrorContext(ctx, "unable to erase user", "requestId", requestID, "err", err.Error())
+		run.Failed++
+
+		if err = d.recordFailure(ctx, requestID, err); err != nil {
+			d.l.ErrorContext(ctx, "unable to record erasure failure", "requestId", requestID, "err", err.Error())
+			return run, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+	}
+}
+
+// eraseNext erases the user of the next due erasure request and returns the id of the request,
+// with the error if erasing failed, the id is empty if no request is due or it couldn't be found.
+func (d *ErasureRepoDB) eraseNext(ctx context.Context) (requestID string, err error) {
+	sqlNextDue := `SELECT request_id, user_id, decided_by FROM erasure_requests
+				   WHERE status = 'approved' AND erase_after <= now()
+				     AND (failed_at IS NULL OR failed_at < $1)
+				   ORDER BY erase_after LIMIT 1 FOR UPDATE SKIP LOCKED`
+	sqlComplete := `UPDATE erasure_requests SET status = 'completed', completed_at = now() WHERE request_id = $1`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return "", err
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var userID string
+	var decidedBy sql.NullString
+
+	retryBefore := time.Now().Add(-ErasureRetryAfter)
+	if err = tx.QueryRowContext(ctx, sqlNextDue, retryBefore).Scan(&requestID, &userID, &decidedBy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if err = pseudonymise(ctx, tx, userID); err != nil {
+		return requestID, err
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlComplete, requestID); err != nil {
+		return requestID, err
+	}
+
+	// the erasure is attributed to the admin who approved it
+	if _, err = audit.AppendTx(ctx, tx, audit.Entry{Actor: decidedBy.String, Target: userID,
+		Action: audit.ActionUserErase, Diff: audit.Diff{"requestId": {After: requestID}}}); err != nil {
+		return requestID, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return requestID, err
+	}
+
+	d.l.InfoContext(ctx, "user erased", "userId", userID, "requestId", requestID)
+
+	return requestID, nil
+}
+
+// recordFailure counts a failed attempt to carry out an erasure request with its error,
+// once the transaction of the attempt was rolled back.
+func (d *ErasureRepoDB) recordFailure(ctx context.Context, requestID string, cause error) error {
+	sqlFailure := `UPDATE erasure_requests SET erase_failures = erase_failures + 1, last_error = $2, failed_at = now()
+				   WHERE request_id = $1`
+
+	_, err := d.db.ExecContext(ctx, sqlFailure, requestID, cause.Error())
+
+	return err
+}
+
+// pseudonymise replaces the personal data of a user with pseudonyms in place, the user keeps its row and id,
+// so the records referencing it stay intact, and the user is deleted and logged out everywhere.
+// Device details of its sessions and auth events are cleared, its secrets and credentials are removed.
+// The audit log is append-only and kept as the record of what happened to the account, only the personal data
+// of the entries about the user, kept outside the hash chain, is cleared.
+func pseudonymise(ctx context.Context, tx *sql.Tx, userID string) error {
+	sqlLockUser := `SELECT username, email FROM users WHERE user_id = $1 FOR UPDATE`
+	sqlUser := `UPDATE users SET username = 'erased-' || replace(user_id::text, '-', ''),
+				email = 'erased-' || replace(user_id::text, '-', '') || '@erased.invalid',
+				status = 'deleted', hashed_pass = '', email_verified_at = NULL, erased_at = now()
+				WHERE user_id = $1`
+	sqlAuthEvents := `UPDATE auth_events SET identifier = '', ip = '', user_agent = ''
+					  WHERE user_id = $1 OR (user_id IS NULL AND lower(identifier) IN (lower($2), lower($3)))`
+	sqlLoginFailures := `DELETE FROM login_failures
+						 WHERE key IN ('account:username:' || lower($2), 'account:email:' || lower($3))`
+	sqlRevokeAccessTokens := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, now())
+							  ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`
+
+	// statements taking the user id only
+	sqlByUserID := []string{
+		`UPDATE user_profiles SET first_name = 'Erased', last_name = 'User', address = NULL
+		 WHERE user_id = (SELECT id FROM users WHERE user_id = $1)`,
+		`UPDATE auth_sessions SET ip = '', user_agent = '', revoked_at = coalesce(revoked_at, now())
+		 WHERE user_id = $1`,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`,
+		`UPDATE api_keys SET allowed_ips = '', revoked_at = coalesce(revoked_at, now()) WHERE user_id = $1`,
+		`DELETE FROM user_mfa WHERE user_id = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+		`DELETE FROM password_history WHERE user_id = $1`,
+		`DELETE FROM one_time_tokens WHERE user_id = $1`,
+		`DELETE FROM login_failures WHERE key = 'mfa:' || $1::text`,
+		`UPDATE audit_log SET personal = NULL WHERE (actor = $1::text OR target = $1::text) AND personal IS NOT NULL`,
+		`DELETE FROM idempotency_keys WHERE scope = 'user:' || $1::text`,
+		sqlRevokeAccessTokens,
+	}
+
+	var userName, email string
+	if err := tx.QueryRowContext(ctx, sqlLockUser, userID).Scan(&userName, &email); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlUser, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlAuthEvents, userID, userName, email); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlLoginFailures, userName, email); err != nil {
+		return err
+	}
+
+	for _, query := range sqlByUserID {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanErasureRequest scans the sqlErasureRequestColumns of a row.
+func scanErasureRequest(row interface{ Scan(dest ...any) error }) (ErasureRequest, error) {
+	var er ErasureRequest
+	err := row.Scan(&er.RequestID, &er.UserID, &er.RequestedBy, &er.ImpersonatedBy, &er.Reason, &er.Status,
+		&er.DecidedBy, &er.DecidedAt, &er.DecisionNote, &er.EraseAfter, &er.CompletedAt, &er.CreatedAt,
+		&er.EraseFailures, &er.LastError, &er.FailedAt)
+
+	return er, err
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+// User is a user as admins manage it.
+type User struct {
+	UserID    string    `json:"userId"`
+	UserName  string    `json:"userName"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Admin is the admin making a change, from the access token and request.
+type Admin struct {
+	UserID string
+	IP     string
+}
+
+// Change is a change of the role or status of a user by an admin, recorded in the audit log.
+// For role changes To is the new role, for activation and deactivation the new status.
+type Change struct {
+	AdminID    string
+	UserID     string
+	Action     string
+	To         string
+	ReasonCode string
+	Note       string
+	IP         string
+}
+
+type ChangeRoleRequest struct {
+	Role       string `json:"role" binding:"required"`
+	ReasonCode string `json:"reasonCode" binding:"required"`
+	Note       string `json:"note"`
+}
+
+// ChangeStatusRequest is the reason of activating or deactivating a user.
+type ChangeStatusRequest struct {
+	ReasonCode string `json:"reasonCode" binding:"required"`
+	Note       string `json:"note"`
+}
+
+// InactiveUsersPage is a page of inactive users, the next page starts after NextCursor if it's set.
+type InactiveUsersPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// FindAuditLogRequest filters the audit log by actor, target and action, and by creation time in [from, to),
+// times in RFC 3339. Entries are listed newest first, the next page starts after cursor.
+type FindAuditLogRequest struct {
+	Actor  string `form:"actor"`
+	Target string `form:"target"`
+	Action string `form:"action"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// AuditLogPage is a page of audit log entries, the next page starts after NextCursor if it's set.
+type AuditLogPage struct {
+	Entries    []audit.Entry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+type UserRepository interface {
+	ChangeRole(ctx context.Context, change Change) (*User, lib.APIError)
+	ChangeStatus(ctx context.Context, change Change, from string) (*User, lib.APIError)
+	FindInactive(ctx context.Context, afterUserID string, limit int) ([]User, lib.APIError)
+}
+
+type UserRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewUserRepoDB(db *sql.DB, l *slog.Logger) *UserRepoDB {
+	return &UserRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// ChangeRole gives a user a new role and records the change in the audit log. Access tokens issued until now
+// carry the old role, they are revoked, refreshing them issues tokens with the new role.
+// Returns 404 for unknown or deleted users and 409 if the user already has the role.
+func (d *UserRepoDB) ChangeRole(ctx context.Context, change Change) (*User, lib.APIError) {
+	sqlUpdateRole := `UPDATE users SET role = $2 WHERE user_id = $1 RETURNING updated_at`
+	sqlRevokeAccessTokens := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, now())
+							  ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`
+
+	return d.change(ctx, change, func(tx *sql.Tx, u *User) (string, error) {
+		if u.Role == change.To {
+			return "", errConflict(fmt.Sprintf("user already has role %s", change.To))
+		}
+
+		if err := tx.QueryRowContext(ctx, sqlUpdateRole, change.UserID, change.To).Scan(&u.UpdatedAt); err != nil {
+			return "", err
+		}
+
+		if _, err := tx.ExecContext(ctx, sqlRevokeAccessTokens, change.UserID); err != nil {
+			return "", err
+		}
+
+		old := u.Role
+		u.Role = change.To
+
+		return old, nil
+	})
+}
+
+// ChangeStatus moves a user from status from to change.To and records the change in the audit log.
+// Deactivated users are logged out everywhere, their refresh and access tokens are revoked.
+// Returns 404 for unknown or deleted users and 409 if the user's status isn't from.
+func (d *UserRepoDB) ChangeStatus(ctx context.Context, change Change, from string) (*User, lib.APIError) {
+	sqlUpdateStatus := `UPDATE users SET status = $2 WHERE user_id = $1 RETURNING updated_at`
+	sqlRevokeRefreshTokens := `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+	sqlRevokeAccessTokens := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, now())
+							  ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`
+
+	return d.change(ctx, change, func(tx *sql.Tx, u *User) (string, error) {
+		if u.Status != from {
+			return "", errConflict(fmt.Sprintf("user is %s, only %s users can be changed to %s", u.Status, from,
+				change.To))
+		}
+
+		if err := tx.QueryRowContext(ctx, sqlUpdateStatus, change.UserID, change.To).Scan(&u.UpdatedAt); err != nil {
+			return "", err
+		}
+
+		if change.To == StatusInactive {
+			if _, err := tx.ExecContext(ctx, sqlRevokeRefreshTokens, change.UserID); err != nil {
+				return "", err
+			}
+
+			if _, err := tx.ExecContext(ctx, sqlRevokeAccessTokens, change.UserID); err != nil {
+				return "", err
+			}
+		}
+
+		old := u.Status
+		u.Status = change.To
+
+		return old, nil
+	})
+}
+
+// FindInactive returns up to limit+1 inactive users after the user with afterUserID, oldest first,
+// so the caller can tell whether there's a next page.
+func (d *UserRepoDB) FindInactive(ctx context.Context, afterUserID string, limit int) ([]User, lib.APIError) {
+	sqlFindInactive := `SELECT user_id, username, email, role, status, created_at, updated_at FROM users
+						WHERE status = 'inactive'
+						  AND ($1 = '' OR id > (SELECT id FROM users WHERE user_id = nullif($1, '')::uuid))
+						ORDER BY id LIMIT $2`
+
+	rows, err := d.db.QueryContext(ctx, sqlFindInactive, afterUserID, limit+1)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query inactive users", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, limit+1)
+
+	for rows.Next() {
+		var u User
+		if err = rows.Scan(&u.UserID, &u.UserName, &u.Email, &u.Role, &u.Status, &u.CreatedAt,
+			&u.UpdatedAt); err != nil {
+			d.l.ErrorContext(ctx, "unable to scan user", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, "unable to iterate inactive users", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return users, nil
+}
+
+// conflictError is returned by apply funcs of change to fail with 409 instead of 500.
+type conflictError string
+
+func errConflict(message string) error {
+	return conflictError(message)
+}
+
+func (e conflictError) Error() string {
+	return string(e)
+}
+
+// change locks the user of a change, applies it with apply and records it in the admin audit log and the
+// tamper-evident audit log, in one transaction. apply returns the value the change replaced.
+func (d *UserRepoDB) change(ctx context.Context, change Change,
+	apply func(tx *sql.Tx, u *User) (string, error)) (*User, lib.APIError) {
+	sqlLockUser := `SELECT user_id, username, email, role, status, created_at, updated_at FROM users
+					WHERE user_id = $1 AND status <> 'deleted' FOR UPDATE`
+	sqlInsertAudit := `INSERT INTO admin_audit_log (admin_id, user_id, action, old_value, new_value, reason_code,
+						note, ip) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var u User
+
+	err = tx.QueryRowContext(ctx, sqlLockUser, change.UserID).Scan(&u.UserID, &u.UserName, &u.Email, &u.Role,
+		&u.Status, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by uuid")
+		}
+
+		d.l.ErrorContext(ctx, "unable to lock user", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	var old string
+	if old, err = apply(tx, &u); err != nil {
+		var conflict conflictError
+		if errors.As(err, &conflict) {
+			return nil, lib.ConflictError(conflict.Error())
+		}
+
+		d.l.ErrorContext(ctx, "unable to change user", "err", err.Error(), "action", change.Action)
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlInsertAudit, change.AdminID, change.UserID, change.Action, old, change.To,
+		change.ReasonCode, change.Note, change.IP); err != nil {
+		d.l.ErrorContext(ctx, "unable to record audit log entry", "err", err.Error(), "action", change.Action)
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if _, err = audit.AppendTx(ctx, tx, auditEntry(change, old)); err != nil {
+		d.l.ErrorContext(ctx, "unable to append audit log entry", "err", err.Error(), "action", change.Action)
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &u, nil
+}
+
+// auditEntry returns the audit log entry of a change that replaced old.
+func auditEntry(change Change, old string) audit.Entry {
+	action, field := audit.ActionStatusChange, "status"
+	if change.Action == ActionChangeRole {
+		action, field = audit.ActionRoleChange, "role"
+	}
+
+	diff := audit.Diff{field: {Before: old, After: change.To}}
+	diff.Add("reasonCode", nil, change.ReasonCode)
+	diff.Add("note", nil, nullIfEmpty(change.Note))
+
+	return audit.Entry{
+		Actor:  change.AdminID,
+		Target: change.UserID,
+		Action: action,
+		Diff:   diff,
+		IP:     change.IP,
+	}
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
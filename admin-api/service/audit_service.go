@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+type AuditService interface {
+	FindAuditLog(ctx context.Context, req domain.FindAuditLogRequest) (*domain.AuditLogPage, lib.APIError)
+}
+
+type DefaultAuditService struct {
+	log audit.Finder
+	l   *slog.Logger
+}
+
+func NewAuditService(log audit.Finder, l *slog.Logger) DefaultAuditService {
+	return DefaultAuditService{
+		log: log,
+		l:   l,
+	}
+}
+
+// FindAuditLog returns a page of audit log entries matching the filters of req, newest first,
+// DefaultPageSize entries unless a limit is given.
+func (s DefaultAuditService) FindAuditLog(ctx context.Context,
+	req domain.FindAuditLogRequest) (*domain.AuditLogPage, lib.APIError) {
+	f, apiErr := auditFilter(req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	entries, apiErr := s.log.Find(ctx, f)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	page := domain.AuditLogPage{Entries: entries}
+	if len(entries) > f.Limit {
+		page.Entries = entries[:f.Limit]
+		page.NextCursor = strconv.FormatInt(page.Entries[f.Limit-1].ID, 10)
+	}
+
+	return &page, nil
+}
+
+// auditFilter validates the filters of req and returns them as an audit.Filter, with the default limit if unset.
+func auditFilter(req domain.FindAuditLogRequest) (audit.Filter, lib.APIError) {
+	f := audit.Filter{Actor: req.Actor, Target: req.Target, Action: req.Action, Limit: req.Limit}
+
+	var errs error
+
+	if len(req.Actor) > domain.MaxAuditIDLen || len(req.Target) > domain.MaxAuditIDLen {
+		errs = errors.Join(errs, fmt.Errorf("actor and target can't exceed %d characters", domain.MaxAuditIDLen))
+	}
+
+	if req.Limit < 0 || req.Limit > domain.MaxPageSize {
+		errs = errors.Join(errs, fmt.Errorf("limit must be between 1 and %d", domain.MaxPageSize))
+	}
+
+	var err error
+
+	if req.From != "" {
+		if f.From, err = time.Parse(time.RFC3339, req.From); err != nil {
+			errs = errors.Join(errs, errors.New("from must be an RFC 3339 time"))
+		}
+	}
+
+	if req.To != "" {
+		if f.To, err = time.Parse(time.RFC3339, req.To); err != nil {
+			errs = errors.Join(errs, errors.New("to must be an RFC 3339 time"))
+		}
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		errs = errors.Join(errs, errors.New("from must be before to"))
+	}
+
+	if req.Cursor != "" {
+		if f.BeforeID, err = strconv.ParseInt(req.Cursor, 10, 64); err != nil || f.BeforeID < 1 {
+			errs = errors.Join(errs, errors.New("cursor is invalid"))
+		}
+	}
+
+	if errs != nil {
+		return audit.Filter{}, lib.BadRequestError(errs.Error())
+	}
+
+	if f.Limit == 0 {
+		f.Limit = domain.DefaultPageSize
+	}
+
+	return f, nil
+}
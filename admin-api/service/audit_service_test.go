@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+)
+
+func TestAuditFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       domain.FindAuditLogRequest
+		wantLimit int
+		wantFrom  time.Time
+		wantID    int64
+		errMsg    string
+	}{
+		{name: "Defaults", req: domain.FindAuditLogRequest{}, wantLimit: domain.DefaultPageSize},
+		{name: "Filters", req: domain.FindAuditLogRequest{Actor: "6f1c2a8e-4b1d-4c6e-9f3a-2d5e7b8c9a01",
+			From: "2024-01-01T00:00:00Z", To: "2024-02-01T00:00:00+06:00", Cursor: "42", Limit: 5},
+			wantLimit: 5, wantFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), wantID: 42},
+		{name: "Invalid_Times", req: domain.FindAuditLogRequest{From: "yesterday", To: "2024-02-01"},
+			errMsg: "from must be an RFC 3339 time\nto must be an RFC 3339 time"},
+		{name: "From_After_To", req: domain.FindAuditLogRequest{From: "2024-02-01T00:00:00Z",
+			To: "2024-01-01T00:00:00Z"}, errMsg: "from must be before to"},
+		{name: "Invalid_Cursor_And_Limit", req: domain.FindAuditLogRequest{Cursor: "-1", Limit: 101},
+			errMsg: "limit must be between 1 and 100\ncursor is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := auditFilter(tt.req)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Fatalf("expected error %q, but got %v", tt.errMsg, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, but got %q", err.Error())
+			}
+
+			if f.Limit != tt.wantLimit || !f.From.Equal(tt.wantFrom) || f.BeforeID != tt.wantID {
+				t.Errorf("auditFilter() = %+v, want limit %d, from %v, before id %d", f, tt.wantLimit, tt.wantFrom,
+					tt.wantID)
+			}
+		})
+	}
+}
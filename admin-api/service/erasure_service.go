@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// erasureStats counts the erased users and the failed erasures, a failed request is counted on every attempt.
+// They're published at /debug/vars by services exposing expvar.
+var erasureStats = expvar.NewMap("erasure")
+
+type ErasureService interface {
+	FindRequests(ctx context.Context, status string, cursor string, limit int) (*domain.ErasureRequestsPage,
+		lib.APIError)
+	Approve(ctx context.Context, admin domain.Admin, requestID string,
+		req domain.ErasureDecisionRequest) (*domain.ErasureRequest, lib.APIError)
+	Reject(ctx context.Context, admin domain.Admin, requestID string,
+		req domain.ErasureDecisionRequest) (*domain.ErasureRequest, lib.APIError)
+}
+
+type DefaultErasureService struct {
+	repo domain.ErasureRepository
+	l    *slog.Logger
+}
+
+func NewErasureService(repo domain.ErasureRepository, l *slog.Logger) DefaultErasureService {
+	return DefaultErasureService{
+		repo: repo,
+		l:    l,
+	}
+}
+
+// FindRequests returns a page of erasure requests with the status, every status if it's empty, oldest first
+// after the request id cursor, DefaultPageSize requests unless a limit is given.
+func (s DefaultErasureService) FindRequests(ctx context.Context, status string, cursor string,
+	limit int) (*domain.ErasureRequestsPage, lib.APIError) {
+	if apiErr := validateErasureFilter(status, cursor, limit); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if limit == 0 {
+		limit = domain.DefaultPageSize
+	}
+
+	requests, apiErr := s.repo.FindRequests(ctx, status, cursor, limit)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	page := domain.ErasureRequestsPage{Requests: requests}
+	if len(requests) > limit {
+		page.Requests = requests[:limit]
+		page.NextCursor = page.Requests[limit-1].RequestID
+	}
+
+	return &page, nil
+}
+
+// Approve approves a pending erasure request, the user's personal data is erased once ErasureCoolingOff passed.
+func (s DefaultErasureService) Approve(ctx context.Context, admin domain.Admin, requestID string,
+	req domain.ErasureDecisionRequest) (*domain.ErasureRequest, lib.APIError) {
+	return s.decide(ctx, admin, requestID, req, domain.ErasureApproved)
+}
+
+// Reject rejects a pending erasure request, the note must tell the user why.
+func (s DefaultErasureService) Reject(ctx context.Context, admin domain.Admin, requestID string,
+	req domain.ErasureDecisionRequest) (*domain.ErasureRequest, lib.APIError) {
+	return s.decide(ctx, admin, requestID, req, domain.ErasureRejected)
+}
+
+// StartErasure erases the personal data of users whose approved erasure requests are due every interval,
+// until ctx is done. Failed requests are listed with their error by FindRequests and counted in erasureStats.
+func (s DefaultErasureService) StartErasure(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.eraseDue(ctx)
+			}
+		}
+	}()
+}
+
+// eraseDue carries out the due erasure requests once and reports the run.
+func (s DefaultErasureService) eraseDue(ctx context.Context) {
+	run, apiErr := s.repo.EraseDue(ctx)
+
+	erasureStats.Add("erased", int64(run.Erased))
+	erasureStats.Add("failed", int64(run.Failed))
+
+	switch {
+	case apiErr != nil:
+		s.l.ErrorContext(ctx, "carrying out due erasure requests stopped", "err", apiErr.WithCauses(),
+			"erased", run.Erased, "failed", run.Failed)
+	case run.Failed > 0:
+		s.l.WarnContext(ctx, "due erasure requests failed", "erased", run.Erased, "failed", run.Failed)
+	case run.Erased > 0:
+		s.l.InfoContext(ctx, "due erasure requests carried out", "erased", run.Erased)
+	}
+}
+
+// decide approves or rejects a request, admins can't decide on erasing their own data.
+func (s DefaultErasureService) decide(ctx context.Context, admin domain.Admin, requestID string,
+	req domain.ErasureDecisionRequest, status string) (*domain.ErasureRequest, lib.APIError) {
+	if apiErr := validateDecision(requestID, status, req.Note); apiErr != nil {
+		return nil, apiErr
+	}
+
+	er, apiErr := s.repo.FindRequest(ctx, requestID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if apiErr = checkDecider(er, admin); apiErr != nil {
+		return nil, apiErr
+	}
+
+	decision := domain.Decision{
+		AdminID:   admin.UserID,
+		IP:        admin.IP,
+		RequestID: requestID,
+		Status:    status,
+		Note:      strings.TrimSpace(req.Note),
+	}
+
+	if status == domain.ErasureApproved {
+		decision.EraseAfter = time.Now().Add(domain.ErasureCoolingOff)
+	}
+
+	if er, apiErr = s.repo.Decide(ctx, decision); apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.l.InfoContext(ctx, "erasure request decided", "adminId", admin.UserID, "requestId", requestID,
+		"status", status)
+
+	return er, nil
+}
+
+// checkDecider returns 403 if the admin can't decide on the request, requests to erase their own data,
+// requests they filed and requests filed while they impersonated the requester.
+func checkDecider(er *domain.ErasureRequest, admin domain.Admin) lib.APIError {
+	switch {
+	case er.UserID == admin.UserID:
+		return lib.ForbiddenError("admins can't decide on erasing their own data")
+	case er.RequestedBy != nil && *er.RequestedBy == admin.UserID,
+		er.ImpersonatedBy != nil && *er.ImpersonatedBy == admin.UserID:
+		return lib.ForbiddenError("admins can't decide on erasure requests they filed")
+	default:
+		return nil
+	}
+}
+
+// validateErasureFilter checks the status is one of the erasure statuses, if given, the cursor a request id
+// and the limit in range.
+func validateErasureFilter(status string, cursor string, limit int) lib.APIError {
+	var errs error
+
+	switch status {
+	case "", domain.ErasurePending, domain.ErasureApproved, domain.ErasureRejected, domain.ErasureCancelled,
+		domain.ErasureCompleted:
+	default:
+		errs = errors.Join(errs, errors.New("status must be one of: pending, approved, rejected, cancelled, completed"))
+	}
+
+	if limit < 0 || limit > domain.MaxPageSize {
+		errs = errors.Join(errs, fmt.Errorf("limit must be between 1 and %d", domain.MaxPageSize))
+	}
+
+	if cursor != "" && !uuidRegex.MatchString(cursor) {
+		errs = errors.Join(errs, errors.New("cursor is invalid"))
+	}
+
+	if errs != nil {
+		return lib.BadRequestError(errs.Error())
+	}
+
+	return nil
+}
+
+// validateDecision checks the request id is a uuid, and the note is short enough and given with a rejection.
+func validateDecision(requestID string, status string, note string) lib.APIError {
+	var errs error
+
+	if !uuidRegex.MatchString(requestID) {
+		errs = errors.Join(errs, errors.New("request id must be a valid uuid"))
+	}
+
+	switch {
+	case len(note) > domain.MaxNoteLen:
+		errs = errors.Join(errs, fmt.Errorf("note can't exceed %d characters", domain.MaxNoteLen))
+	case status == domain.ErasureRejected && strings.TrimSpace(note) == "":
+		errs = errors.Join(errs, errors.New("note is required to reject an erasure request"))
+	}
+
+	if errs != nil {
+		return lib.BadRequestError(errs.Error())
+	}
+
+	return nil
+}
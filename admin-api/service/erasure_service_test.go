@@ -0,0 +1,86 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+)
+
+func TestValidateDecision(t *testing.T) {
+	requestID := "0b7d3e2f-1a4c-4d5e-8f6a-9b8c7d6e5f40"
+
+	tests := []struct {
+		name      string
+		requestID string
+		status    string
+		note      string
+		errMsg    string
+	}{
+		{name: "Approve_Without_Note", requestID: requestID, status: domain.ErasureApproved},
+		{name: "Reject_With_Note", requestID: requestID, status: domain.ErasureRejected, note: "open dispute"},
+		{name: "Reject_Without_Note", requestID: requestID, status: domain.ErasureRejected, note: " ",
+			errMsg: "note is required to reject an erasure request"},
+		{name: "Invalid_Request_ID", requestID: "42", status: domain.ErasureApproved, note: strings.Repeat("a", 513),
+			errMsg: "request id must be a valid uuid\nnote can't exceed 512 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDecision(tt.requestID, tt.status, tt.note)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("validateDecision() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestCheckDecider(t *testing.T) {
+	admin := domain.Admin{UserID: "admin-1"}
+	adminID, otherID := "admin-1", "admin-2"
+
+	tests := []struct {
+		name   string
+		er     domain.ErasureRequest
+		errMsg string
+	}{
+		{name: "Requested_By_User", er: domain.ErasureRequest{UserID: "user-1", RequestedBy: ptr("user-1")}},
+		{name: "Impersonated_By_Other_Admin", er: domain.ErasureRequest{UserID: "user-1", RequestedBy: ptr("user-1"),
+			ImpersonatedBy: &otherID}},
+		{name: "Own_Data", er: domain.ErasureRequest{UserID: adminID, RequestedBy: &adminID},
+			errMsg: "admins can't decide on erasing their own data"},
+		{name: "Requested_By_Admin", er: domain.ErasureRequest{UserID: "user-1", RequestedBy: &adminID},
+			errMsg: "admins can't decide on erasure requests they filed"},
+		{name: "Impersonated_By_Admin", er: domain.ErasureRequest{UserID: "user-1", RequestedBy: ptr("user-1"),
+			ImpersonatedBy: &adminID}, errMsg: "admins can't decide on erasure requests they filed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDecider(&tt.er, admin)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("checkDecider() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
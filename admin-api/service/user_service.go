@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+var uuidRegex = regexp.MustCompile(
+	`^[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[1-5][a-fA-F0-9]{3}-[89abAB][a-fA-F0-9]{3}-[a-fA-F0-9]{12}$`)
+
+type UserService interface {
+	ChangeRole(ctx context.Context, admin domain.Admin, userID string,
+		req domain.ChangeRoleRequest) (*domain.User, lib.APIError)
+	Activate(ctx context.Context, admin domain.Admin, userID string,
+		req domain.ChangeStatusRequest) (*domain.User, lib.APIError)
+	Deactivate(ctx context.Context, admin domain.Admin, userID string,
+		req domain.ChangeStatusRequest) (*domain.User, lib.APIError)
+	FindInactive(ctx context.Context, cursor string, limit int) (*domain.InactiveUsersPage, lib.APIError)
+}
+
+type DefaultUserService struct {
+	repo domain.UserRepository
+	l    *slog.Logger
+}
+
+func NewUserService(repo domain.UserRepository, l *slog.Logger) DefaultUserService {
+	return DefaultUserService{
+		repo: repo,
+		l:    l,
+	}
+}
+
+// ChangeRole gives a user a new role, the user's access tokens are revoked so the old role stops applying.
+// Admins can't change their own role, so the last admin can't lock everyone out by mistake.
+func (s DefaultUserService) ChangeRole(ctx context.Context, admin domain.Admin, userID string,
+	req domain.ChangeRoleRequest) (*domain.User, lib.APIError) {
+	if apiErr := validateChange(admin, userID, req.ReasonCode, req.Note); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if !slices.Contains(domain.Roles, req.Role) {
+		return nil, lib.BadRequestError(fmt.Sprintf("role must be one of: %s", strings.Join(domain.Roles, ", ")))
+	}
+
+	user, apiErr := s.repo.ChangeRole(ctx, newChange(admin, userID, domain.ActionChangeRole, req.Role,
+		req.ReasonCode, req.Note))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.l.InfoContext(ctx, "user role changed", "adminId", admin.UserID, "userId", userID, "role", req.Role,
+		"reasonCode", req.ReasonCode)
+
+	return user, nil
+}
+
+// Activate reactivates an inactive user.
+func (s DefaultUserService) Activate(ctx context.Context, admin domain.Admin, userID string,
+	req domain.ChangeStatusRequest) (*domain.User, lib.APIError) {
+	return s.changeStatus(ctx, admin, userID, req, domain.ActionActivate, domain.StatusInactive,
+		domain.StatusActive)
+}
+
+// Deactivate deactivates an active user and logs it out everywhere, it can't log in until it's activated again.
+func (s DefaultUserService) Deactivate(ctx context.Context, admin domain.Admin, userID string,
+	req domain.ChangeStatusRequest) (*domain.User, lib.APIError) {
+	return s.changeStatus(ctx, admin, userID, req, domain.ActionDeactivate, domain.StatusActive,
+		domain.StatusInactive)
+}
+
+// FindInactive returns a page of inactive users after the user id cursor, DefaultPageSize users unless
+// a limit is given.
+func (s DefaultUserService) FindInactive(ctx context.Context, cursor string,
+	limit int) (*domain.InactiveUsersPage, lib.APIError) {
+	if limit < 0 || limit > domain.MaxPageSize {
+		return nil, lib.BadRequestError(fmt.Sprintf("limit must be between 1 and %d", domain.MaxPageSize))
+	}
+
+	if limit == 0 {
+		limit = domain.DefaultPageSize
+	}
+
+	if cursor != "" && !uuidRegex.MatchString(cursor) {
+		return nil, lib.BadRequestError("cursor is invalid")
+	}
+
+	users, apiErr := s.repo.FindInactive(ctx, cursor, limit)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	page := domain.InactiveUsersPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = page.Users[limit-1].UserID
+	}
+
+	return &page, nil
+}
+
+func (s DefaultUserService) changeStatus(ctx context.Context, admin domain.Admin, userID string,
+	req domain.ChangeStatusRequest, action, from, to string) (*domain.User, lib.APIError) {
+	if apiErr := validateChange(admin, userID, req.ReasonCode, req.Note); apiErr != nil {
+		return nil, apiErr
+	}
+
+	user, apiErr := s.repo.ChangeStatus(ctx, newChange(admin, userID, action, to, req.ReasonCode, req.Note), from)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.l.InfoContext(ctx, "user status changed", "adminId", admin.UserID, "userId", userID, "status", to,
+		"reasonCode", req.ReasonCode)
+
+	return user, nil
+}
+
+// validateChange checks the target of a change is another user by uuid, and the change has a known reason code,
+// with a note for the other reason.
+func validateChange(admin domain.Admin, userID string, reasonCode string, note string) lib.APIError {
+	var errs error
+
+	if !uuidRegex.MatchString(userID) {
+		errs = errors.Join(errs, errors.New("user id must be a valid uuid"))
+	} else if userID == admin.UserID {
+		errs = errors.Join(errs, errors.New("admins can't change their own role or status"))
+	}
+
+	if _, ok := domain.ReasonCodes[reasonCode]; !ok {
+		errs = errors.Join(errs, fmt.Errorf("reason code must be one of: %s", strings.Join(reasonCodes(), ", ")))
+	}
+
+	switch {
+	case len(note) > domain.MaxNoteLen:
+		errs = errors.Join(errs, fmt.Errorf("note can't exceed %d characters", domain.MaxNoteLen))
+	case reasonCode == domain.ReasonOther && strings.TrimSpace(note) == "":
+		errs = errors.Join(errs, errors.New("note is required with reason code other"))
+	}
+
+	if errs != nil {
+		return lib.BadRequestError(errs.Error())
+	}
+
+	return nil
+}
+
+func newChange(admin domain.Admin, userID, action, to, reasonCode, note string) domain.Change {
+	return domain.Change{
+		AdminID:    admin.UserID,
+		UserID:     userID,
+		Action:     action,
+		To:         to,
+		ReasonCode: reasonCode,
+		Note:       strings.TrimSpace(note),
+		IP:         admin.IP,
+	}
+}
+
+// reasonCodes returns the known reason codes sorted.
+func reasonCodes() []string {
+	codes := make([]string, 0, len(domain.ReasonCodes))
+	for code := range domain.ReasonCodes {
+		codes = append(codes, code)
+	}
+
+	slices.Sort(codes)
+
+	return codes
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashtishad/instabid-wallet/admin-api/domain"
+)
+
+func TestValidateChange(t *testing.T) {
+	admin := domain.Admin{UserID: "6f1c2a8e-4b1d-4c6e-9f3a-2d5e7b8c9a01"}
+	userID := "0b7d3e2f-1a4c-4d5e-8f6a-9b8c7d6e5f40"
+
+	tests := []struct {
+		name       string
+		userID     string
+		reasonCode string
+		note       string
+		errMsg     string
+	}{
+		{name: "Valid", userID: userID, reasonCode: "kyc_verified"},
+		{name: "Other_With_Note", userID: userID, reasonCode: "other", note: "requested by compliance"},
+		{name: "Other_Without_Note", userID: userID, reasonCode: "other", note: " ",
+			errMsg: "note is required with reason code other"},
+		{name: "Unknown_Reason", userID: userID, reasonCode: "because",
+			errMsg: "reason code must be one of: dormant_account, fraud_suspected, issue_resolved, kyc_failed, " +
+				"kyc_verified, merchant_approved, merchant_revoked, other, policy_violation, staff_change, user_request"},
+		{name: "Self", userID: admin.UserID, reasonCode: "staff_change",
+			errMsg: "admins can't change their own role or status"},
+		{name: "Invalid_User_ID", userID: "42", reasonCode: "user_request", note: strings.Repeat("a", 513),
+			errMsg: "user id must be a valid uuid\nnote can't exceed 512 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChange(admin, tt.userID, tt.reasonCode, tt.note)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("validateChange() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+const pathParamKeyID = "key_id"
+
+// APIKeyHandlers let merchants manage the API keys of their own account.
+type APIKeyHandlers struct {
+	service service.APIKeyService
+}
+
+func (kh APIKeyHandlers) CreateAPIKeyHandler(c *gin.Context) {
+	var req domain.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, apiErr := kh.service.Create(c.Request.Context(), claimsFromContext(c).UserID, req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{"apiKey": key, "key": secret})
+}
+
+func (kh APIKeyHandlers) FindAPIKeysHandler(c *gin.Context) {
+	keys, apiErr := kh.service.FindAll(c.Request.Context(), claimsFromContext(c).UserID)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apiKeys": keys})
+}
+
+func (kh APIKeyHandlers) UpdateAPIKeyHandler(c *gin.Context) {
+	var req domain.UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, apiErr := kh.service.UpdateLabel(c.Request.Context(), claimsFromContext(c).UserID, c.Param(pathParamKeyID),
+		req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apiKey": key})
+}
+
+func (kh APIKeyHandlers) RevokeAPIKeyHandler(c *gin.Context) {
+	userID := claimsFromContext(c).UserID
+	if apiErr := kh.service.Revoke(c.Request.Context(), userID, c.Param(pathParamKeyID)); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
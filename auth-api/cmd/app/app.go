@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log/slog"
@@ -9,6 +10,15 @@ import (
 
 	"github.com/ashtishad/instabid-wallet/auth-api/domain"
 	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/password"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/ashtishad/instabid-wallet/lib/revocation"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,13 +32,117 @@ func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
 	var r = gin.New()
 	srv.Handler = r
 
+	// client IPs are taken from X-Forwarded-For only behind the configured proxies
+	if err := r.SetTrustedProxies(lib.TrustedProxies()); err != nil {
+		l.Error("invalid TRUSTED_PROXIES", "err", err.Error())
+		os.Exit(1)
+	}
+
+	// Load the token revocation list and keep it in sync, every token validation consults it
+	revocations := revocation.NewStore(dbClient, l)
+	if apiErr := revocations.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load token revocation list", "err", apiErr.WithCauses())
+	}
+
+	revocations.StartSync(context.Background(), revocation.DefaultSyncInterval)
+	jwtutils.UseRevocationList(revocations)
+
+	// Load the role permissions and keep them in sync, admins change them through the rbac routes
+	permissions := policy.NewRolePermissions(dbClient, l)
+	if apiErr := permissions.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load role permissions", "err", apiErr.WithCauses())
+	}
+
+	permissions.StartSync(context.Background(), policy.DefaultSyncInterval)
+
+	// Load the signing keys, tokens are verified locally with the same key set auth-api publishes as JWKS
+	keys := loadSigningKeys(l)
+	jwtutils.UseKeyResolver(keys)
+
 	// Wire up the handler for auth API
 	authRepositoryDB := domain.NewAuthRepoDB(dbClient, l)
-	ah := AuthHandlers{service.NewAuthService(authRepositoryDB, l)}
+	tokenRepositoryDB := domain.NewTokenRepoDB(dbClient, l)
+	oneTimeTokenRepositoryDB := domain.NewOneTimeTokenRepoDB(dbClient, l)
+	mfaRepositoryDB := domain.NewMFARepoDB(dbClient, l)
+	loginAttemptRepositoryDB := domain.NewLoginAttemptRepoDB(dbClient, l)
+	authEventRepositoryDB := domain.NewAuthEventRepoDB(dbClient, l)
+	authService := service.NewAuthService(authRepositoryDB, tokenRepositoryDB, mfaRepositoryDB,
+		loginAttemptRepositoryDB, authEventRepositoryDB, audit.NewStoreDB(dbClient, l), revocations, keys, l)
+	n := notifier.FromEnv(l)
+	ah := AuthHandlers{
+		service:    authService,
+		mfaService: service.NewMFAService(mfaRepositoryDB, l),
+		passwordService: service.NewPasswordService(authRepositoryDB, oneTimeTokenRepositoryDB, authService,
+			password.PolicyFromEnv(l), n, l),
+		magicLinkService: service.NewMagicLinkService(authRepositoryDB, oneTimeTokenRepositoryDB, authService, n, l),
+	}
+	apiKeyService := service.NewAPIKeyService(domain.NewAPIKeyRepoDB(dbClient, l), l)
+	kh := APIKeyHandlers{apiKeyService}
+	oh := OAuthHandlers{
+		service: service.NewOAuthService(domain.NewOAuthClientRepoDB(dbClient, l), keys, l),
+		apiKeys: apiKeyService,
+		// uncached, so introspection reflects revocations immediately
+		verifier: verifier.New(permissions, verifier.Config{}),
+	}
+	ih := ImpersonationHandlers{service.NewImpersonationService(domain.NewImpersonationRepoDB(dbClient, l),
+		authRepositoryDB, keys, l)}
+	rh := RBACHandlers{service.NewRBACService(domain.NewRBACRepoDB(dbClient, l), permissions, l)}
 
 	// Route URL mappings for the auth API
 	r.POST("/login", ah.LoginHandler)
-	r.GET("/verify", ah.VerifyHandler)
+	r.POST("/login/mfa", ah.LoginMFAHandler)
+	r.POST("/login/magic-link", ah.MagicLinkHandler)
+	r.POST("/login/magic-link/consume", ah.ConsumeMagicLinkHandler)
+	r.POST("/refresh", ah.RefreshHandler)
+	r.POST("/oauth/token", oh.TokenHandler)
+	r.POST(jwtutils.IntrospectPath, oh.IntrospectHandler)
+	r.POST(jwtutils.AuthorizePath, oh.AuthorizeHandler)
+	r.GET(jwtutils.JWKSPath, jwksHandler(keys, l))
+	r.POST("/reset-password", ah.ResetPasswordHandler)
+	r.POST("/reset-password/confirm", ah.ConfirmResetPasswordHandler)
+
+	authenticated := r.Group("", requireAccessToken(impersonation.NewRecorderDB(dbClient, l), l))
+	{
+		authenticated.POST("/logout", ah.LogoutHandler)
+		authenticated.POST("/logout/all", ah.LogoutAllHandler)
+		authenticated.PUT("/password", ah.ChangePasswordHandler)
+		authenticated.GET("/sessions", ah.SessionsHandler)
+		authenticated.DELETE("/sessions/:session_id", ah.RevokeSessionHandler)
+		authenticated.POST("/users/:user_id/logout", requireRole(domain.RoleAdmin), ah.LogoutUserHandler)
+		authenticated.POST("/users/:user_id/unlock", requireRole(domain.RoleAdmin), ah.UnlockUserHandler)
+		authenticated.POST("/mfa/enroll", ah.EnrollMFAHandler)
+		authenticated.POST("/mfa/confirm", ah.ConfirmMFAHandler)
+		authenticated.DELETE("/users/:user_id/mfa", requireRole(domain.RoleAdmin), ah.ResetMFAHandler)
+		authenticated.POST("/users/:user_id/impersonate", requireRole(domain.RoleAdmin), ih.ImpersonateHandler)
+		authenticated.GET("/impersonations", ih.FindImpersonationsHandler)
+	}
+
+	oauthClients := authenticated.Group("/oauth/clients", requireRole(domain.RoleAdmin))
+	{
+		oauthClients.GET("", oh.FindClientsHandler)
+		oauthClients.POST("", oh.CreateClientHandler)
+		oauthClients.DELETE("/:client_id", oh.DisableClientHandler)
+	}
+
+	apiKeys := authenticated.Group("/api-keys", requireRole(domain.RoleMerchant))
+	{
+		apiKeys.GET("", kh.FindAPIKeysHandler)
+		apiKeys.POST("", kh.CreateAPIKeyHandler)
+		apiKeys.PATCH("/:key_id", kh.UpdateAPIKeyHandler)
+		apiKeys.DELETE("/:key_id", kh.RevokeAPIKeyHandler)
+	}
+
+	rbac := authenticated.Group("/rbac", requireRole(domain.RoleAdmin))
+	{
+		rbac.GET("/roles", rh.FindRolesHandler)
+		rbac.POST("/roles", rh.CreateRoleHandler)
+		rbac.DELETE("/roles/:role", rh.DeleteRoleHandler)
+		rbac.PUT("/roles/:role/permissions/:permission_id", rh.GrantHandler)
+		rbac.DELETE("/roles/:role/permissions/:permission_id", rh.RevokeHandler)
+		rbac.GET("/permissions", rh.FindPermissionsHandler)
+		rbac.POST("/permissions", rh.CreatePermissionHandler)
+		rbac.DELETE("/permissions/:permission_id", rh.DeletePermissionHandler)
+	}
 
 	// Start the server
 	go func() {
@@ -37,3 +151,29 @@ func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
 		}
 	}()
 }
+
+// loadSigningKeys loads the token signing keys from the PEM files in JWT_KEYS_DIR,
+// JWT_ACTIVE_KID picks the signing key, by default the key whose file name sorts last.
+// Without JWT_KEYS_DIR an ephemeral Ed25519 key is generated, tokens then don't survive a restart.
+func loadSigningKeys(l *slog.Logger) *jwtutils.KeySet {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		k, err := jwtutils.GenerateEd25519Key()
+		if err != nil {
+			l.Error("unable to generate signing key", "err", err.Error())
+			os.Exit(1)
+		}
+
+		l.Warn("JWT_KEYS_DIR not defined, signing tokens with an ephemeral key", "kid", k.ID)
+
+		return jwtutils.NewKeySet(k)
+	}
+
+	keys, err := jwtutils.LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		l.Error("unable to load signing keys", "err", err.Error(), "dir", dir)
+		os.Exit(1)
+	}
+
+	return keys
+}
@@ -2,25 +2,21 @@ package app
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 
 	"github.com/ashtishad/instabid-wallet/auth-api/domain"
 	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/ashtishad/instabid-wallet/lib"
 	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-)
-
-const (
-	queryParamToken     = "token"
-	mapKeyRole          = "Role"
-	queryParamRouteName = "routeName"
-	queryParamUserID    = "userId"
-	mapKeyUserID        = "UserID"
 )
 
 type AuthHandlers struct {
-	service service.AuthService
+	service          service.AuthService
+	passwordService  service.PasswordService
+	mfaService       service.MFAService
+	magicLinkService service.MagicLinkService
 }
 
 func (ah AuthHandlers) LoginHandler(c *gin.Context) {
@@ -30,14 +26,8 @@ func (ah AuthHandlers) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
-
-	switch {
-	case req.Email != "":
-		ctx = context.WithValue(ctx, domain.UserCredentialKey, domain.UserCredentialEmail)
-	case req.Username != "":
-		ctx = context.WithValue(ctx, domain.UserCredentialKey, domain.UserCredentialUsername)
-	default:
+	ctx, ok := credentialContext(c, req.Email, req.Username)
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "you must provide either an email or a username, along with a password."})
 		return
@@ -52,54 +42,348 @@ func (ah AuthHandlers) LoginHandler(c *gin.Context) {
 		return
 	}
 
+	loginResponse(c, res)
+}
+
+// MagicLinkHandler sends a single use login link to a verified email.
+func (ah AuthHandlers) MagicLinkHandler(c *gin.Context) {
+	var req domain.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, ok := credentialContext(c, req.Email, "")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you must provide an email."})
+		return
+	}
+
+	if apiErr := ah.magicLinkService.RequestLink(ctx, req); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if a verified account matches, a login link has been sent",
+	})
+}
+
+// ConsumeMagicLinkHandler exchanges the token of a login link for a session, responding like LoginHandler.
+func (ah AuthHandlers) ConsumeMagicLinkHandler(c *gin.Context) {
+	var req domain.ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, apiErr := ah.magicLinkService.Consume(clientContext(c), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	loginResponse(c, res)
+}
+
+// LoginMFAHandler exchanges the mfa token returned by LoginHandler and a TOTP or recovery code for a session.
+func (ah AuthHandlers) LoginMFAHandler(c *gin.Context) {
+	var req domain.LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, apiErr := ah.service.LoginMFA(clientContext(c), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"token": &res.AccessToken,
-		"user":  &res.Login,
+		"token":        &res.AccessToken,
+		"refreshToken": &res.RefreshToken,
+		"user":         &res.Login,
 	})
 }
 
-func (ah AuthHandlers) VerifyHandler(c *gin.Context) {
-	tokenStr := c.Query(queryParamToken)
-	if tokenStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Token required"})
+func (ah AuthHandlers) RefreshHandler(c *gin.Context) {
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, apiErr := ah.service.Refresh(clientContext(c), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        &res.AccessToken,
+		"refreshToken": &res.RefreshToken,
+		"user":         &res.Login,
+	})
+}
+
+// LogoutHandler logs out the session of the presented access token.
+func (ah AuthHandlers) LogoutHandler(c *gin.Context) {
+	if apiErr := ah.service.Logout(c.Request.Context(), claimsFromContext(c)); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAllHandler logs out every session of the authenticated user.
+func (ah AuthHandlers) LogoutAllHandler(c *gin.Context) {
+	if apiErr := ah.service.LogoutAll(c.Request.Context(), claimsFromContext(c).UserID); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutUserHandler lets admins log out every session of another user, e.g. for compromised accounts.
+func (ah AuthHandlers) LogoutUserHandler(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user id can't be empty"})
+		return
+	}
+
+	if apiErr := ah.service.LogoutAll(c.Request.Context(), userID); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SessionsHandler lists the active sessions of the current user.
+func (ah AuthHandlers) SessionsHandler(c *gin.Context) {
+	sessions, apiErr := ah.service.Sessions(c.Request.Context(), claimsFromContext(c))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSessionHandler logs out one session of the current user by ID.
+func (ah AuthHandlers) RevokeSessionHandler(c *gin.Context) {
+	userID := claimsFromContext(c).UserID
+	if apiErr := ah.service.RevokeSession(c.Request.Context(), userID, c.Param("session_id")); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockUserHandler lifts the login lockout of a specific user by ID.
+func (ah AuthHandlers) UnlockUserHandler(c *gin.Context) {
+	if apiErr := ah.service.Unlock(c.Request.Context(), c.Param("user_id")); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// EnrollMFAHandler generates a TOTP secret for the authenticated user, returned as an otpauth:// uri too.
+func (ah AuthHandlers) EnrollMFAHandler(c *gin.Context) {
+	enrollment, apiErr := ah.mfaService.Enroll(c.Request.Context(), claimsFromContext(c))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFAHandler enables MFA with a first code and responds with the recovery codes, they are shown only once.
+func (ah AuthHandlers) ConfirmMFAHandler(c *gin.Context) {
+	var req domain.ConfirmMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, apiErr := ah.mfaService.Confirm(c.Request.Context(), claimsFromContext(c).UserID, req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// ResetMFAHandler disables MFA of a specific user by ID, so the user can log in with a password and enroll again.
+func (ah AuthHandlers) ResetMFAHandler(c *gin.Context) {
+	if apiErr := ah.mfaService.Reset(c.Request.Context(), c.Param("user_id")); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
 		return
 	}
 
-	token, err := jwtutils.ParseAndValidateToken(tokenStr)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	c.Status(http.StatusNoContent)
+}
+
+// ResetPasswordHandler sends a password reset link, it responds with 202 whether the user exists or not.
+func (ah AuthHandlers) ResetPasswordHandler(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	ctx, ok := credentialContext(c, req.Email, req.Username)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you must provide either an email or a username."})
 		return
 	}
 
-	// Extract role and userId from claims
-	role, roleOk := claims[mapKeyRole].(string)
-	userID, userIDOk := claims[mapKeyUserID].(string)
+	if apiErr := ah.passwordService.RequestReset(ctx, req); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if an account matches, a password reset link has been sent",
+	})
+}
+
+// ConfirmResetPasswordHandler sets a new password using a password reset token.
+func (ah AuthHandlers) ConfirmResetPasswordHandler(c *gin.Context) {
+	var req domain.ConfirmResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if !roleOk || !userIDOk {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Role or UserId not found in token"})
+	if apiErr := ah.passwordService.ConfirmReset(c.Request.Context(), req); apiErr != nil {
+		c.JSON(apiErr.Code(), lib.ErrorBody(apiErr))
 		return
 	}
 
-	routeName := c.Query(queryParamRouteName)
+	c.Status(http.StatusNoContent)
+}
 
-	// Check role-based permissions
-	if !domain.Permissions.IsAuthorizedFor(role, routeName) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this resource"})
+// ChangePasswordHandler sets a new password for the authenticated user, given the current one,
+// every session including the current one is logged out.
+func (ah AuthHandlers) ChangePasswordHandler(c *gin.Context) {
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check userId-based permissions if the path includes userId
-	pathUserID := c.Query(queryParamUserID)
-	if pathUserID != "" && pathUserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access resources for another user"})
+	if apiErr := ah.passwordService.Change(clientContext(c), claimsFromContext(c).UserID, req); apiErr != nil {
+		c.JSON(apiErr.Code(), lib.ErrorBody(apiErr))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"claims": claims})
+	c.Status(http.StatusNoContent)
+}
+
+// credentialContext returns the request context carrying the client ip and the credential field
+// the user identifies with, email takes precedence over username. It returns false if both are empty.
+func credentialContext(c *gin.Context, email string, username string) (context.Context, bool) {
+	ctx := clientContext(c)
+
+	switch {
+	case email != "":
+		return context.WithValue(ctx, domain.UserCredentialKey, domain.UserCredentialEmail), true
+	case username != "":
+		return context.WithValue(ctx, domain.UserCredentialKey, domain.UserCredentialUsername), true
+	default:
+		return ctx, false
+	}
+}
+
+// loginResponse writes the session of a first factor login, or the mfa token if the user must pass MFA.
+func loginResponse(c *gin.Context, res *domain.LoginResponse) {
+	if res.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfaRequired": true,
+			"mfaToken":    res.MFAToken,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        &res.AccessToken,
+		"refreshToken": &res.RefreshToken,
+		"user":         &res.Login,
+	})
+}
+
+// clientContext returns the request context carrying the client ip and user agent,
+// sessions and the auth audit trail record them.
+func clientContext(c *gin.Context) context.Context {
+	ctx := context.WithValue(c.Request.Context(), domain.ClientIPKey, c.ClientIP())
+	return context.WithValue(ctx, domain.UserAgentKey, c.Request.UserAgent())
+}
+
+// jwksHandler publishes the public keys tokens are verified with as a JSON Web Key Set.
+func jwksHandler(keys *jwtutils.KeySet, l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		set, err := keys.JWKS()
+		if err != nil {
+			l.Error("unable to encode jwks", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to publish keys"})
+
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, set)
+	}
 }
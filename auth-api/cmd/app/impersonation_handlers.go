@@ -0,0 +1,43 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHandlers let admins act as a user, and users review what was done as them.
+type ImpersonationHandlers struct {
+	service service.ImpersonationService
+}
+
+// ImpersonateHandler issues an impersonation token of a specific user by ID to the authenticated admin.
+func (ih ImpersonationHandlers) ImpersonateHandler(c *gin.Context) {
+	var req domain.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, apiErr := ih.service.Impersonate(clientContext(c), claimsFromContext(c), c.Param("user_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, res)
+}
+
+// FindImpersonationsHandler lists the impersonations of the authenticated user with every request made.
+func (ih ImpersonationHandlers) FindImpersonationsHandler(c *gin.Context) {
+	impersonations, apiErr := ih.service.FindByUserID(c.Request.Context(), claimsFromContext(c).UserID)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"impersonations": impersonations})
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	authHeader      = "Authorization"
+	bearer          = "Bearer"
+	ctxKeyClaims    = "accessTokenClaims"
+	msgUnauthorized = "unauthorized"
+)
+
+var (
+	errAuthHeaderNotFound  = errors.New("authorization header not found")
+	errBearerTokenNotFound = errors.New("bearer token not found in auth header")
+	errNotAccessToken      = errors.New("token is not an access token")
+	errNotUserToken        = errors.New("token is not issued to a user")
+)
+
+// impersonationRoutes are the routes besides reads impersonation tokens may call,
+// an impersonating admin can't change the credentials, sessions or keys of the impersonated user.
+var impersonationRoutes = map[string]bool{"POST:/logout": true}
+
+// requireAccessToken is a Gin middleware that authenticates requests by the bearer access token
+// in the "Authorization" header, revoked tokens are rejected as well.
+// On success the token claims are set in the Gin context, otherwise it responds with 401 and aborts.
+// Requests with impersonation tokens are limited to reads and logout, and recorded with recorder.
+func requireAccessToken(recorder impersonation.Recorder, l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := accessTokenClaims(c)
+		if err != nil {
+			l.Warn("unable to authenticate access token", "err", err.Error())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msgUnauthorized})
+
+			return
+		}
+
+		c.Set(ctxKeyClaims, claims)
+
+		if claims.Act == nil {
+			c.Next()
+			return
+		}
+
+		routeName := fmt.Sprintf("%s:%s", c.Request.Method, c.FullPath())
+		if c.Request.Method != http.MethodGet && !impersonationRoutes[routeName] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+		} else {
+			c.Next()
+		}
+
+		recorder.Record(c.Request.Context(), impersonation.Request{
+			ImpersonationID: claims.SessionID,
+			Method:          c.Request.Method,
+			Path:            c.Request.URL.Path,
+			Status:          c.Writer.Status(),
+			IP:              c.ClientIP(),
+		})
+	}
+}
+
+// requireRole is a Gin middleware that must run after requireAccessToken,
+// it responds with 403 and aborts unless the authenticated user has one of the given roles.
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := claimsFromContext(c)
+
+		for _, role := range roles {
+			if claims != nil && claims.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this resource"})
+	}
+}
+
+// claimsFromContext returns the access token claims set by requireAccessToken, or nil if there are none.
+func claimsFromContext(c *gin.Context) *domain.AccessTokenClaims {
+	v, ok := c.Get(ctxKeyClaims)
+	if !ok {
+		return nil
+	}
+
+	claims, ok := v.(*domain.AccessTokenClaims)
+	if !ok {
+		return nil
+	}
+
+	return claims
+}
+
+// accessTokenClaims extracts the bearer token from the "Authorization" header, validates it
+// and returns its claims, only access tokens issued to users are accepted.
+func accessTokenClaims(c *gin.Context) (*domain.AccessTokenClaims, error) {
+	header := c.GetHeader(authHeader)
+	if header == "" {
+		return nil, errAuthHeaderNotFound
+	}
+
+	var tokenStr string
+	if _, err := fmt.Sscanf(header, bearer+" %s", &tokenStr); err != nil {
+		return nil, errBearerTokenNotFound
+	}
+
+	token, err := jwtutils.ParseAndValidateToken(tokenStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errNotAccessToken
+	}
+
+	claims, err := domain.ClaimsFromMap(mapClaims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	if claims.TokenType != domain.TokenTypeAccess {
+		return nil, errNotAccessToken
+	}
+
+	// auth-api routes act on the user of the token, service client tokens have none
+	if claims.UserID == "" {
+		return nil, errNotUserToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/ashtishad/instabid-wallet/lib/apikey"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	introspectRouteName = http.MethodPost + ":" + jwtutils.IntrospectPath
+	authorizeRouteName  = http.MethodPost + ":" + jwtutils.AuthorizePath
+)
+
+type OAuthHandlers struct {
+	service  service.OAuthService
+	apiKeys  service.APIKeyService
+	verifier *verifier.Verifier
+}
+
+// TokenHandler is the OAuth 2.0 token endpoint, it supports the client credentials grant.
+// Clients authenticate with HTTP Basic or with client_id and client_secret form parameters,
+// responses and errors follow RFC 6749 sections 5.1 and 5.2.
+func (oh OAuthHandlers) TokenHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.ContentType() != gin.MIMEPOSTForm {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidRequest,
+			Description: "request must be form encoded"})
+
+		return
+	}
+
+	if c.PostForm("grant_type") != domain.GrantTypeClientCredentials {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrUnsupportedGrantType})
+		return
+	}
+
+	req, usedBasic, ok := clientCredentials(c)
+	if !ok {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidRequest,
+			Description: "client must authenticate with exactly one method"})
+
+		return
+	}
+
+	res, oauthErr := oh.service.ClientCredentials(c.Request.Context(), req)
+	if oauthErr != nil {
+		if oauthErr.Code == domain.OAuthErrInvalidClient && usedBasic {
+			c.Header("WWW-Authenticate", `Basic realm="auth-api"`)
+		}
+
+		oauthError(c, oauthErr)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// IntrospectHandler is the token introspection endpoint, RFC 7662. Callers authenticate as a service client
+// with the POST:/introspect scope and send the token in the form body. Invalid, expired, revoked and
+// non-access tokens are all described as inactive. API keys are introspected for the client_ip form parameter,
+// the address the key was used from.
+func (oh OAuthHandlers) IntrospectHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if !oh.authenticateCaller(c, introspectRouteName) {
+		return
+	}
+
+	tokenStr := c.PostForm("token")
+	if tokenStr == "" {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidRequest,
+			Description: "token is required"})
+
+		return
+	}
+
+	if c.PostForm("token_type_hint") == apikey.TokenTypeHint || apikey.IsKey(tokenStr) {
+		c.JSON(http.StatusOK, oh.apiKeys.Introspect(c.Request.Context(), tokenStr, c.PostForm("client_ip")))
+		return
+	}
+
+	claims, err := oh.verifier.Authenticate(tokenStr)
+	if err != nil {
+		c.JSON(http.StatusOK, jwtutils.Introspection{Active: false})
+		return
+	}
+
+	c.JSON(http.StatusOK, jwtutils.NewIntrospection(claims))
+}
+
+// AuthorizeHandler decides whether an access token may call a route ("METHOD:/full/path"),
+// with the same rules services apply locally: client scopes, role permissions and the path user id.
+// Callers authenticate as a service client with the POST:/authorize scope.
+func (oh OAuthHandlers) AuthorizeHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if !oh.authenticateCaller(c, authorizeRouteName) {
+		return
+	}
+
+	tokenStr, routeName := c.PostForm("token"), c.PostForm("route")
+	if tokenStr == "" || routeName == "" {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidRequest,
+			Description: "token and route are required"})
+
+		return
+	}
+
+	claims, err := oh.verifier.Verify(tokenStr, routeName, c.PostForm("user_id"))
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"allowed": true, "token": jwtutils.NewIntrospection(claims)})
+	case errors.Is(err, verifier.ErrForbidden) || errors.Is(err, verifier.ErrTypeAssertion):
+		c.JSON(http.StatusOK, gin.H{"allowed": false, "reason": "forbidden"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"allowed": false, "reason": "inactive_token"})
+	}
+}
+
+func (oh OAuthHandlers) CreateClientHandler(c *gin.Context) {
+	var req domain.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, secret, apiErr := oh.service.CreateClient(c.Request.Context(), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"client": client, "clientSecret": secret})
+}
+
+func (oh OAuthHandlers) FindClientsHandler(c *gin.Context) {
+	clients, apiErr := oh.service.FindClients(c.Request.Context())
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+func (oh OAuthHandlers) DisableClientHandler(c *gin.Context) {
+	if apiErr := oh.service.DisableClient(c.Request.Context(), c.Param("client_id")); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// clientCredentials reads the client id and secret from the Basic authorization header or the form,
+// header values are form encoded as RFC 6749 section 2.3.1 requires. It returns false if both or neither are used.
+func clientCredentials(c *gin.Context) (domain.ClientCredentialsRequest, bool, bool) {
+	req := domain.ClientCredentialsRequest{Scope: c.PostForm("scope")}
+	formID, formSecret := c.PostForm("client_id"), c.PostForm("client_secret")
+
+	basicID, basicSecret, usedBasic := c.Request.BasicAuth()
+	if usedBasic {
+		if formSecret != "" {
+			return req, true, false
+		}
+
+		id, idErr := url.QueryUnescape(basicID)
+		secret, secretErr := url.QueryUnescape(basicSecret)
+
+		if idErr != nil || secretErr != nil {
+			return req, true, false
+		}
+
+		req.ClientID, req.ClientSecret = id, secret
+
+		return req, true, true
+	}
+
+	if formID == "" || formSecret == "" {
+		return req, false, false
+	}
+
+	req.ClientID, req.ClientSecret = formID, formSecret
+
+	return req, false, true
+}
+
+// authenticateCaller authenticates the calling service client by its form encoded credentials
+// and checks its scopes allow routeName, otherwise it responds with the OAuth error and returns false.
+func (oh OAuthHandlers) authenticateCaller(c *gin.Context, routeName string) bool {
+	if c.ContentType() != gin.MIMEPOSTForm {
+		oauthError(c, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidRequest,
+			Description: "request must be form encoded"})
+
+		return false
+	}
+
+	req, usedBasic, ok := clientCredentials(c)
+	if !ok {
+		c.Header("WWW-Authenticate", `Basic realm="auth-api"`)
+		oauthError(c, &domain.OAuthError{Status: http.StatusUnauthorized, Code: domain.OAuthErrInvalidClient,
+			Description: "client must authenticate with exactly one method"})
+
+		return false
+	}
+
+	client, oauthErr := oh.service.AuthenticateClient(c.Request.Context(), req.ClientID, req.ClientSecret)
+	if oauthErr != nil {
+		if oauthErr.Code == domain.OAuthErrInvalidClient && usedBasic {
+			c.Header("WWW-Authenticate", `Basic realm="auth-api"`)
+		}
+
+		oauthError(c, oauthErr)
+
+		return false
+	}
+
+	if !policy.ScopesAuthorize(client.Scopes, routeName) {
+		oauthError(c, &domain.OAuthError{Status: http.StatusForbidden, Code: domain.OAuthErrInsufficientScope,
+			Description: "client is not allowed to call " + routeName})
+
+		return false
+	}
+
+	return true
+}
+
+func oauthError(c *gin.Context, oauthErr *domain.OAuthError) {
+	c.JSON(oauthErr.Status, oauthErr)
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/auth-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	pathParamRole         = "role"
+	pathParamPermissionID = "permission_id"
+	queryParamAnyUser     = "anyUser"
+)
+
+// RBACHandlers let admins manage roles, permissions and which roles are granted which permissions.
+type RBACHandlers struct {
+	service service.RBACService
+}
+
+func (rh RBACHandlers) FindRolesHandler(c *gin.Context) {
+	roles, apiErr := rh.service.FindRoles(c.Request.Context())
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (rh RBACHandlers) CreateRoleHandler(c *gin.Context) {
+	var req domain.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if apiErr := rh.service.CreateRole(c.Request.Context(), req); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"role": domain.Role{Name: req.Name, Description: req.Description,
+		Permissions: make([]string, 0)}})
+}
+
+func (rh RBACHandlers) DeleteRoleHandler(c *gin.Context) {
+	if apiErr := rh.service.DeleteRole(c.Request.Context(), c.Param(pathParamRole)); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (rh RBACHandlers) FindPermissionsHandler(c *gin.Context) {
+	permissions, apiErr := rh.service.FindPermissions(c.Request.Context())
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (rh RBACHandlers) CreatePermissionHandler(c *gin.Context) {
+	var req domain.CreatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	permission, apiErr := rh.service.CreatePermission(c.Request.Context(), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"permission": permission})
+}
+
+func (rh RBACHandlers) DeletePermissionHandler(c *gin.Context) {
+	id, ok := permissionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if apiErr := rh.service.DeletePermission(c.Request.Context(), id); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GrantHandler grants a permission to a role, with ?anyUser=true on behalf of any user,
+// granting it again changes only whether it's granted for any user.
+func (rh RBACHandlers) GrantHandler(c *gin.Context) {
+	id, ok := permissionIDParam(c)
+	if !ok {
+		return
+	}
+
+	anyUser := false
+	if v := c.Query(queryParamAnyUser); v != "" {
+		var err error
+		if anyUser, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "anyUser must be true or false"})
+			return
+		}
+	}
+
+	if apiErr := rh.service.Grant(c.Request.Context(), c.Param(pathParamRole), id, anyUser); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (rh RBACHandlers) RevokeHandler(c *gin.Context) {
+	id, ok := permissionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if apiErr := rh.service.Revoke(c.Request.Context(), c.Param(pathParamRole), id); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// permissionIDParam parses the permission id path parameter, responding 400 if it isn't a number.
+func permissionIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(pathParamPermissionID), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "permission id must be a number"})
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+const MaxAPIKeysPerUser = 25
+
+// APIKey is a long-lived key a merchant authenticates with instead of an access token.
+// Its requests are limited to its scopes, route patterns like "POST:/users/*", and if set to the allowed IPs.
+type APIKey struct {
+	KeyID      string     `json:"keyId"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowedIps"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UserID     string     `json:"-"`
+	KeyHash    string     `json:"-"`
+	Revoked    bool       `json:"-"`
+}
+
+type CreateAPIKeyRequest struct {
+	Label      string   `json:"label" binding:"required"`
+	Scopes     []string `json:"scopes" binding:"required"`
+	AllowedIPs []string `json:"allowedIps"`
+}
+
+type UpdateAPIKeyRequest struct {
+	Label string `json:"label" binding:"required"`
+}
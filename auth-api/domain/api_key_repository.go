@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// APIKeyRepository stores the API keys of merchants, with hashed keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key APIKey) (*APIKey, lib.APIError)
+	FindByUserID(ctx context.Context, userID string) ([]APIKey, lib.APIError)
+	FindByPrefix(ctx context.Context, prefix string) (*APIKey, *Login, lib.APIError)
+	UpdateLabel(ctx context.Context, userID string, keyID string, label string) (*APIKey, lib.APIError)
+	Revoke(ctx context.Context, userID string, keyID string) lib.APIError
+	Touch(ctx context.Context, keyID string) lib.APIError
+}
+
+type APIKeyRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewAPIKeyRepoDB(db *sql.DB, l *slog.Logger) *APIKeyRepoDB {
+	return &APIKeyRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Create stores a key unless the user already has MaxAPIKeysPerUser active keys, then it's a 409.
+// The user row is locked while the keys are counted, so concurrent creations can't exceed the limit.
+func (d *APIKeyRepoDB) Create(ctx context.Context, key APIKey) (*APIKey, lib.APIError) {
+	sqlLockUser := `SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE`
+	sqlCount := `SELECT count(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL`
+	sqlInsert := `INSERT INTO api_keys (user_id, label, prefix, key_hash, scopes, allowed_ips)
+				  VALUES ($1, $2, $3, $4, $5, $6) RETURNING key_id, created_at`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var active int
+	if _, err = tx.ExecContext(ctx, sqlLockUser, key.UserID); err == nil {
+		err = tx.QueryRowContext(ctx, sqlCount, key.UserID).Scan(&active)
+	}
+
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to count api keys", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if active >= MaxAPIKeysPerUser {
+		err = errors.New("api key limit reached")
+		return nil, lib.ConflictError(fmt.Sprintf("at most %d api keys can be active", MaxAPIKeysPerUser))
+	}
+
+	err = tx.QueryRowContext(ctx, sqlInsert, key.UserID, key.Label, key.Prefix, key.KeyHash,
+		strings.Join(key.Scopes, " "), strings.Join(key.AllowedIPs, " ")).Scan(&key.KeyID, &key.CreatedAt)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to create api key", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &key, nil
+}
+
+// FindByUserID returns the active keys of a user, newest first.
+func (d *APIKeyRepoDB) FindByUserID(ctx context.Context, userID string) ([]APIKey, lib.APIError) {
+	sqlFind := `SELECT key_id, user_id, label, prefix, key_hash, scopes, allowed_ips, last_used_at,
+					revoked_at IS NOT NULL, created_at
+				FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`
+
+	rows, err := d.db.QueryContext(ctx, sqlFind, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query api keys", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+
+	for rows.Next() {
+		var key *APIKey
+		if key, err = scanAPIKey(rows); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return keys, nil
+}
+
+// FindByPrefix returns a key, revoked ones too, with its owner, 404 if there is no such key.
+func (d *APIKeyRepoDB) FindByPrefix(ctx context.Context, prefix string) (*APIKey, *Login, lib.APIError) {
+	sqlFind := `SELECT k.key_id, k.user_id, k.label, k.prefix, k.key_hash, k.scopes, k.allowed_ips, k.last_used_at,
+					k.revoked_at IS NOT NULL, k.created_at, u.username, u.email, u.role, u.status
+				FROM api_keys k JOIN users u ON u.user_id = k.user_id WHERE k.prefix = $1`
+
+	var (
+		key                APIKey
+		login              Login
+		scopes, allowedIPs string
+	)
+
+	err := d.db.QueryRowContext(ctx, sqlFind, prefix).Scan(&key.KeyID, &key.UserID, &key.Label, &key.Prefix,
+		&key.KeyHash, &scopes, &allowedIPs, &key.LastUsedAt, &key.Revoked, &key.CreatedAt,
+		&login.Username, &login.Email, &login.Role, &login.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, lib.NotFoundError("api key not found")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query api key", "err", err.Error())
+
+		return nil, nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	key.Scopes, key.AllowedIPs = strings.Fields(scopes), strings.Fields(allowedIPs)
+	login.UserID = key.UserID
+
+	return &key, &login, nil
+}
+
+// UpdateLabel relabels an active key of the user, 404 if the user has no such key.
+func (d *APIKeyRepoDB) UpdateLabel(ctx context.Context, userID string, keyID string,
+	label string) (*APIKey, lib.APIError) {
+	sqlUpdate := `UPDATE api_keys SET label = $3 WHERE key_id::text = $2 AND user_id = $1 AND revoked_at IS NULL
+				  RETURNING key_id, user_id, label, prefix, key_hash, scopes, allowed_ips, last_used_at,
+					revoked_at IS NOT NULL, created_at`
+
+	key, err := scanAPIKey(d.db.QueryRowContext(ctx, sqlUpdate, userID, keyID, label))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError(fmt.Sprintf("api key %s not found", keyID))
+		}
+
+		d.l.ErrorContext(ctx, "unable to update api key", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return key, nil
+}
+
+// Revoke revokes an active key of the user, 404 if the user has no such key.
+func (d *APIKeyRepoDB) Revoke(ctx context.Context, userID string, keyID string) lib.APIError {
+	sqlRevoke := `UPDATE api_keys SET revoked_at = now()
+				  WHERE key_id::text = $2 AND user_id = $1 AND revoked_at IS NULL`
+
+	res, err := d.db.ExecContext(ctx, sqlRevoke, userID, keyID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke api key", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.NotFoundError(fmt.Sprintf("api key %s not found", keyID))
+	}
+
+	return nil
+}
+
+// Touch records that a key was used, at most once a minute to keep busy keys from writing on every request.
+func (d *APIKeyRepoDB) Touch(ctx context.Context, keyID string) lib.APIError {
+	sqlTouch := `UPDATE api_keys SET last_used_at = now()
+				 WHERE key_id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+
+	if _, err := d.db.ExecContext(ctx, sqlTouch, keyID); err != nil {
+		d.l.ErrorContext(ctx, "unable to update api key last use", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+
+	var scopes, allowedIPs string
+
+	err := row.Scan(&key.KeyID, &key.UserID, &key.Label, &key.Prefix, &key.KeyHash, &scopes, &allowedIPs,
+		&key.LastUsedAt, &key.Revoked, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan api key: %w", err)
+	}
+
+	key.Scopes, key.AllowedIPs = strings.Fields(scopes), strings.Fields(allowedIPs)
+
+	return &key, nil
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const (
+	AuthEventLoginSucceeded = "login_succeeded"
+	AuthEventLoginFailed    = "login_failed"
+	AuthEventMFAChallenged  = "mfa_challenged"
+	AuthEventMFAFailed      = "mfa_failed"
+
+	AuthReasonPassword           = "password"
+	AuthReasonMagicLink          = "magic_link"
+	AuthReasonMFACode            = "mfa_code"
+	AuthReasonRecoveryCode       = "recovery_code"
+	AuthReasonInvalidCredentials = "invalid_credentials"
+	AuthReasonLockedOut          = "locked_out"
+	AuthReasonInvalidCode        = "invalid_code"
+	AuthReasonTooManyAttempts    = "too_many_attempts"
+)
+
+// AuthEvent is an entry of the authentication audit trail, UserID is empty if the user is unknown,
+// Identifier is the email or username the user identified with.
+type AuthEvent struct {
+	UserID     string
+	Identifier string
+	Event      string
+	Reason     string
+	Client     ClientInfo
+}
+
+type AuthEventRepository interface {
+	Save(ctx context.Context, e AuthEvent) lib.APIError
+}
+
+type AuthEventRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewAuthEventRepoDB(db *sql.DB, l *slog.Logger) *AuthEventRepoDB {
+	return &AuthEventRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+func (d *AuthEventRepoDB) Save(ctx context.Context, e AuthEvent) lib.APIError {
+	sqlInsert := `INSERT INTO auth_events (user_id, identifier, event, reason, ip, user_agent)
+				  VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6)`
+
+	_, err := d.db.ExecContext(ctx, sqlInsert, e.UserID, e.Identifier, e.Event, e.Reason, e.Client.IP,
+		e.Client.UserAgent)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to save auth event", "err", err.Error(), "event", e.Event)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
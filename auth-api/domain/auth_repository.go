@@ -6,13 +6,33 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 
 	"github.com/ashtishad/instabid-wallet/lib"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/ashtishad/instabid-wallet/lib/hashpass"
 )
 
+// dummyPasswordHash is compared against when no user is found, made by the hasher passwords are hashed with.
+var dummyPasswordHash string
+
+// init fails hard without a dummy hash, logins of unknown users would otherwise answer faster than others.
+func init() {
+	hash, err := hashpass.Default.Hash("dummy-password")
+	if err != nil {
+		panic(fmt.Sprintf("unable to hash the dummy password: %v", err))
+	}
+
+	dummyPasswordHash = hash
+}
+
 type AuthRepository interface {
 	FindByCredential(ctx context.Context, req LoginRequest) (*Login, lib.APIError)
+	FindByIdentity(ctx context.Context, req LoginRequest) (*Login, lib.APIError)
+	FindByUserID(ctx context.Context, userID string) (*Login, lib.APIError)
+	UpdatePassword(ctx context.Context, userID string, hashedPass string, keepHistory int) lib.APIError
+	ResetPassword(ctx context.Context, userID string, tokenHash string, hashedPass string,
+		keepHistory int) lib.APIError
+	FindPasswordHashes(ctx context.Context, userID string, limit int) ([]string, lib.APIError)
 }
 
 type AuthRepoDB struct {
@@ -27,7 +47,240 @@ func NewAuthRepoDB(db *sql.DB, l *slog.Logger) *AuthRepoDB {
 	}
 }
 
+// FindByCredential finds a user by email or username and checks the password.
+// Unknown users, deleted users and wrong passwords all return 401 "invalid credentials", for unknown and deleted
+// users a password is compared against a dummy hash, so response times don't reveal which accounts exist either.
+// A matched password hashed with an outdated algorithm or parameters is rehashed and stored.
+// Users an admin deactivated get 401 "account is deactivated" once their password matched.
 func (d *AuthRepoDB) FindByCredential(ctx context.Context, req LoginRequest) (*Login, lib.APIError) {
+	l, hashedPassDB, apiErr := d.findByIdentity(ctx, req)
+	if apiErr == nil && l.Status == StatusDeleted {
+		d.l.InfoContext(ctx, "login attempt of deleted user", "userId", l.UserID)
+		apiErr = lib.NotFoundError("user is deleted")
+	}
+
+	if apiErr != nil {
+		if apiErr.Code() != http.StatusNotFound {
+			return nil, apiErr
+		}
+
+		_, _ = hashpass.Verify(dummyPasswordHash, req.Password)
+
+		return nil, lib.UnauthorizedError(ErrInvalidCredentials)
+	}
+
+	needsRehash, err := hashpass.Verify(string(hashedPassDB), req.Password)
+	if err != nil {
+		d.l.InfoContext(ctx, "unable to match hashed pass", "err", err.Error())
+		return nil, lib.UnauthorizedError(ErrInvalidCredentials)
+	}
+
+	if needsRehash {
+		d.rehash(ctx, l.UserID, string(hashedPassDB), req.Password)
+	}
+
+	if l.Status == StatusInactive {
+		return nil, lib.UnauthorizedError(ErrAccountInactive)
+	}
+
+	return l, nil
+}
+
+// rehash replaces the outdated hash of a verified password with a fresh one, unless the password was changed
+// meanwhile. Failures are only logged, the login succeeds regardless and the upgrade is retried on the next one.
+func (d *AuthRepoDB) rehash(ctx context.Context, userID string, oldHash string, password string) {
+	sqlRehash := `UPDATE users SET hashed_pass = $1 WHERE user_id = $2 AND hashed_pass = $3`
+
+	hashedPass, err := hashpass.Default.Hash(password)
+	if err != nil {
+		d.l.WarnContext(ctx, lib.ErrHashingPassword, "err", err.Error(), "userId", userID)
+		return
+	}
+
+	if _, err = d.db.ExecContext(ctx, sqlRehash, hashedPass, userID, oldHash); err != nil {
+		d.l.WarnContext(ctx, "unable to upgrade password hash", "err", err.Error(), "userId", userID)
+	}
+}
+
+// FindByIdentity finds a user by email or username like FindByCredential, without checking the password.
+// Returns 404 if the user is not found, 500 if other error occurs.
+func (d *AuthRepoDB) FindByIdentity(ctx context.Context, req LoginRequest) (*Login, lib.APIError) {
+	l, _, apiErr := d.findByIdentity(ctx, req)
+	return l, apiErr
+}
+
+// FindByUserID finds a user by uuid, returns 404 if the user is not found.
+func (d *AuthRepoDB) FindByUserID(ctx context.Context, userID string) (*Login, lib.APIError) {
+	sqlFindByUserID := `select user_id, username, email, role, status, email_verified_at is not null
+						from users where user_id = $1`
+
+	var l Login
+
+	err := d.db.QueryRowContext(ctx, sqlFindByUserID, userID).Scan(&l.UserID, &l.Username, &l.Email, &l.Role, &l.Status,
+		&l.EmailVerified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by uuid")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query user by uuid", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &l, nil
+}
+
+// UpdatePassword replaces the hashed password of a user by uuid, returns 404 if the user is not found.
+// The replaced hash is kept in the password history, which is pruned to the keepHistory latest hashes,
+// none are kept if keepHistory isn't positive.
+func (d *AuthRepoDB) UpdatePassword(ctx context.Context, userID string, hashedPass string,
+	keepHistory int) lib.APIError {
+	return d.updatePassword(ctx, userID, "", hashedPass, keepHistory)
+}
+
+// ResetPassword consumes a password reset token of the user and replaces its password like UpdatePassword,
+// in the same transaction, so the token is only spent along with the password change.
+// Returns 400 if the token is unknown, used, expired or of another user.
+func (d *AuthRepoDB) ResetPassword(ctx context.Context, userID string, tokenHash string, hashedPass string,
+	keepHistory int) lib.APIError {
+	return d.updatePassword(ctx, userID, tokenHash, hashedPass, keepHistory)
+}
+
+// updatePassword replaces the hashed password of a user, consuming the password reset token first
+// unless tokenHash is empty.
+func (d *AuthRepoDB) updatePassword(ctx context.Context, userID string, tokenHash string, hashedPass string,
+	keepHistory int) lib.APIError {
+	sqlSaveHistory := `INSERT INTO password_history (user_id, hashed_pass)
+					   SELECT user_id, hashed_pass FROM users WHERE user_id = $1`
+	sqlUpdatePassword := `UPDATE users SET hashed_pass = $1, updated_at = now() WHERE user_id = $2`
+	sqlPruneHistory := `DELETE FROM password_history WHERE user_id = $1 AND id NOT IN
+						(SELECT id FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	if tokenHash != "" {
+		if apiErr := d.consumeResetToken(ctx, tx, tokenHash, userID); apiErr != nil {
+			err = apiErr
+			return apiErr
+		}
+	}
+
+	if keepHistory > 0 {
+		if _, err = tx.ExecContext(ctx, sqlSaveHistory, userID); err != nil {
+			d.l.ErrorContext(ctx, "unable to save password history", "err", err.Error())
+			return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, sqlUpdatePassword, hashedPass, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to update password", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to get rows affected", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if ra != 1 {
+		err = sql.ErrNoRows
+		return lib.NotFoundError("user not found by uuid")
+	}
+
+	// without a history to keep, PASSWORD_HISTORY_SIZE of 0, the hashes left from before are dropped
+	if _, err = tx.ExecContext(ctx, sqlPruneHistory, userID, max(keepHistory, 0)); err != nil {
+		d.l.ErrorContext(ctx, "unable to prune password history", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// consumeResetToken marks the unused and unexpired password reset token of the user as used in tx,
+// returns 400 if there's none.
+func (d *AuthRepoDB) consumeResetToken(ctx context.Context, tx *sql.Tx, tokenHash string,
+	userID string) lib.APIError {
+	sqlConsumeToken := `UPDATE one_time_tokens SET used_at = now()
+						WHERE token_hash = $1 AND purpose = $2 AND user_id = $3 AND used_at IS NULL
+						  AND expires_at > now()`
+
+	res, err := tx.ExecContext(ctx, sqlConsumeToken, tokenHash, TokenPurposePasswordReset, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to consume password reset token", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		return lib.BadRequestError("token is invalid or has expired")
+	}
+
+	return nil
+}
+
+// FindPasswordHashes returns the current hashed password of a user followed by up to limit-1 previous ones
+// from the password history, newest first. Returns 404 if the user is not found.
+func (d *AuthRepoDB) FindPasswordHashes(ctx context.Context, userID string, limit int) ([]string, lib.APIError) {
+	sqlFindCurrent := `SELECT hashed_pass FROM users WHERE user_id = $1`
+	sqlFindHistory := `SELECT hashed_pass FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2`
+
+	var current string
+	if err := d.db.QueryRowContext(ctx, sqlFindCurrent, userID).Scan(&current); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by uuid")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query hashed password", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	hashes := []string{current}
+	if limit <= 1 {
+		return hashes, nil
+	}
+
+	rows, err := d.db.QueryContext(ctx, sqlFindHistory, userID, limit-1)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query password history", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			d.l.ErrorContext(ctx, "unable to scan password history", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, "unable to iterate password history", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return hashes, nil
+}
+
+// findByIdentity queries a user and its hashed password by the credential field set in the context,
+// one of email or username.
+func (d *AuthRepoDB) findByIdentity(ctx context.Context, req LoginRequest) (*Login, []byte, lib.APIError) {
 	var sqlQuery, value, dbField string
 
 	// prepare query according to credential field, one of email or username
@@ -35,34 +288,31 @@ func (d *AuthRepoDB) FindByCredential(ctx context.Context, req LoginRequest) (*L
 	case UserCredentialEmail:
 		value = req.Email
 		dbField = UserCredentialEmail
-		sqlQuery = `select user_id, username, email, hashed_pass, role, status from users where email = $1`
+		sqlQuery = `select user_id, username, email, hashed_pass, role, status, email_verified_at is not null
+					from users where email = $1`
 	case UserCredentialUsername:
 		value = req.Username
 		dbField = UserCredentialUsername
-		sqlQuery = `select user_id, username, email, hashed_pass, role, status from users where username = $1`
+		sqlQuery = `select user_id, username, email, hashed_pass, role, status, email_verified_at is not null
+					from users where username = $1`
 	default:
-		return nil, lib.BadRequestError("credential field must be one of email or username")
+		return nil, nil, lib.BadRequestError("credential field must be one of email or username")
 	}
 
 	var l Login
 	var hashedPassDB []byte
 	err := d.db.QueryRowContext(ctx, sqlQuery, value).Scan(&l.UserID,
-		&l.Username, &l.Email, &hashedPassDB, &l.Role, &l.Status)
+		&l.Username, &l.Email, &hashedPassDB, &l.Role, &l.Status, &l.EmailVerified)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, lib.NotFoundError(fmt.Sprintf("user with %s:%s not found", dbField, value))
+			return nil, nil, lib.NotFoundError(fmt.Sprintf("user with %s:%s not found", dbField, value))
 		}
 
 		d.l.ErrorContext(ctx, fmt.Sprintf("unable to query user by %s", dbField), "err", err.Error())
 
-		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
-	}
-
-	if err = bcrypt.CompareHashAndPassword(hashedPassDB, []byte(req.Password)); err != nil {
-		d.l.ErrorContext(ctx, "unable to match hashed pass", "err", err.Error())
-		return nil, lib.BadRequestError("input password is wrong")
+		return nil, nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
 	}
 
-	return &l, nil
+	return &l, hashedPassDB, nil
 }
@@ -6,12 +6,45 @@ const (
 	UserCredentialEmail    = "email"
 	UserCredentialUsername = "username"
 
-	AccessTokenDuration = time.Hour
-	TokenTypeAccess     = "access_token"
+	AccessTokenDuration       = time.Hour
+	ClientAccessTokenDuration = 15 * time.Minute
+	TokenTypeAccess           = "access_token"
+	ClientIDSize              = 12
+	ClientSecretSize          = 32
+
+	RefreshTokenDuration = 30 * 24 * time.Hour
+	RefreshTokenSize     = 32
+	TokenTypeRefresh     = "refresh_token"
+
+	PasswordResetTokenDuration = 30 * time.Minute
+	OneTimeTokenSize           = 32
+	TokenPurposePasswordReset  = "password_reset"
+	MagicLinkTokenDuration     = 10 * time.Minute
+	TokenPurposeMagicLink      = "magic_link"
+
+	MFAChallengeDuration  = 5 * time.Minute
+	TokenTypeMFAChallenge = "mfa_challenge"
+	MFAIssuer             = "Instabid Wallet"
+	RecoveryCodeCount     = 10
+	RecoveryCodeSize      = 10
+
+	ImpersonationTokenDuration = 15 * time.Minute
+	MaxImpersonationReasonLen  = 512
+
+	RoleAdmin      = "admin"
+	RoleMerchant   = "merchant"
+	StatusActive   = "active"
+	StatusInactive = "inactive"
+	StatusDeleted  = "deleted"
+
+	ErrInvalidCredentials = "invalid credentials"
+	ErrAccountInactive    = "account is deactivated"
 )
 
 type ContextKey string
 
 const (
 	UserCredentialKey ContextKey = "credential"
+	ClientIPKey       ContextKey = "clientIP"
+	UserAgentKey      ContextKey = "userAgent"
 )
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ImpersonationResponse carries an impersonation token, there is no refresh token, it can't outlive its expiry.
+type ImpersonationResponse struct {
+	AccessToken     string    `json:"accessToken"`
+	ImpersonationID string    `json:"impersonationId"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+	Login
+}
+
+// Impersonation is an admin acting as a user with an impersonation token, with every request made with it.
+// AdminUsername is empty if the admin was deleted since.
+type Impersonation struct {
+	ID            string                `json:"id"`
+	AdminID       string                `json:"adminId"`
+	AdminUsername string                `json:"adminUsername"`
+	UserID        string                `json:"userId"`
+	Reason        string                `json:"reason"`
+	IP            string                `json:"ip"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	ExpiresAt     time.Time             `json:"expiresAt"`
+	Requests      []ImpersonatedRequest `json:"requests"`
+}
+
+type ImpersonatedRequest struct {
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
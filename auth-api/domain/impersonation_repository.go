@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+type ImpersonationRepository interface {
+	Create(ctx context.Context, imp Impersonation) (string, lib.APIError)
+	FindByUserID(ctx context.Context, userID string) ([]Impersonation, lib.APIError)
+}
+
+type ImpersonationRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewImpersonationRepoDB(db *sql.DB, l *slog.Logger) *ImpersonationRepoDB {
+	return &ImpersonationRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Create records the start of an impersonation and returns its uuid, which is the session id of its token.
+func (d *ImpersonationRepoDB) Create(ctx context.Context, imp Impersonation) (string, lib.APIError) {
+	sqlInsert := `INSERT INTO impersonations (admin_id, user_id, reason, ip, expires_at)
+				  VALUES ($1, $2, $3, $4, $5) RETURNING impersonation_id`
+
+	var id string
+
+	err := d.db.QueryRowContext(ctx, sqlInsert, imp.AdminID, imp.UserID, imp.Reason, imp.IP,
+		imp.ExpiresAt).Scan(&id)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to save impersonation", "err", err.Error())
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return id, nil
+}
+
+// FindByUserID returns every impersonation of a user with the requests made, newest first.
+func (d *ImpersonationRepoDB) FindByUserID(ctx context.Context, userID string) ([]Impersonation, lib.APIError) {
+	sqlFind := `SELECT i.impersonation_id, COALESCE(i.admin_id::text, ''), COALESCE(a.username, ''), i.user_id,
+					   i.reason, i.ip, i.created_at, i.expires_at, r.method, r.path, r.status, r.created_at
+				FROM impersonations i
+				LEFT JOIN users a ON a.user_id = i.admin_id
+				LEFT JOIN impersonation_requests r ON r.impersonation_id = i.impersonation_id
+				WHERE i.user_id = $1
+				ORDER BY i.created_at DESC, i.impersonation_id, r.id`
+
+	rows, err := d.db.QueryContext(ctx, sqlFind, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query impersonations", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	impersonations := make([]Impersonation, 0)
+
+	for rows.Next() {
+		var (
+			imp          Impersonation
+			method, path sql.NullString
+			status       sql.NullInt64
+			requestedAt  sql.NullTime
+		)
+
+		if err = rows.Scan(&imp.ID, &imp.AdminID, &imp.AdminUsername, &imp.UserID, &imp.Reason, &imp.IP,
+			&imp.CreatedAt, &imp.ExpiresAt, &method, &path, &status, &requestedAt); err != nil {
+			d.l.ErrorContext(ctx, "unable to scan impersonation", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if n := len(impersonations); n == 0 || impersonations[n-1].ID != imp.ID {
+			imp.Requests = make([]ImpersonatedRequest, 0)
+			impersonations = append(impersonations, imp)
+		}
+
+		if method.Valid {
+			last := &impersonations[len(impersonations)-1]
+			last.Requests = append(last.Requests, ImpersonatedRequest{Method: method.String, Path: path.String,
+				Status: int(status.Int64), CreatedAt: requestedAt.Time})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, "unable to iterate impersonations", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return impersonations, nil
+}
@@ -1,8 +1,11 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -12,6 +15,10 @@ type Login struct {
 	Email    string `json:"email"`
 	Role     string `json:"role"`
 	Status   string `json:"status"`
+
+	// EmailVerified is whether the user confirmed owning its email, which active users may not have,
+	// e.g. imported ones.
+	EmailVerified bool `json:"-"`
 }
 
 type AccessTokenClaims struct {
@@ -21,30 +28,92 @@ type AccessTokenClaims struct {
 	Email     string
 	Role      string
 	Status    string
+	SessionID string
+	ClientID  string `json:"client_id,omitempty"` //nolint:tagliatelle // RFC 9068 claim names
+	Scope     string `json:"scope,omitempty"`
+	// Act is set on impersonation tokens, it's the admin acting as the user of the other claims.
+	Act *impersonation.Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (l Login) ClaimsForAccessToken() AccessTokenClaims {
-	expirationTime := time.Now().Add(AccessTokenDuration)
+// ClaimsForAccessToken builds access token claims for the login, identified by tokenID (jti),
+// sessionID is the refresh token family the access token was issued for.
+func (l Login) ClaimsForAccessToken(sessionID string, tokenID string) AccessTokenClaims {
+	now := time.Now()
+
+	return AccessTokenClaims{
+		TokenType: TokenTypeAccess,
+		Username:  l.Username,
+		UserID:    l.UserID,
+		Email:     l.Email,
+		Role:      l.Role,
+		Status:    l.Status,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   l.UserID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenDuration)),
+		},
+	}
+}
+
+// ClaimsForImpersonation builds the claims of a short-lived impersonation token identified by tokenID (jti),
+// it carries the claims of the impersonated user and the admin acting as them, impersonationID is its session id.
+func (l Login) ClaimsForImpersonation(adminID string, impersonationID string, tokenID string) AccessTokenClaims {
+	claims := l.ClaimsForAccessToken(impersonationID, tokenID)
+	claims.Act = &impersonation.Actor{UserID: adminID}
+	claims.ExpiresAt = jwt.NewNumericDate(claims.IssuedAt.Add(ImpersonationTokenDuration))
+
+	return claims
+}
+
+// ClaimsForMFAChallenge builds the claims of a challenge token identified by tokenID (jti),
+// it proves the password was verified and is exchanged for an access token with the second factor.
+func (l Login) ClaimsForMFAChallenge(tokenID string) AccessTokenClaims {
+	now := time.Now()
 
 	return AccessTokenClaims{
-		TokenType:        TokenTypeAccess,
-		Username:         l.Username,
-		UserID:           l.UserID,
-		Email:            l.Email,
-		Role:             l.Role,
-		Status:           l.Status,
-		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expirationTime)},
+		TokenType: TokenTypeMFAChallenge,
+		UserID:    l.UserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   l.UserID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeDuration)),
+		},
 	}
 }
 
+// ClaimsFromMap converts already validated map claims into AccessTokenClaims.
+func ClaimsFromMap(m jwt.MapClaims) (*AccessTokenClaims, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal claims: %w", err)
+	}
+
+	var claims AccessTokenClaims
+	if err = json.Unmarshal(b, &claims); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal claims: %w", err)
+	}
+
+	return &claims, nil
+}
+
 type LoginRequest struct {
 	Username string `json:"username,omitempty"`
 	Email    string `json:"email,omitempty"`
 	Password string `json:"password"`
 }
 
+// LoginResponse holds the tokens of a new session, or only MFAToken if the user has to pass a second factor first.
 type LoginResponse struct {
-	AccessToken string `json:"accessToken"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	MFAToken     string `json:"mfaToken,omitempty"`
 	Login
 }
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// LoginFailure counts the consecutive failed logins of an account identifier or a client ip.
+type LoginFailure struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+// LoginAttemptRepository tracks failed logins, shared by every auth-api instance.
+// Attempts are reserved as failures before the credentials are checked, so concurrent attempts are counted
+// one after another, and released again if they turn out not to be failed guesses.
+type LoginAttemptRepository interface {
+	Reserve(ctx context.Context, key string, resetBefore time.Time) (*LoginFailure, lib.APIError)
+	Release(ctx context.Context, keys ...string) lib.APIError
+	Lock(ctx context.Context, key string, until time.Time) lib.APIError
+	Clear(ctx context.Context, keys ...string) lib.APIError
+}
+
+type LoginAttemptRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewLoginAttemptRepoDB(db *sql.DB, l *slog.Logger) *LoginAttemptRepoDB {
+	return &LoginAttemptRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Reserve counts an attempt for key as a failure in one statement and returns the failures before it,
+// counting restarts if the last failure happened before resetBefore. Concurrent reservations of a key
+// are serialized by its row, each of them sees the ones before.
+func (d *LoginAttemptRepoDB) Reserve(ctx context.Context, key string,
+	resetBefore time.Time) (*LoginFailure, lib.APIError) {
+	sqlReserve := `INSERT INTO login_failures (key, failures, last_failed_at) VALUES ($1, 1, now())
+				   ON CONFLICT (key) DO UPDATE SET
+				   failures = CASE WHEN login_failures.last_failed_at < $2 THEN 1 ELSE login_failures.failures + 1 END,
+				   prev_failed_at = login_failures.last_failed_at,
+				   last_failed_at = now()
+				   RETURNING failures, prev_failed_at, locked_until`
+
+	var failures int
+
+	var prevFailedAt, lockedUntil sql.NullTime
+
+	err := d.db.QueryRowContext(ctx, sqlReserve, key, resetBefore).Scan(&failures, &prevFailedAt, &lockedUntil)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to reserve login attempt", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &LoginFailure{Key: key, Failures: failures - 1, LastFailedAt: prevFailedAt.Time,
+		LockedUntil: lockedUntil.Time}, nil
+}
+
+// Release takes back an attempt reserved for the keys, one that wasn't a failed guess.
+func (d *LoginAttemptRepoDB) Release(ctx context.Context, keys ...string) lib.APIError {
+	sqlRelease := `UPDATE login_failures SET failures = greatest(failures - 1, 0) WHERE key = $1`
+
+	for _, key := range keys {
+		if _, err := d.db.ExecContext(ctx, sqlRelease, key); err != nil {
+			d.l.ErrorContext(ctx, "unable to release login attempt", "err", err.Error())
+			return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+	}
+
+	return nil
+}
+
+// Lock rejects logins for key until the given time.
+func (d *LoginAttemptRepoDB) Lock(ctx context.Context, key string, until time.Time) lib.APIError {
+	sqlLock := `UPDATE login_failures SET locked_until = $2 WHERE key = $1`
+
+	if _, err := d.db.ExecContext(ctx, sqlLock, key, until); err != nil {
+		d.l.ErrorContext(ctx, "unable to lock login", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// Clear forgets the failures of the keys, lifting any lockout.
+func (d *LoginAttemptRepoDB) Clear(ctx context.Context, keys ...string) lib.APIError {
+	sqlClear := `DELETE FROM login_failures WHERE key = $1`
+
+	for _, key := range keys {
+		if _, err := d.db.ExecContext(ctx, sqlClear, key); err != nil {
+			d.l.ErrorContext(ctx, "unable to clear login failures", "err", err.Error())
+			return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+	}
+
+	return nil
+}
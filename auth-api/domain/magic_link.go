@@ -0,0 +1,9 @@
+package domain
+
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token"`
+}
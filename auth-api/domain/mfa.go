@@ -0,0 +1,27 @@
+package domain
+
+// MFA is the TOTP second factor of a user, it's enforced on login once confirmed.
+// LastUsedStep is the time step of the last accepted code, codes of it or earlier steps are rejected.
+type MFA struct {
+	UserID       string
+	Secret       string
+	Confirmed    bool
+	LastUsedStep int64
+}
+
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type ConfirmMFARequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginMFARequest completes a login with the challenge token returned by Login,
+// and either a TOTP code or one of the recovery codes.
+type LoginMFARequest struct {
+	MFAToken     string `json:"mfaToken" binding:"required"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
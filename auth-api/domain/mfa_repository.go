@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// MFARepository stores the TOTP secrets and the hashed recovery codes of users.
+type MFARepository interface {
+	SaveSecret(ctx context.Context, userID string, secret string) lib.APIError
+	FindByUserID(ctx context.Context, userID string) (*MFA, lib.APIError)
+	Confirm(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) lib.APIError
+	UseStep(ctx context.Context, userID string, step int64) lib.APIError
+	ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) lib.APIError
+	Delete(ctx context.Context, userID string) lib.APIError
+}
+
+type MFARepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewMFARepoDB(db *sql.DB, l *slog.Logger) *MFARepoDB {
+	return &MFARepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// SaveSecret stores a new unconfirmed secret for the user, replacing an earlier unconfirmed one.
+// Returns 409 if the user already has confirmed MFA, it must be reset first.
+func (d *MFARepoDB) SaveSecret(ctx context.Context, userID string, secret string) lib.APIError {
+	sqlUpsert := `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+				  ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+				  WHERE user_mfa.confirmed_at IS NULL`
+
+	res, err := d.db.ExecContext(ctx, sqlUpsert, userID, secret)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to save mfa secret", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.ConflictError("mfa is already enabled")
+	}
+
+	return nil
+}
+
+// FindByUserID returns the MFA of the user, 404 if the user never enrolled.
+func (d *MFARepoDB) FindByUserID(ctx context.Context, userID string) (*MFA, lib.APIError) {
+	sqlFind := `SELECT user_id, secret, confirmed_at IS NOT NULL, last_used_step FROM user_mfa WHERE user_id = $1`
+
+	var m MFA
+
+	err := d.db.QueryRowContext(ctx, sqlFind, userID).Scan(&m.UserID, &m.Secret, &m.Confirmed, &m.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("mfa not enrolled")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query mfa", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &m, nil
+}
+
+// Confirm enables the enrolled MFA of the user with the time step of the first valid code,
+// in the same transaction earlier recovery codes are replaced by the given ones.
+func (d *MFARepoDB) Confirm(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) lib.APIError {
+	sqlConfirm := `UPDATE user_mfa SET confirmed_at = now(), last_used_step = $2
+				   WHERE user_id = $1 AND confirmed_at IS NULL`
+	sqlDeleteCodes := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+	sqlInsertCode := `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	res, err := tx.ExecContext(ctx, sqlConfirm, userID, step)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to confirm mfa", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = errors.New("mfa not pending confirmation")
+		return lib.ConflictError("mfa is already enabled")
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlDeleteCodes, userID); err != nil {
+		d.l.ErrorContext(ctx, "unable to delete recovery codes", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	for _, h := range recoveryCodeHashes {
+		if _, err = tx.ExecContext(ctx, sqlInsertCode, userID, h); err != nil {
+			d.l.ErrorContext(ctx, "unable to save recovery code", "err", err.Error())
+			return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// UseStep records the time step of an accepted code, so the same code can't be replayed.
+// Returns 401 if a code of the same or a later step was already used.
+func (d *MFARepoDB) UseStep(ctx context.Context, userID string, step int64) lib.APIError {
+	sqlUseStep := `UPDATE user_mfa SET last_used_step = $2
+				   WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+
+	res, err := d.db.ExecContext(ctx, sqlUseStep, userID, step)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to use mfa step", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.UnauthorizedError("mfa code is invalid")
+	}
+
+	return nil
+}
+
+// ConsumeRecoveryCode atomically marks an unused recovery code of the user as used, 401 if there is none.
+func (d *MFARepoDB) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) lib.APIError {
+	sqlConsume := `UPDATE mfa_recovery_codes SET used_at = now()
+				   WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := d.db.ExecContext(ctx, sqlConsume, userID, codeHash)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to consume recovery code", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.UnauthorizedError("recovery code is invalid")
+	}
+
+	return nil
+}
+
+// Delete removes the MFA and the recovery codes of the user, 404 if the user never enrolled.
+func (d *MFARepoDB) Delete(ctx context.Context, userID string) lib.APIError {
+	sqlDeleteCodes := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+	sqlDeleteMFA := `DELETE FROM user_mfa WHERE user_id = $1`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	if _, err = tx.ExecContext(ctx, sqlDeleteCodes, userID); err != nil {
+		d.l.ErrorContext(ctx, "unable to delete recovery codes", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	res, err := tx.ExecContext(ctx, sqlDeleteMFA, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to delete mfa", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = errors.New("mfa not enrolled")
+		return lib.NotFoundError("mfa not enrolled")
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// OAuthClientRepository stores the registered service clients, with hashed secrets.
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client OAuthClient) (*OAuthClient, lib.APIError)
+	FindByID(ctx context.Context, clientID string) (*OAuthClient, lib.APIError)
+	FindAll(ctx context.Context) ([]OAuthClient, lib.APIError)
+	Disable(ctx context.Context, clientID string) lib.APIError
+}
+
+type OAuthClientRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewOAuthClientRepoDB(db *sql.DB, l *slog.Logger) *OAuthClientRepoDB {
+	return &OAuthClientRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+func (d *OAuthClientRepoDB) Create(ctx context.Context, client OAuthClient) (*OAuthClient, lib.APIError) {
+	sqlInsert := `INSERT INTO oauth_clients (client_id, name, secret_hash, scopes) VALUES ($1, $2, $3, $4)
+				  RETURNING created_at`
+
+	err := d.db.QueryRowContext(ctx, sqlInsert, client.ClientID, client.Name, client.SecretHash,
+		strings.Join(client.Scopes, " ")).Scan(&client.CreatedAt)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to create oauth client", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &client, nil
+}
+
+// FindByID returns a client by id, disabled ones too, 404 if there is no such client.
+func (d *OAuthClientRepoDB) FindByID(ctx context.Context, clientID string) (*OAuthClient, lib.APIError) {
+	sqlFind := `SELECT client_id, name, secret_hash, scopes, disabled_at IS NOT NULL, created_at
+				FROM oauth_clients WHERE client_id = $1`
+
+	client, err := scanOAuthClient(d.db.QueryRowContext(ctx, sqlFind, clientID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError(fmt.Sprintf("oauth client %s not found", clientID))
+		}
+
+		d.l.ErrorContext(ctx, "unable to query oauth client", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return client, nil
+}
+
+// FindAll returns every client, newest first.
+func (d *OAuthClientRepoDB) FindAll(ctx context.Context) ([]OAuthClient, lib.APIError) {
+	sqlFindAll := `SELECT client_id, name, secret_hash, scopes, disabled_at IS NOT NULL, created_at
+				   FROM oauth_clients ORDER BY created_at DESC`
+
+	rows, err := d.db.QueryContext(ctx, sqlFindAll)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query oauth clients", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	clients := make([]OAuthClient, 0)
+
+	for rows.Next() {
+		var client *OAuthClient
+		if client, err = scanOAuthClient(rows); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		clients = append(clients, *client)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return clients, nil
+}
+
+// Disable stops a client from getting new access tokens, 404 if there is no such enabled client.
+func (d *OAuthClientRepoDB) Disable(ctx context.Context, clientID string) lib.APIError {
+	sqlDisable := `UPDATE oauth_clients SET disabled_at = now() WHERE client_id = $1 AND disabled_at IS NULL`
+
+	res, err := d.db.ExecContext(ctx, sqlDisable, clientID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to disable oauth client", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.NotFoundError(fmt.Sprintf("oauth client %s not found", clientID))
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOAuthClient(row rowScanner) (*OAuthClient, error) {
+	var client OAuthClient
+
+	var scopes string
+
+	err := row.Scan(&client.ClientID, &client.Name, &client.SecretHash, &scopes, &client.Disabled, &client.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan oauth client: %w", err)
+	}
+
+	client.Scopes = strings.Fields(scopes)
+
+	return &client, nil
+}
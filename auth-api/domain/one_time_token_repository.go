@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// OneTimeTokenRepository stores hashed, single use and expiring tokens such as password reset tokens,
+// the purpose keeps tokens issued for one flow from being accepted by another.
+type OneTimeTokenRepository interface {
+	Save(ctx context.Context, userID string, purpose string, tokenHash string, expiresAt time.Time) lib.APIError
+	Consume(ctx context.Context, purpose string, tokenHash string) (string, lib.APIError)
+	Peek(ctx context.Context, purpose string, tokenHash string) (string, lib.APIError)
+}
+
+type OneTimeTokenRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewOneTimeTokenRepoDB(db *sql.DB, l *slog.Logger) *OneTimeTokenRepoDB {
+	return &OneTimeTokenRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Save stores a new token for the user and purpose, in the same transaction it invalidates
+// every outstanding token the user has for that purpose, so only the latest issued token works.
+func (d *OneTimeTokenRepoDB) Save(ctx context.Context, userID string, purpose string, tokenHash string,
+	expiresAt time.Time) lib.APIError {
+	sqlInvalidate := `UPDATE one_time_tokens SET used_at = now() 
+					  WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	sqlInsert := `INSERT INTO one_time_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	if _, err = tx.ExecContext(ctx, sqlInvalidate, userID, purpose); err != nil {
+		d.l.ErrorContext(ctx, "unable to invalidate one time tokens", "err", err.Error(), "purpose", purpose)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlInsert, tokenHash, userID, purpose, expiresAt); err != nil {
+		d.l.ErrorContext(ctx, "unable to save one time token", "err", err.Error(), "purpose", purpose)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// Consume atomically marks an unused and unexpired token as used and returns the uuid of its owner.
+// Unknown, used and expired tokens are indistinguishable to the caller, all of them return 400.
+func (d *OneTimeTokenRepoDB) Consume(ctx context.Context, purpose string, tokenHash string) (string, lib.APIError) {
+	sqlConsume := `UPDATE one_time_tokens SET used_at = now()
+				   WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+				   RETURNING user_id`
+
+	var userID string
+
+	err := d.db.QueryRowContext(ctx, sqlConsume, tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", lib.BadRequestError("token is invalid or has expired")
+		}
+
+		d.l.ErrorContext(ctx, "unable to consume one time token", "err", err.Error(), "purpose", purpose)
+
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return userID, nil
+}
+
+// Peek returns the uuid of the owner of an unused and unexpired token without consuming it, so a request
+// can be validated against its owner before the token is spent. Errors are the same as Consume's.
+func (d *OneTimeTokenRepoDB) Peek(ctx context.Context, purpose string, tokenHash string) (string, lib.APIError) {
+	sqlPeek := `SELECT user_id FROM one_time_tokens
+				WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()`
+
+	var userID string
+
+	err := d.db.QueryRowContext(ctx, sqlPeek, tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", lib.BadRequestError("token is invalid or has expired")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query one time token", "err", err.Error(), "purpose", purpose)
+
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return userID, nil
+}
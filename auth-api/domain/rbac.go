@@ -0,0 +1,27 @@
+package domain
+
+// Role is a role users or clients can have with the route patterns it's granted,
+// AnyUserPermissions are those of them it's granted on behalf of any user, not only on the user itself.
+type Role struct {
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	Permissions        []string `json:"permissions"`
+	AnyUserPermissions []string `json:"anyUserPermissions"`
+}
+
+// Permission is a route pattern "METHOD:/path" that can be granted to roles, see policy.RolePermissions.
+type Permission struct {
+	ID          int64  `json:"id"`
+	Route       string `json:"route"`
+	Description string `json:"description"`
+}
+
+type CreateRoleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type CreatePermissionRequest struct {
+	Route       string `json:"route" binding:"required"`
+	Description string `json:"description"`
+}
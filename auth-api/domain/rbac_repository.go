@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// RBACRepository manages the roles, permissions and grants policy.RolePermissions loads.
+type RBACRepository interface {
+	FindRoles(ctx context.Context) ([]Role, lib.APIError)
+	CreateRole(ctx context.Context, role Role) lib.APIError
+	DeleteRole(ctx context.Context, name string) lib.APIError
+	FindPermissions(ctx context.Context) ([]Permission, lib.APIError)
+	CreatePermission(ctx context.Context, p Permission) (*Permission, lib.APIError)
+	DeletePermission(ctx context.Context, id int64) lib.APIError
+	Grant(ctx context.Context, role string, permissionID int64, anyUser bool) lib.APIError
+	Revoke(ctx context.Context, role string, permissionID int64) lib.APIError
+}
+
+type RBACRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewRBACRepoDB(db *sql.DB, l *slog.Logger) *RBACRepoDB {
+	return &RBACRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// FindRoles returns every role sorted by name with the routes granted to it, and those granted for any user.
+func (d *RBACRepoDB) FindRoles(ctx context.Context) ([]Role, lib.APIError) {
+	sqlFindRoles := `SELECT r.name, r.description, p.route, coalesce(rp.any_user, false) FROM roles r
+					 LEFT JOIN role_permissions rp ON rp.role = r.name
+					 LEFT JOIN permissions p ON p.id = rp.permission_id
+					 ORDER BY r.name, p.route`
+
+	rows, err := d.db.QueryContext(ctx, sqlFindRoles)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query roles", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	roles := make([]Role, 0)
+
+	for rows.Next() {
+		var name, description string
+
+		var route sql.NullString
+
+		var anyUser bool
+		if err = rows.Scan(&name, &description, &route, &anyUser); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, Role{Name: name, Description: description, Permissions: make([]string, 0),
+				AnyUserPermissions: make([]string, 0)})
+		}
+
+		if route.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, route.String)
+
+			if anyUser {
+				last.AnyUserPermissions = append(last.AnyUserPermissions, route.String)
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return roles, nil
+}
+
+// CreateRole adds a role without permissions, 409 if a role with the name exists.
+func (d *RBACRepoDB) CreateRole(ctx context.Context, role Role) lib.APIError {
+	sqlInsert := `INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`
+
+	res, err := d.db.ExecContext(ctx, sqlInsert, role.Name, role.Description)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to create role", "err", err.Error(), "role", role.Name)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.ConflictError(fmt.Sprintf("role %s exists", role.Name))
+	}
+
+	return nil
+}
+
+// DeleteRole removes a role along with its grants.
+func (d *RBACRepoDB) DeleteRole(ctx context.Context, name string) lib.APIError {
+	sqlDelete := `DELETE FROM roles WHERE name = $1`
+
+	return d.deleteOne(ctx, sqlDelete, name, fmt.Sprintf("role %s not found", name))
+}
+
+// FindPermissions returns every permission sorted by route.
+func (d *RBACRepoDB) FindPermissions(ctx context.Context) ([]Permission, lib.APIError) {
+	sqlFindPermissions := `SELECT id, route, description FROM permissions ORDER BY route`
+
+	rows, err := d.db.QueryContext(ctx, sqlFindPermissions)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query permissions", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	permissions := make([]Permission, 0)
+
+	for rows.Next() {
+		var p Permission
+		if err = rows.Scan(&p.ID, &p.Route, &p.Description); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		permissions = append(permissions, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return permissions, nil
+}
+
+// CreatePermission adds a route pattern and returns it with its id, 409 if the route exists.
+func (d *RBACRepoDB) CreatePermission(ctx context.Context, p Permission) (*Permission, lib.APIError) {
+	sqlInsert := `INSERT INTO permissions (route, description) VALUES ($1, $2)
+				  ON CONFLICT (route) DO NOTHING RETURNING id`
+
+	err := d.db.QueryRowContext(ctx, sqlInsert, p.Route, p.Description).Scan(&p.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.ConflictError(fmt.Sprintf("permission %s exists", p.Route))
+		}
+
+		d.l.ErrorContext(ctx, "unable to create permission", "err", err.Error(), "route", p.Route)
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &p, nil
+}
+
+// DeletePermission removes a permission, revoking it from every role.
+func (d *RBACRepoDB) DeletePermission(ctx context.Context, id int64) lib.APIError {
+	sqlDelete := `DELETE FROM permissions WHERE id = $1`
+
+	return d.deleteOne(ctx, sqlDelete, id, fmt.Sprintf("permission %d not found", id))
+}
+
+// Grant grants the permission to the role, for any user or for the user itself,
+// granting it again only updates that. Both of them must exist, otherwise 404.
+func (d *RBACRepoDB) Grant(ctx context.Context, role string, permissionID int64, anyUser bool) lib.APIError {
+	sqlGrant := `INSERT INTO role_permissions (role, permission_id, any_user)
+				 SELECT r.name, p.id, $3 FROM roles r, permissions p WHERE r.name = $1 AND p.id = $2
+				 ON CONFLICT (role, permission_id) DO UPDATE SET any_user = excluded.any_user`
+	sqlExists := `SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = $1 AND permission_id = $2)`
+
+	res, err := d.db.ExecContext(ctx, sqlGrant, role, permissionID, anyUser)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to grant permission", "err", err.Error(), "role", role)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	var granted bool
+	if err = d.db.QueryRowContext(ctx, sqlExists, role, permissionID).Scan(&granted); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRow, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if !granted {
+		return lib.NotFoundError(fmt.Sprintf("role %s or permission %d not found", role, permissionID))
+	}
+
+	return nil
+}
+
+// Revoke revokes the permission from the role, 404 if it wasn't granted.
+func (d *RBACRepoDB) Revoke(ctx context.Context, role string, permissionID int64) lib.APIError {
+	sqlRevoke := `DELETE FROM role_permissions WHERE role = $1 AND permission_id = $2`
+
+	res, err := d.db.ExecContext(ctx, sqlRevoke, role, permissionID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke permission", "err", err.Error(), "role", role)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.NotFoundError(fmt.Sprintf("permission %d is not granted to role %s", permissionID, role))
+	}
+
+	return nil
+}
+
+func (d *RBACRepoDB) deleteOne(ctx context.Context, sqlDelete string, id any, notFound string) lib.APIError {
+	res, err := d.db.ExecContext(ctx, sqlDelete, id)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to delete rbac entry", "err", err.Error(), "id", id)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return lib.NotFoundError(notFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Session is a login session, identified by the refresh token family it started.
+type Session struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+// ClientInfo describes the client a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
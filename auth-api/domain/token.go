@@ -9,17 +9,18 @@ import (
 )
 
 type AuthToken struct {
-	token *jwt.Token
-	l     *slog.Logger
+	claims jwt.Claims
+	keys   *jwtutils.KeySet
+	l      *slog.Logger
 }
 
-func NewAuthToken(claims AccessTokenClaims, l *slog.Logger) AuthToken {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return AuthToken{token: token, l: l}
+func NewAuthToken(claims jwt.Claims, keys *jwtutils.KeySet, l *slog.Logger) AuthToken {
+	return AuthToken{claims: claims, keys: keys, l: l}
 }
 
+// NewAccessToken signs the claims with the active key of the key set, the key id is set in the token header.
 func (t AuthToken) NewAccessToken() (string, lib.APIError) {
-	signedString, err := t.token.SignedString(jwtutils.HMACSecret)
+	signedString, err := t.keys.Sign(t.claims)
 	if err != nil {
 		t.l.Error("failed signing access token", "err", err.Error())
 		return "", lib.InternalServerError("cannot generate access token", err)
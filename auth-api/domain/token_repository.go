@@ -0,0 +1,289 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+type TokenRepository interface {
+	SaveRefreshToken(ctx context.Context, userID string, tokenHash string, expiresAt time.Time,
+		client ClientInfo) (string, lib.APIError)
+	RotateRefreshToken(ctx context.Context, tokenHash string, newTokenHash string, expiresAt time.Time,
+		client ClientInfo) (*Login, string, lib.APIError)
+	RevokeFamily(ctx context.Context, familyID string) lib.APIError
+	RevokeUserTokens(ctx context.Context, userID string) lib.APIError
+	FindSessions(ctx context.Context, userID string) ([]Session, lib.APIError)
+	RevokeSession(ctx context.Context, userID string, sessionID string) lib.APIError
+}
+
+type TokenRepoDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewTokenRepoDB(db *sql.DB, l *slog.Logger) *TokenRepoDB {
+	return &TokenRepoDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// SaveRefreshToken persists the hash of a refresh token that starts a new token family and returns the family id.
+// Every token issued later by rotating this one shares the generated family id, which also identifies the session,
+// the session is recorded with the client it was started from.
+func (d *TokenRepoDB) SaveRefreshToken(ctx context.Context, userID string, tokenHash string, expiresAt time.Time,
+	client ClientInfo) (string, lib.APIError) {
+	sqlInsertSession := `INSERT INTO auth_sessions (id, user_id, ip, user_agent)
+						 VALUES (uuid_generate_v4(), $1, $2, $3) RETURNING id`
+	sqlInsert := `INSERT INTO refresh_tokens (token_hash, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var familyID string
+	if err = tx.QueryRowContext(ctx, sqlInsertSession, userID, client.IP, client.UserAgent).Scan(&familyID); err != nil {
+		d.l.ErrorContext(ctx, "unable to save session", "err", err.Error())
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlInsert, tokenHash, familyID, userID, expiresAt); err != nil {
+		d.l.ErrorContext(ctx, "unable to save refresh token", "err", err.Error())
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return familyID, nil
+}
+
+// RotateRefreshToken marks the presented refresh token as used and stores its successor in the same family,
+// then returns the login details of the token owner and the family id for issuing a new access token.
+// The presented token row is locked for the duration of the transaction, so concurrent rotations serialize.
+// Presenting a token that was already used is treated as token theft, the whole family is revoked
+// and 401 is returned. So is it if the owner isn't active anymore, deactivated, deleted or unverified.
+// Unknown, revoked or expired tokens also return 401, other errors result in 500.
+// The session is marked as last seen now from the given client.
+func (d *TokenRepoDB) RotateRefreshToken(ctx context.Context, tokenHash string, newTokenHash string,
+	expiresAt time.Time, client ClientInfo) (*Login, string, lib.APIError) {
+	sqlFindForUpdate := `SELECT rt.family_id, rt.used_at, rt.revoked_at, rt.expires_at,
+       						u.user_id, u.username, u.email, u.role, u.status
+						 FROM refresh_tokens rt JOIN users u ON u.user_id = rt.user_id
+						 WHERE rt.token_hash = $1 FOR UPDATE OF rt`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var l Login
+	var familyID string
+	var usedAt, revokedAt sql.NullTime
+	var tokenExpiresAt time.Time
+
+	err = tx.QueryRowContext(ctx, sqlFindForUpdate, tokenHash).Scan(&familyID, &usedAt, &revokedAt,
+		&tokenExpiresAt, &l.UserID, &l.Username, &l.Email, &l.Role, &l.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", lib.UnauthorizedError("invalid refresh token")
+		}
+
+		d.l.ErrorContext(ctx, "unable to query refresh token", "err", err.Error())
+
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if revokedAt.Valid {
+		err = errors.New("refresh token revoked")
+		return nil, "", lib.UnauthorizedError("refresh token has been revoked")
+	}
+
+	if usedAt.Valid {
+		if err = d.revokeFamily(ctx, tx, familyID); err != nil {
+			return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if err = tx.Commit(); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+			return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		d.l.WarnContext(ctx, "refresh token reuse detected, token family revoked",
+			"familyId", familyID, "userId", l.UserID)
+
+		return nil, "", lib.UnauthorizedError("refresh token has already been used")
+	}
+
+	if time.Now().After(tokenExpiresAt) {
+		err = errors.New("refresh token expired")
+		return nil, "", lib.UnauthorizedError("refresh token has expired")
+	}
+
+	if l.Status != StatusActive {
+		if err = d.revokeFamily(ctx, tx, familyID); err != nil {
+			return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if err = tx.Commit(); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+			return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		d.l.InfoContext(ctx, "refresh token of an inactive user, token family revoked", "familyId", familyID,
+			"userId", l.UserID, "status", l.Status)
+
+		return nil, "", lib.UnauthorizedError("user is not active")
+	}
+
+	sqlMarkUsed := `UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1`
+	if _, err = tx.ExecContext(ctx, sqlMarkUsed, tokenHash); err != nil {
+		d.l.ErrorContext(ctx, "unable to mark refresh token used", "err", err.Error())
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	sqlInsert := `INSERT INTO refresh_tokens (token_hash, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err = tx.ExecContext(ctx, sqlInsert, newTokenHash, familyID, l.UserID, expiresAt); err != nil {
+		d.l.ErrorContext(ctx, "unable to save rotated refresh token", "err", err.Error())
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	sqlTouchSession := `UPDATE auth_sessions SET last_seen_at = now(), ip = $2, user_agent = $3 WHERE id = $1`
+	if _, err = tx.ExecContext(ctx, sqlTouchSession, familyID, client.IP, client.UserAgent); err != nil {
+		d.l.ErrorContext(ctx, "unable to update session", "err", err.Error())
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, "", lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &l, familyID, nil
+}
+
+// RevokeFamily revokes every refresh token of a token family, ending the session it belongs to.
+func (d *TokenRepoDB) RevokeFamily(ctx context.Context, familyID string) lib.APIError {
+	sqlRevokeFamily := `WITH s AS (UPDATE auth_sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL)
+						UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := d.db.ExecContext(ctx, sqlRevokeFamily, familyID); err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke refresh token family", "err", err.Error(), "familyId", familyID)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// RevokeUserTokens revokes every refresh token of a user, ending all of their sessions.
+func (d *TokenRepoDB) RevokeUserTokens(ctx context.Context, userID string) lib.APIError {
+	sqlRevokeUser := `WITH s AS (UPDATE auth_sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL)
+					  UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := d.db.ExecContext(ctx, sqlRevokeUser, userID); err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke user refresh tokens", "err", err.Error(), "userId", userID)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// FindSessions returns the active sessions of a user, most recently seen first.
+// A session is active until it's revoked or its latest refresh token expired.
+func (d *TokenRepoDB) FindSessions(ctx context.Context, userID string) ([]Session, lib.APIError) {
+	sqlFindSessions := `SELECT s.id, s.ip, s.user_agent, s.created_at, s.last_seen_at FROM auth_sessions s
+						WHERE s.user_id = $1 AND s.revoked_at IS NULL
+						AND EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.family_id = s.id AND rt.used_at IS NULL
+						            AND rt.revoked_at IS NULL AND rt.expires_at > now())
+						ORDER BY s.last_seen_at DESC`
+
+	rows, err := d.db.QueryContext(ctx, sqlFindSessions, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query sessions", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	sessions := make([]Session, 0)
+
+	for rows.Next() {
+		var s Session
+		if err = rows.Scan(&s.ID, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes a session of the user with its refresh tokens, 404 if the user has no such active session.
+func (d *TokenRepoDB) RevokeSession(ctx context.Context, userID string, sessionID string) lib.APIError {
+	sqlRevokeSession := `UPDATE auth_sessions SET revoked_at = now()
+						 WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	res, err := tx.ExecContext(ctx, sqlRevokeSession, sessionID, userID)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke session", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = errors.New("session not found")
+		return lib.NotFoundError("session not found")
+	}
+
+	if err = d.revokeFamily(ctx, tx, sessionID); err != nil {
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// revokeFamily revokes every not yet revoked refresh token of a token family inside the given transaction.
+func (d *TokenRepoDB) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	sqlRevokeFamily := `WITH s AS (UPDATE auth_sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL)
+						UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := tx.ExecContext(ctx, sqlRevokeFamily, familyID); err != nil {
+		d.l.ErrorContext(ctx, "unable to revoke refresh token family", "err", err.Error(), "familyId", familyID)
+		return fmt.Errorf("unable to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
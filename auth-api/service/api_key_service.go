@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/apikey"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, userID string, req domain.CreateAPIKeyRequest) (*domain.APIKey, string, lib.APIError)
+	FindAll(ctx context.Context, userID string) ([]domain.APIKey, lib.APIError)
+	UpdateLabel(ctx context.Context, userID string, keyID string, req domain.UpdateAPIKeyRequest) (*domain.APIKey,
+		lib.APIError)
+	Revoke(ctx context.Context, userID string, keyID string) lib.APIError
+	Introspect(ctx context.Context, key string, clientIP string) jwtutils.Introspection
+}
+
+type DefaultAPIKeyService struct {
+	repo domain.APIKeyRepository
+	l    *slog.Logger
+}
+
+func NewAPIKeyService(repo domain.APIKeyRepository, l *slog.Logger) DefaultAPIKeyService {
+	return DefaultAPIKeyService{
+		repo: repo,
+		l:    l,
+	}
+}
+
+// Create issues an API key to the user and returns it with the key itself, the key is shown only once.
+func (s DefaultAPIKeyService) Create(ctx context.Context, userID string,
+	req domain.CreateAPIKeyRequest) (*domain.APIKey, string, lib.APIError) {
+	if apiErr := validateCreateAPIKeyRequest(req); apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	key, prefix, err := apikey.Generate()
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating api key", "err", err.Error())
+		return nil, "", lib.InternalServerError("cannot generate api key", err)
+	}
+
+	allowedIPs := req.AllowedIPs
+	if allowedIPs == nil {
+		allowedIPs = make([]string, 0)
+	}
+
+	created, apiErr := s.repo.Create(ctx, domain.APIKey{
+		UserID:     userID,
+		Label:      req.Label,
+		Prefix:     prefix,
+		Scopes:     req.Scopes,
+		AllowedIPs: allowedIPs,
+		KeyHash:    securetoken.Hash(key),
+	})
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	return created, key, nil
+}
+
+func (s DefaultAPIKeyService) FindAll(ctx context.Context, userID string) ([]domain.APIKey, lib.APIError) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+func (s DefaultAPIKeyService) UpdateLabel(ctx context.Context, userID string, keyID string,
+	req domain.UpdateAPIKeyRequest) (*domain.APIKey, lib.APIError) {
+	if apiErr := validateAPIKeyLabel(req.Label); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return s.repo.UpdateLabel(ctx, userID, keyID, req.Label)
+}
+
+// Revoke revokes a key of the user, services accepting it stop doing so within their verification cache ttl.
+func (s DefaultAPIKeyService) Revoke(ctx context.Context, userID string, keyID string) lib.APIError {
+	return s.repo.Revoke(ctx, userID, keyID)
+}
+
+// Introspect describes an API key used from clientIP as an introspection response. It's active only
+// if the key isn't revoked, the ip is allowed and its owner is still an active merchant.
+func (s DefaultAPIKeyService) Introspect(ctx context.Context, key string, clientIP string) jwtutils.Introspection {
+	inactive := jwtutils.Introspection{Active: false}
+
+	prefix, ok := apikey.Prefix(key)
+	if !ok {
+		return inactive
+	}
+
+	k, owner, apiErr := s.repo.FindByPrefix(ctx, prefix)
+	if apiErr != nil {
+		if apiErr.Code() != http.StatusNotFound {
+			s.l.WarnContext(ctx, "unable to introspect api key", "err", apiErr.WithCauses())
+		}
+
+		return inactive
+	}
+
+	validKey := subtle.ConstantTimeCompare([]byte(securetoken.Hash(key)), []byte(k.KeyHash)) == 1
+	if !validKey || k.Revoked || !apikey.AllowsIP(k.AllowedIPs, clientIP) ||
+		owner.Role != domain.RoleMerchant || owner.Status != domain.StatusActive {
+		return inactive
+	}
+
+	if apiErr = s.repo.Touch(ctx, k.KeyID); apiErr != nil {
+		s.l.WarnContext(ctx, "unable to record api key use", "err", apiErr.WithCauses())
+	}
+
+	return jwtutils.Introspection{
+		Active:    true,
+		Scope:     strings.Join(k.Scopes, " "),
+		Username:  owner.Username,
+		TokenType: apikey.Scheme,
+		Iat:       k.CreatedAt.Unix(),
+		Sub:       owner.UserID,
+		UserID:    owner.UserID,
+		Email:     owner.Email,
+		Role:      owner.Role,
+		Status:    owner.Status,
+		APIKeyID:  k.KeyID,
+	}
+}
@@ -3,24 +3,66 @@ package service
 import (
 	"context"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/ashtishad/instabid-wallet/auth-api/domain"
 	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/revocation"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+	"github.com/ashtishad/instabid-wallet/lib/totp"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+const tokenIDSize = 16
+
 type AuthService interface {
 	Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResponse, lib.APIError)
+	LoginMFA(ctx context.Context, req domain.LoginMFARequest) (*domain.LoginResponse, lib.APIError)
+	CompleteLogin(ctx context.Context, login *domain.Login, event domain.AuthEvent) (*domain.LoginResponse,
+		lib.APIError)
+	Refresh(ctx context.Context, req domain.RefreshRequest) (*domain.LoginResponse, lib.APIError)
+	Logout(ctx context.Context, claims *domain.AccessTokenClaims) lib.APIError
+	LogoutAll(ctx context.Context, userID string) lib.APIError
+	Sessions(ctx context.Context, claims *domain.AccessTokenClaims) ([]domain.Session, lib.APIError)
+	RevokeSession(ctx context.Context, userID string, sessionID string) lib.APIError
+	Unlock(ctx context.Context, userID string) lib.APIError
 }
 
 type DefaultAuthService struct {
-	repo domain.AuthRepository
-	l    *slog.Logger
+	repo        domain.AuthRepository
+	tokenRepo   domain.TokenRepository
+	mfaRepo     domain.MFARepository
+	attemptRepo domain.LoginAttemptRepository
+	eventRepo   domain.AuthEventRepository
+	auditLog    audit.Log
+	revocations *revocation.Store
+	keys        *jwtutils.KeySet
+	l           *slog.Logger
 }
 
-func NewAuthService(repo domain.AuthRepository, l *slog.Logger) DefaultAuthService {
-	return DefaultAuthService{repo: repo, l: l}
+func NewAuthService(repo domain.AuthRepository, tokenRepo domain.TokenRepository, mfaRepo domain.MFARepository,
+	attemptRepo domain.LoginAttemptRepository, eventRepo domain.AuthEventRepository, auditLog audit.Log,
+	revocations *revocation.Store, keys *jwtutils.KeySet, l *slog.Logger) DefaultAuthService {
+	return DefaultAuthService{
+		repo:        repo,
+		tokenRepo:   tokenRepo,
+		mfaRepo:     mfaRepo,
+		attemptRepo: attemptRepo,
+		eventRepo:   eventRepo,
+		auditLog:    auditLog,
+		revocations: revocations,
+		keys:        keys,
+		l:           l,
+	}
 }
 
+// Login authenticates a user by email or username and password. Failed logins are tracked per account
+// and per client ip, they delay further attempts increasingly and finally lock logins temporarily.
+// Every attempt is counted before the password is checked, so parallel attempts are limited too.
+// If the user has MFA enabled only a challenge token is returned, see LoginMFA.
 func (s DefaultAuthService) Login(ctx context.Context, req domain.LoginRequest) (*domain.LoginResponse, lib.APIError) {
 	var apiErr lib.APIError
 	var login *domain.Login
@@ -29,22 +71,356 @@ func (s DefaultAuthService) Login(ctx context.Context, req domain.LoginRequest)
 		return nil, apiErr
 	}
 
+	identifier, field := req.Email, domain.UserCredentialEmail
+	if identifier == "" {
+		identifier, field = req.Username, domain.UserCredentialUsername
+	}
+
+	accountKey := accountAttemptKey(field, identifier)
+
+	attempt, apiErr := s.reserveLoginAttempt(ctx, loginAttemptKeys(ctx, accountKey))
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusTooManyRequests {
+			s.recordEvent(ctx, domain.AuthEvent{Identifier: identifier, Event: domain.AuthEventLoginFailed,
+				Reason: domain.AuthReasonLockedOut})
+		}
+
+		return nil, apiErr
+	}
+
 	login, apiErr = s.repo.FindByCredential(ctx, req)
+	if apiErr != nil {
+		if apiErr.Code() != http.StatusUnauthorized {
+			s.releaseLoginAttempt(ctx, attempt)
+			return nil, apiErr
+		}
+
+		s.recordEvent(ctx, domain.AuthEvent{Identifier: identifier, Event: domain.AuthEventLoginFailed,
+			Reason: domain.AuthReasonInvalidCredentials})
+
+		if failErr := s.failLoginAttempt(ctx, attempt); failErr != nil {
+			return nil, failErr
+		}
+
+		return nil, apiErr
+	}
+
+	if apiErr = s.succeedLoginAttempt(ctx, attempt, accountKey); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return s.CompleteLogin(ctx, login, domain.AuthEvent{UserID: login.UserID, Identifier: identifier,
+		Event: domain.AuthEventLoginSucceeded, Reason: domain.AuthReasonPassword})
+}
+
+// CompleteLogin starts a session for a user who proved a first factor, recorded as event.
+// If the user has MFA enabled only a challenge token is returned, see LoginMFA.
+func (s DefaultAuthService) CompleteLogin(ctx context.Context, login *domain.Login,
+	event domain.AuthEvent) (*domain.LoginResponse, lib.APIError) {
+	mfa, apiErr := s.mfaRepo.FindByUserID(ctx, login.UserID)
+	if apiErr != nil && apiErr.Code() != http.StatusNotFound {
+		return nil, apiErr
+	}
+
+	if mfa != nil && mfa.Confirmed {
+		event.Event = domain.AuthEventMFAChallenged
+		s.recordEvent(ctx, event)
+
+		return s.mfaChallenge(login)
+	}
+
+	return s.startSessionWithEvent(ctx, login, event)
+}
+
+// LoginMFA completes a login of a user with MFA enabled, it exchanges the challenge token returned by Login
+// and a TOTP code or an unused recovery code for a new session. Each challenge can be exchanged once.
+// Wrong codes are counted per user and per client ip across challenges, like failed logins they delay
+// further attempts increasingly and finally lock the user's codes temporarily.
+func (s DefaultAuthService) LoginMFA(ctx context.Context, req domain.LoginMFARequest) (*domain.LoginResponse,
+	lib.APIError) {
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		return nil, lib.BadRequestError("either code or recovery code must be provided")
+	}
+
+	challenge, apiErr := s.parseMFAChallenge(req.MFAToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	event := domain.AuthEvent{UserID: challenge.UserID, Event: domain.AuthEventMFAFailed}
+
+	attempt, apiErr := s.reserveLoginAttempt(ctx, mfaAttemptKeys(ctx, challenge.UserID))
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusTooManyRequests {
+			event.Reason = domain.AuthReasonTooManyAttempts
+			s.recordEvent(ctx, event)
+		}
+
+		return nil, apiErr
+	}
+
+	if req.Code != "" {
+		event.Reason = domain.AuthReasonMFACode
+		apiErr = s.verifyMFACode(ctx, challenge.UserID, req.Code)
+	} else {
+		event.Reason = domain.AuthReasonRecoveryCode
+		codeHash := securetoken.Hash(normalizeRecoveryCode(req.RecoveryCode))
+		apiErr = s.mfaRepo.ConsumeRecoveryCode(ctx, challenge.UserID, codeHash)
+	}
+
+	if apiErr != nil {
+		if apiErr.Code() != http.StatusUnauthorized {
+			s.releaseLoginAttempt(ctx, attempt)
+			return nil, apiErr
+		}
+
+		s.recordEvent(ctx, domain.AuthEvent{UserID: challenge.UserID, Event: domain.AuthEventMFAFailed,
+			Reason: domain.AuthReasonInvalidCode})
+
+		if failErr := s.failLoginAttempt(ctx, attempt); failErr != nil {
+			return nil, failErr
+		}
+
+		return nil, apiErr
+	}
+
+	if apiErr = s.succeedLoginAttempt(ctx, attempt, mfaAttemptKey(challenge.UserID)); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if apiErr = s.revocations.RevokeToken(ctx, challenge.ID, challenge.UserID, challenge.ExpiresAt.Time); apiErr != nil {
+		return nil, apiErr
+	}
+
+	login, apiErr := s.repo.FindByUserID(ctx, challenge.UserID)
 	if apiErr != nil {
 		return nil, apiErr
 	}
 
-	claims := login.ClaimsForAccessToken()
-	authToken := domain.NewAuthToken(claims, s.l)
+	// the user may have been deactivated or deleted since the challenge was issued
+	if login.Status != domain.StatusActive {
+		s.l.InfoContext(ctx, "mfa login of a user who isn't active", "userId", login.UserID, "status", login.Status)
+		return nil, lib.UnauthorizedError("mfa token is invalid or has expired")
+	}
+
+	event.Event = domain.AuthEventLoginSucceeded
+	event.Identifier = login.Username
+
+	return s.startSessionWithEvent(ctx, login, event)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// The presented refresh token is consumed, presenting it again revokes every token of its family.
+func (s DefaultAuthService) Refresh(ctx context.Context, req domain.RefreshRequest) (*domain.LoginResponse,
+	lib.APIError) {
+	if req.RefreshToken == "" {
+		return nil, lib.BadRequestError("refresh token must be provided")
+	}
+
+	refreshToken, apiErr := s.newRefreshToken()
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
-	var accessToken string
+	expiresAt := time.Now().Add(domain.RefreshTokenDuration)
 
-	if accessToken, apiErr = authToken.NewAccessToken(); apiErr != nil {
+	login, sessionID, apiErr := s.tokenRepo.RotateRefreshToken(ctx, securetoken.Hash(req.RefreshToken),
+		securetoken.Hash(refreshToken), expiresAt, clientInfoFromContext(ctx))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	return s.loginResponse(login, sessionID, refreshToken)
+}
+
+// Logout ends the session of the presented access token, the access token itself is revoked until it expires,
+// the session id is revoked for as long as any of its refresh tokens could live, and its refresh tokens are revoked.
+func (s DefaultAuthService) Logout(ctx context.Context, claims *domain.AccessTokenClaims) lib.APIError {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if apiErr := s.revocations.RevokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	if apiErr := s.revokeSessionID(ctx, claims.UserID, claims.SessionID); apiErr != nil {
+		return apiErr
+	}
+
+	return s.tokenRepo.RevokeFamily(ctx, claims.SessionID)
+}
+
+// Sessions returns the active sessions of the authenticated user, marking the one the access token belongs to.
+func (s DefaultAuthService) Sessions(ctx context.Context, claims *domain.AccessTokenClaims) ([]domain.Session,
+	lib.APIError) {
+	sessions, apiErr := s.tokenRepo.FindSessions(ctx, claims.UserID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	markCurrentSession(sessions, claims.SessionID)
+
+	return sessions, nil
+}
+
+// RevokeSession ends one session of a user, its refresh tokens and the access tokens issued for it stop working.
+func (s DefaultAuthService) RevokeSession(ctx context.Context, userID string, sessionID string) lib.APIError {
+	if apiErr := validateRevokeSession(userID, sessionID); apiErr != nil {
+		return apiErr
+	}
+
+	if apiErr := s.tokenRepo.RevokeSession(ctx, userID, sessionID); apiErr != nil {
+		return apiErr
+	}
+
+	return s.revokeSessionID(ctx, userID, sessionID)
+}
+
+// LogoutAll ends every session of a user, all access tokens issued until now and all refresh tokens are revoked.
+func (s DefaultAuthService) LogoutAll(ctx context.Context, userID string) lib.APIError {
+	if apiErr := s.revocations.RevokeUser(ctx, userID, time.Now()); apiErr != nil {
+		return apiErr
+	}
+
+	return s.tokenRepo.RevokeUserTokens(ctx, userID)
+}
+
+// startSessionWithEvent starts a new session of the logged-in user, a new refresh token family,
+// and records the successful login event and audit log entry.
+func (s DefaultAuthService) startSessionWithEvent(ctx context.Context, login *domain.Login,
+	event domain.AuthEvent) (*domain.LoginResponse, lib.APIError) {
+	refreshToken, apiErr := s.newRefreshToken()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	expiresAt := time.Now().Add(domain.RefreshTokenDuration)
+
+	sessionID, apiErr := s.tokenRepo.SaveRefreshToken(ctx, login.UserID, securetoken.Hash(refreshToken), expiresAt,
+		clientInfoFromContext(ctx))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	res, apiErr := s.loginResponse(login, sessionID, refreshToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.recordEvent(ctx, event)
+	s.auditLog.Record(ctx, audit.Entry{
+		Actor:  login.UserID,
+		Target: login.UserID,
+		Action: audit.ActionLogin,
+		Diff:   audit.Diff{"session": {After: sessionID}, "method": {After: event.Reason}},
+		IP:     clientInfoFromContext(ctx).IP,
+	})
+
+	return res, nil
+}
+
+// revokeSessionID revokes the session id, so access tokens issued for the session are rejected,
+// for as long as any of its refresh tokens could live.
+func (s DefaultAuthService) revokeSessionID(ctx context.Context, userID string, sessionID string) lib.APIError {
+	return s.revocations.RevokeToken(ctx, sessionID, userID, time.Now().Add(domain.RefreshTokenDuration))
+}
+
+// recordEvent saves an entry of the authentication audit trail with the client of the request,
+// failing to save it is logged but doesn't fail the request.
+func (s DefaultAuthService) recordEvent(ctx context.Context, e domain.AuthEvent) {
+	e.Client = clientInfoFromContext(ctx)
+
+	if apiErr := s.eventRepo.Save(ctx, e); apiErr != nil {
+		s.l.WarnContext(ctx, "auth event not recorded", "event", e.Event, "userId", e.UserID, "reason", e.Reason)
+	}
+}
+
+// mfaChallenge signs a short-lived challenge token for a user whose password was verified but who must pass MFA.
+func (s DefaultAuthService) mfaChallenge(login *domain.Login) (*domain.LoginResponse, lib.APIError) {
+	tokenID, err := securetoken.Generate(tokenIDSize)
+	if err != nil {
+		s.l.Error("failed generating token id", "err", err.Error())
+		return nil, lib.InternalServerError("cannot generate mfa token", err)
+	}
+
+	mfaToken, apiErr := domain.NewAuthToken(login.ClaimsForMFAChallenge(tokenID), s.keys, s.l).NewAccessToken()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	return &domain.LoginResponse{MFAToken: mfaToken}, nil
+}
+
+// parseMFAChallenge validates a challenge token, revoked (already exchanged) and expired ones are rejected.
+func (s DefaultAuthService) parseMFAChallenge(tokenStr string) (*domain.AccessTokenClaims, lib.APIError) {
+	token, err := jwtutils.ParseAndValidateToken(tokenStr)
+	if err != nil {
+		return nil, lib.UnauthorizedError("mfa token is invalid or has expired")
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, lib.UnauthorizedError("mfa token is invalid or has expired")
+	}
+
+	claims, err := domain.ClaimsFromMap(mapClaims)
+	if err != nil || claims.TokenType != domain.TokenTypeMFAChallenge || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, lib.UnauthorizedError("mfa token is invalid or has expired")
+	}
+
+	return claims, nil
+}
+
+// verifyMFACode checks a TOTP code against the confirmed secret of the user and records its time step,
+// so the code can't be used again.
+func (s DefaultAuthService) verifyMFACode(ctx context.Context, userID string, code string) lib.APIError {
+	mfa, apiErr := s.mfaRepo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !mfa.Confirmed || !ok || step <= mfa.LastUsedStep {
+		return lib.UnauthorizedError("mfa code is invalid")
+	}
+
+	return s.mfaRepo.UseStep(ctx, userID, step)
+}
+
+// newRefreshToken generates a new opaque refresh token, only its hash is stored in the database.
+func (s DefaultAuthService) newRefreshToken() (string, lib.APIError) {
+	refreshToken, err := securetoken.Generate(domain.RefreshTokenSize)
+	if err != nil {
+		s.l.Error("failed generating refresh token", "err", err.Error())
+		return "", lib.InternalServerError("cannot generate refresh token", err)
+	}
+
+	return refreshToken, nil
+}
+
+// loginResponse signs a new access token for the login session and pairs it with the given refresh token.
+func (s DefaultAuthService) loginResponse(login *domain.Login, sessionID string,
+	refreshToken string) (*domain.LoginResponse, lib.APIError) {
+	tokenID, err := securetoken.Generate(tokenIDSize)
+	if err != nil {
+		s.l.Error("failed generating token id", "err", err.Error())
+		return nil, lib.InternalServerError("cannot generate access token", err)
+	}
+
+	claims := login.ClaimsForAccessToken(sessionID, tokenID)
+	authToken := domain.NewAuthToken(claims, s.keys, s.l)
+
+	accessToken, apiErr := authToken.NewAccessToken()
+	if apiErr != nil {
 		return nil, apiErr
 	}
 
 	response := domain.LoginResponse{
-		AccessToken: accessToken,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 		Login: domain.Login{
 			UserID:   login.UserID,
 			Username: login.Username,
@@ -1,10 +1,32 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/ashtishad/instabid-wallet/auth-api/domain"
 	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/apikey"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+)
+
+const (
+	maxRoleDescriptionLength = 256
+	maxClientNameLength      = 128
+	maxAPIKeyLabelLength     = 128
+	maxUserAgentLength       = 512
+)
+
+var (
+	roleNameRegex        = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+	recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+	sessionIDRegex       = regexp.MustCompile(`^[a-fA-F0-9]{8}(-[a-fA-F0-9]{4}){3}-[a-fA-F0-9]{12}$`)
 )
 
 // validateLoginRequest validates the fields of a LoginRequest.
@@ -43,3 +65,179 @@ func validateLoginRequest(req domain.LoginRequest) lib.APIError {
 
 	return nil
 }
+
+// validateResetPasswordRequest validates the fields of a ResetPasswordRequest.
+// Exactly one of Username or Email must be provided.
+func validateResetPasswordRequest(req domain.ResetPasswordRequest) lib.APIError {
+	switch {
+	case req.Username != "" && req.Email != "":
+		return lib.BadRequestError("password reset can't be requested with both username and email")
+	case req.Email != "":
+		if err := lib.ValidateEmail(req.Email); err != nil {
+			return lib.BadRequestError(err.Error())
+		}
+	case req.Username != "":
+		if err := lib.ValidateUserName(req.Username); err != nil {
+			return lib.BadRequestError(err.Error())
+		}
+	default:
+		return lib.BadRequestError("either username or email must be provided")
+	}
+
+	return nil
+}
+
+// validateCreateRoleRequest validates the fields of a CreateRoleRequest.
+// Role names are lowercase identifiers, as they are stored in tokens and in the role_permissions table.
+func validateCreateRoleRequest(req domain.CreateRoleRequest) lib.APIError {
+	if !roleNameRegex.MatchString(req.Name) {
+		return lib.BadRequestError("role name must be 2-32 lowercase letters, digits or underscores, " +
+			"starting with a letter")
+	}
+
+	if len(req.Description) > maxRoleDescriptionLength {
+		return lib.BadRequestError(fmt.Sprintf("role description must be at most %d characters",
+			maxRoleDescriptionLength))
+	}
+
+	return nil
+}
+
+// newRecoveryCode returns a random MFA recovery code formatted for humans, e.g. "abcde-fghij".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, domain.RecoveryCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to read random bytes: %w", err)
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+
+	return code[:len(code)/2] + "-" + code[len(code)/2:], nil
+}
+
+// normalizeRecoveryCode strips separators and case from a recovery code as typed by a user,
+// recovery codes are hashed in their normalized form.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// resolveScopes returns the scopes to grant for a space-delimited requested scope, all allowed ones if empty.
+// It returns false if any requested scope isn't allowed.
+func resolveScopes(requested string, allowed []string) ([]string, bool) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, len(allowed) > 0
+	}
+
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, scope := range allowed {
+		allowedSet[scope] = true
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !allowedSet[scope] {
+			return nil, false
+		}
+	}
+
+	return scopes, true
+}
+
+// validateCreateOAuthClientRequest validates the fields of a CreateOAuthClientRequest,
+// every scope must be a valid route pattern.
+func validateCreateOAuthClientRequest(req domain.CreateOAuthClientRequest) lib.APIError {
+	if len(req.Name) > maxClientNameLength {
+		return lib.BadRequestError(fmt.Sprintf("client name must be at most %d characters", maxClientNameLength))
+	}
+
+	if len(req.Scopes) == 0 {
+		return lib.BadRequestError("at least one scope must be provided")
+	}
+
+	for _, scope := range req.Scopes {
+		if err := policy.ValidatePattern(scope); err != nil {
+			return lib.BadRequestError(scope + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateCreateAPIKeyRequest validates the fields of a CreateAPIKeyRequest,
+// every scope must be a valid route pattern and every allowed ip an address or CIDR prefix.
+func validateCreateAPIKeyRequest(req domain.CreateAPIKeyRequest) lib.APIError {
+	if apiErr := validateAPIKeyLabel(req.Label); apiErr != nil {
+		return apiErr
+	}
+
+	if len(req.Scopes) == 0 {
+		return lib.BadRequestError("at least one scope must be provided")
+	}
+
+	for _, scope := range req.Scopes {
+		if err := policy.ValidatePattern(scope); err != nil {
+			return lib.BadRequestError(scope + ": " + err.Error())
+		}
+	}
+
+	if err := apikey.ValidateAllowlist(req.AllowedIPs); err != nil {
+		return lib.BadRequestError(err.Error())
+	}
+
+	return nil
+}
+
+func validateAPIKeyLabel(label string) lib.APIError {
+	if strings.TrimSpace(label) == "" || len(label) > maxAPIKeyLabelLength {
+		return lib.BadRequestError(fmt.Sprintf("label must be 1 to %d characters", maxAPIKeyLabelLength))
+	}
+
+	return nil
+}
+
+// clientInfoFromContext returns the client of the request, the ip and user agent set in the context by the handlers.
+func clientInfoFromContext(ctx context.Context) domain.ClientInfo {
+	ip, _ := ctx.Value(domain.ClientIPKey).(string)
+	userAgent, _ := ctx.Value(domain.UserAgentKey).(string)
+
+	return newClientInfo(ip, userAgent)
+}
+
+// newClientInfo returns the client sessions and auth events are recorded with, the user agent is cut
+// to maxUserAgentLength bytes at a character boundary, so it's stored as valid UTF-8.
+func newClientInfo(ip string, userAgent string) domain.ClientInfo {
+	userAgent = strings.TrimSpace(userAgent)
+
+	if len(userAgent) > maxUserAgentLength {
+		end := maxUserAgentLength
+		for end > 0 && !utf8.RuneStart(userAgent[end]) {
+			end--
+		}
+
+		userAgent = userAgent[:end]
+	}
+
+	return domain.ClientInfo{IP: strings.TrimSpace(ip), UserAgent: strings.ToValidUTF8(userAgent, "")}
+}
+
+// markCurrentSession marks the session with the id of the access token as the current one,
+// no session is marked for tokens without a session id.
+func markCurrentSession(sessions []domain.Session, sessionID string) {
+	for i := range sessions {
+		sessions[i].Current = sessionID != "" && sessions[i].ID == sessionID
+	}
+}
+
+// validateRevokeSession checks a user can revoke the session, the user must be known and the session id
+// a uuid, sessions of other users are then left alone by the query scoped to the user.
+func validateRevokeSession(userID string, sessionID string) lib.APIError {
+	if userID == "" {
+		return lib.UnauthorizedError("sessions can only be revoked by their user")
+	}
+
+	if !sessionIDRegex.MatchString(sessionID) {
+		return lib.BadRequestError("session id must be a valid uuid")
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/ashtishad/instabid-wallet/auth-api/domain"
@@ -18,7 +20,7 @@ func TestValidateLoginRequest(t *testing.T) {
 		{domain.LoginRequest{Email: "test@email.com", Password: "password"}, false, ""},
 		{domain.LoginRequest{Username: "invalid user", Password: "password"}, true, "invalid username: must be 7-64 alphanumeric characters with no spaces"},
 		{domain.LoginRequest{Email: "invalid-email", Password: "password"}, true, "invalid email, you entered invalid-email"},
-		{domain.LoginRequest{Username: "testUser", Password: "short"}, true, "password must be at least 8 characters long and no more than 32 characters"},
+		{domain.LoginRequest{Username: "testUser", Password: "short"}, true, "password must be at least 8 characters long and no more than 128 characters"},
 	}
 
 	for _, tc := range testCases {
@@ -38,3 +40,304 @@ func TestValidateLoginRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateResetPasswordRequest(t *testing.T) {
+	testCases := []struct {
+		name   string
+		req    domain.ResetPasswordRequest
+		errMsg string
+	}{
+		{"Empty", domain.ResetPasswordRequest{}, "either username or email must be provided"},
+		{"Both", domain.ResetPasswordRequest{Username: "testUser", Email: "test@email.com"},
+			"password reset can't be requested with both username and email"},
+		{"Valid_Username", domain.ResetPasswordRequest{Username: "testUser"}, ""},
+		{"Valid_Email", domain.ResetPasswordRequest{Email: "test@email.com"}, ""},
+		{"Invalid_Username", domain.ResetPasswordRequest{Username: "invalid user"},
+			"invalid username: must be 7-64 alphanumeric characters with no spaces"},
+		{"Invalid_Email", domain.ResetPasswordRequest{Email: "invalid-email"}, "invalid email, you entered invalid-email"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateResetPasswordRequest(tc.req)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("expected error message %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestValidateCreateRoleRequest(t *testing.T) {
+	invalidName := "role name must be 2-32 lowercase letters, digits or underscores, starting with a letter"
+
+	testCases := []struct {
+		name   string
+		req    domain.CreateRoleRequest
+		errMsg string
+	}{
+		{"Valid", domain.CreateRoleRequest{Name: "support_agent", Description: "Handles tickets"}, ""},
+		{"Too_Short", domain.CreateRoleRequest{Name: "a"}, invalidName},
+		{"Uppercase", domain.CreateRoleRequest{Name: "Support"}, invalidName},
+		{"Leading_Digit", domain.CreateRoleRequest{Name: "1support"}, invalidName},
+		{"Too_Long", domain.CreateRoleRequest{Name: strings.Repeat("a", 33)}, invalidName},
+		{"Long_Description", domain.CreateRoleRequest{Name: "support", Description: strings.Repeat("a", 257)},
+			"role description must be at most 256 characters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCreateRoleRequest(tc.req)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("expected error message %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatalf("newRecoveryCode() unexpected error = %v", err)
+	}
+
+	if len(code) != 17 || code[8] != '-' || code != strings.ToLower(code) {
+		t.Errorf("newRecoveryCode() = %q, want 8 lowercase characters, a dash and 8 more", code)
+	}
+
+	testCases := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"Formatted", "abcdefgh-ijklmnop", "abcdefghijklmnop"},
+		{"Uppercase", "ABCDEFGH-IJKLMNOP", "abcdefghijklmnop"},
+		{"Spaces", " abcdefgh ijklmnop ", "abcdefghijklmnop"},
+		{"Unformatted", "abcdefghijklmnop", "abcdefghijklmnop"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeRecoveryCode(tc.code); got != tc.want {
+				t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveScopes(t *testing.T) {
+	allowed := []string{"GET:/users/*", "POST:/users"}
+
+	testCases := []struct {
+		name      string
+		requested string
+		allowed   []string
+		want      []string
+		wantOk    bool
+	}{
+		{"Default_All_Allowed", "", allowed, allowed, true},
+		{"Subset", "POST:/users", allowed, []string{"POST:/users"}, true},
+		{"Extra_Spaces", "  GET:/users/*   POST:/users ", allowed, allowed, true},
+		{"Not_Allowed", "POST:/users DELETE:/users/*", allowed, nil, false},
+		{"No_Allowed_Scopes", "", nil, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := resolveScopes(tc.requested, tc.allowed)
+			if ok != tc.wantOk {
+				t.Fatalf("resolveScopes() ok = %v, want %v", ok, tc.wantOk)
+			}
+
+			if ok && strings.Join(got, " ") != strings.Join(tc.want, " ") {
+				t.Errorf("resolveScopes() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateCreateOAuthClientRequest(t *testing.T) {
+	testCases := []struct {
+		name   string
+		req    domain.CreateOAuthClientRequest
+		errMsg string
+	}{
+		{"Valid", domain.CreateOAuthClientRequest{Name: "wallet-api", Scopes: []string{"GET:/users/*"}}, ""},
+		{"No_Scopes", domain.CreateOAuthClientRequest{Name: "wallet-api"}, "at least one scope must be provided"},
+		{"Invalid_Scope", domain.CreateOAuthClientRequest{Name: "wallet-api", Scopes: []string{"users:read"}},
+			"users:read: route pattern must look like METHOD:/path, e.g. GET:/users/*"},
+		{"Long_Name", domain.CreateOAuthClientRequest{Name: strings.Repeat("a", 129), Scopes: []string{"GET:/users"}},
+			"client name must be at most 128 characters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCreateOAuthClientRequest(tc.req)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("expected error message %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestValidateCreateAPIKeyRequest(t *testing.T) {
+	scopes := []string{"POST:/users/*"}
+
+	testCases := []struct {
+		name   string
+		req    domain.CreateAPIKeyRequest
+		errMsg string
+	}{
+		{"Valid", domain.CreateAPIKeyRequest{Label: "checkout", Scopes: scopes,
+			AllowedIPs: []string{"203.0.113.7", "10.0.0.0/8"}}, ""},
+		{"Blank_Label", domain.CreateAPIKeyRequest{Label: "  ", Scopes: scopes}, "label must be 1 to 128 characters"},
+		{"Long_Label", domain.CreateAPIKeyRequest{Label: strings.Repeat("a", 129), Scopes: scopes},
+			"label must be 1 to 128 characters"},
+		{"No_Scopes", domain.CreateAPIKeyRequest{Label: "checkout"}, "at least one scope must be provided"},
+		{"Invalid_Scope", domain.CreateAPIKeyRequest{Label: "checkout", Scopes: []string{"users:write"}},
+			"users:write: route pattern must look like METHOD:/path, e.g. GET:/users/*"},
+		{"Invalid_IP", domain.CreateAPIKeyRequest{Label: "checkout", Scopes: scopes, AllowedIPs: []string{"shop.example"}},
+			"allowlist entries must be IP addresses or CIDR prefixes: shop.example"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCreateAPIKeyRequest(tc.req)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("expected error message %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestClientInfoFromContext(t *testing.T) {
+	longUserAgent := strings.Repeat("a", 600)
+	// a multi-byte character straddling the length limit is left out as a whole
+	straddling := strings.Repeat("a", 511) + "é" + "b"
+
+	testCases := []struct {
+		name      string
+		ip        any
+		userAgent any
+		want      domain.ClientInfo
+	}{
+		{"Both_Set", "203.0.113.7", "Mozilla/5.0", domain.ClientInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"}},
+		{"None_Set", nil, nil, domain.ClientInfo{}},
+		{"Not_Strings", 42, []byte("Mozilla/5.0"), domain.ClientInfo{}},
+		{"Padded", " 203.0.113.7 ", " curl/8.0 ", domain.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"}},
+		{"Long_User_Agent", "::1", longUserAgent, domain.ClientInfo{IP: "::1", UserAgent: longUserAgent[:512]}},
+		{"Straddling_Character", "::1", straddling, domain.ClientInfo{IP: "::1", UserAgent: straddling[:511]}},
+		{"Invalid_UTF8", "::1", "curl\xff/8.0", domain.ClientInfo{IP: "::1", UserAgent: "curl/8.0"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.ip != nil {
+				ctx = context.WithValue(ctx, domain.ClientIPKey, tc.ip)
+			}
+
+			if tc.userAgent != nil {
+				ctx = context.WithValue(ctx, domain.UserAgentKey, tc.userAgent)
+			}
+
+			if got := clientInfoFromContext(ctx); got != tc.want {
+				t.Errorf("clientInfoFromContext() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMarkCurrentSession(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sessionID string
+		want      []bool
+	}{
+		{"Current_Session", "s2", []bool{false, true, false}},
+		{"Unknown_Session", "s4", []bool{false, false, false}},
+		{"No_Session_ID", "", []bool{false, false, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a stale mark is cleared, sessions without an id never match tokens without a session id
+			sessions := []domain.Session{{ID: "s1", Current: true}, {ID: "s2"}, {ID: ""}}
+
+			markCurrentSession(sessions, tc.sessionID)
+
+			for i, s := range sessions {
+				if s.Current != tc.want[i] {
+					t.Errorf("session %d current = %t, want %t", i, s.Current, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRevokeSession(t *testing.T) {
+	const sessionID = "3f1c2a9e-8b4d-4c6e-9a7f-1d2e3c4b5a69"
+
+	testCases := []struct {
+		name      string
+		userID    string
+		sessionID string
+		errMsg    string
+	}{
+		{"Valid", "9b2d8c1e-4f3a-4e5b-8c7d-6a5b4c3d2e1f", sessionID, ""},
+		{"Uppercase_Session_ID", "9b2d8c1e-4f3a-4e5b-8c7d-6a5b4c3d2e1f", strings.ToUpper(sessionID), ""},
+		{"No_User", "", sessionID, "sessions can only be revoked by their user"},
+		{"Empty_Session_ID", "9b2d8c1e-4f3a-4e5b-8c7d-6a5b4c3d2e1f", "", "session id must be a valid uuid"},
+		{"Malformed_Session_ID", "9b2d8c1e-4f3a-4e5b-8c7d-6a5b4c3d2e1f", "3f1c2a9e-8b4d", "session id must be a valid uuid"},
+		{"Injected_Session_ID", "9b2d8c1e-4f3a-4e5b-8c7d-6a5b4c3d2e1f", sessionID + "' OR '1'='1",
+			"session id must be a valid uuid"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRevokeSession(tc.userID, tc.sessionID)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %q", err.Error())
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("expected error message %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+type ImpersonationService interface {
+	Impersonate(ctx context.Context, admin *domain.AccessTokenClaims, userID string,
+		req domain.ImpersonateRequest) (*domain.ImpersonationResponse, lib.APIError)
+	FindByUserID(ctx context.Context, userID string) ([]domain.Impersonation, lib.APIError)
+}
+
+type DefaultImpersonationService struct {
+	repo     domain.ImpersonationRepository
+	authRepo domain.AuthRepository
+	keys     *jwtutils.KeySet
+	l        *slog.Logger
+}
+
+func NewImpersonationService(repo domain.ImpersonationRepository, authRepo domain.AuthRepository,
+	keys *jwtutils.KeySet, l *slog.Logger) DefaultImpersonationService {
+	return DefaultImpersonationService{
+		repo:     repo,
+		authRepo: authRepo,
+		keys:     keys,
+		l:        l,
+	}
+}
+
+// Impersonate issues a short-lived impersonation token for an admin to see the product as the user sees it.
+// The token has the claims of the user and the admin in its act claim, it can't be refreshed.
+// Admins can't be impersonated and a reason is required, it's shown to the user with every request made.
+func (s DefaultImpersonationService) Impersonate(ctx context.Context, admin *domain.AccessTokenClaims, userID string,
+	req domain.ImpersonateRequest) (*domain.ImpersonationResponse, lib.APIError) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > domain.MaxImpersonationReasonLen {
+		return nil, lib.BadRequestError("reason must be provided, at most 512 characters")
+	}
+
+	if admin.Act != nil || userID == admin.UserID {
+		return nil, lib.BadRequestError("can't impersonate yourself or while impersonating")
+	}
+
+	login, apiErr := s.authRepo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if login.Role == domain.RoleAdmin {
+		return nil, lib.BadRequestError("admins can't be impersonated")
+	}
+
+	tokenID, err := securetoken.Generate(tokenIDSize)
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating token id", "err", err.Error())
+		return nil, lib.InternalServerError("cannot generate access token", err)
+	}
+
+	expiresAt := time.Now().Add(domain.ImpersonationTokenDuration)
+
+	impersonationID, apiErr := s.repo.Create(ctx, domain.Impersonation{
+		AdminID:   admin.UserID,
+		UserID:    login.UserID,
+		Reason:    req.Reason,
+		IP:        clientInfoFromContext(ctx).IP,
+		ExpiresAt: expiresAt,
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	claims := login.ClaimsForImpersonation(admin.UserID, impersonationID, tokenID)
+
+	accessToken, apiErr := domain.NewAuthToken(claims, s.keys, s.l).NewAccessToken()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.l.InfoContext(ctx, "impersonation started", "impersonationId", impersonationID, "adminId", admin.UserID,
+		"userId", login.UserID, "reason", req.Reason)
+
+	return &domain.ImpersonationResponse{
+		AccessToken:     accessToken,
+		ImpersonationID: impersonationID,
+		ExpiresAt:       claims.ExpiresAt.Time,
+		Login:           *login,
+	}, nil
+}
+
+// FindByUserID returns the impersonations of a user with every request made with them.
+func (s DefaultImpersonationService) FindByUserID(ctx context.Context, userID string) ([]domain.Impersonation,
+	lib.APIError) {
+	return s.repo.FindByUserID(ctx, userID)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const (
+	// loginFailuresResetAfter restarts counting failures once none happened for this long.
+	loginFailuresResetAfter = time.Hour
+	maxBackoffShift         = 16
+
+	loginKeyAccount = "account:"
+	loginKeyMFA     = "mfa:"
+	loginKeyIP      = "ip:"
+)
+
+// attemptPolicy decides how long failed logins delay the next attempt. The first freeAttempts failures
+// don't delay, every further one doubles the delay starting at a second up to maxDelay,
+// reaching lockoutAfter failures rejects every attempt for lockout.
+type attemptPolicy struct {
+	freeAttempts int
+	lockoutAfter int
+	maxDelay     time.Duration
+	lockout      time.Duration
+}
+
+var (
+	accountAttempts = attemptPolicy{freeAttempts: 3, lockoutAfter: 10, maxDelay: 5 * time.Minute,
+		lockout: 30 * time.Minute}
+	ipAttempts = attemptPolicy{freeAttempts: 20, lockoutAfter: 100, maxDelay: 5 * time.Minute,
+		lockout: 30 * time.Minute}
+)
+
+// retryAfter returns how long to wait until the next login attempt is allowed, zero if it's allowed now.
+func (p attemptPolicy) retryAfter(f *domain.LoginFailure, now time.Time) time.Duration {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+
+	if f.Failures < p.freeAttempts || now.Sub(f.LastFailedAt) >= loginFailuresResetAfter {
+		return 0
+	}
+
+	delay := time.Second << min(f.Failures-p.freeAttempts, maxBackoffShift)
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+
+	if wait := f.LastFailedAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// accountAttemptKey tracks failures per account by the identifier as entered, whether the account exists or not,
+// so lockouts don't reveal which accounts exist.
+func accountAttemptKey(field string, identifier string) string {
+	return loginKeyAccount + field + ":" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// mfaAttemptKey tracks wrong MFA codes per user, a correct password doesn't reset it,
+// so getting new challenges doesn't allow more guesses.
+func mfaAttemptKey(userID string) string {
+	return loginKeyMFA + userID
+}
+
+// mfaAttemptKeys returns the keys wrong MFA codes of a user are tracked by with their policies,
+// the user's MFA key and the client ip, which failed logins count against too.
+func mfaAttemptKeys(ctx context.Context, userID string) map[string]attemptPolicy {
+	keys := map[string]attemptPolicy{mfaAttemptKey(userID): accountAttempts}
+
+	if ip, ok := ctx.Value(domain.ClientIPKey).(string); ok && ip != "" {
+		keys[loginKeyIP+ip] = ipAttempts
+	}
+
+	return keys
+}
+
+// loginAttemptKeys returns the keys failures of a login request are tracked by with their policies,
+// the account key and the client ip.
+func loginAttemptKeys(ctx context.Context, accountKey string) map[string]attemptPolicy {
+	keys := map[string]attemptPolicy{accountKey: accountAttempts}
+
+	if ip, ok := ctx.Value(domain.ClientIPKey).(string); ok && ip != "" {
+		keys[loginKeyIP+ip] = ipAttempts
+	}
+
+	return keys
+}
+
+// loginAttempt is an attempt reserved against its keys before the credentials are checked,
+// with the failures counted per key including it.
+type loginAttempt struct {
+	keys     map[string]attemptPolicy
+	failures map[string]int
+}
+
+// reserveLoginAttempt counts an attempt against every key before the credentials are checked, so concurrent
+// attempts can't all pass before any of them failed. It returns 429 and releases the attempt
+// if the account or the client ip has to wait before trying again.
+func (s DefaultAuthService) reserveLoginAttempt(ctx context.Context,
+	keys map[string]attemptPolicy) (*loginAttempt, lib.APIError) {
+	now := time.Now()
+	attempt := &loginAttempt{keys: make(map[string]attemptPolicy, len(keys)), failures: make(map[string]int)}
+
+	for key, policy := range keys {
+		f, apiErr := s.attemptRepo.Reserve(ctx, key, now.Add(-loginFailuresResetAfter))
+		if apiErr != nil {
+			s.releaseLoginAttempt(ctx, attempt)
+			return nil, apiErr
+		}
+
+		attempt.keys[key] = policy
+		attempt.failures[key] = f.Failures + 1
+
+		if wait := policy.retryAfter(f, now); wait > 0 {
+			s.releaseLoginAttempt(ctx, attempt)
+
+			return nil, lib.RateLimitError(fmt.Sprintf("too many failed login attempts, try again in %s",
+				wait.Round(time.Second)))
+		}
+	}
+
+	return attempt, nil
+}
+
+// failLoginAttempt keeps a reserved attempt counted as a failure, locking the keys that reached
+// their lockout threshold.
+func (s DefaultAuthService) failLoginAttempt(ctx context.Context, attempt *loginAttempt) lib.APIError {
+	until := time.Now()
+
+	for key, policy := range attempt.keys {
+		if attempt.failures[key] < policy.lockoutAfter {
+			continue
+		}
+
+		s.l.WarnContext(ctx, "login locked after repeated failures", "key", key, "failures", attempt.failures[key])
+
+		if apiErr := s.attemptRepo.Lock(ctx, key, until.Add(policy.lockout)); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return nil
+}
+
+// succeedLoginAttempt forgets the failures of clearKey once an attempt succeeded and releases the attempt
+// from the other keys, the client ip keeps its failures, logging in to an account of the attacker
+// must not reset them.
+func (s DefaultAuthService) succeedLoginAttempt(ctx context.Context, attempt *loginAttempt,
+	clearKey string) lib.APIError {
+	if apiErr := s.attemptRepo.Clear(ctx, clearKey); apiErr != nil {
+		return apiErr
+	}
+
+	for key := range attempt.keys {
+		if key == clearKey {
+			continue
+		}
+
+		if apiErr := s.attemptRepo.Release(ctx, key); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return nil
+}
+
+// releaseLoginAttempt takes back an attempt that wasn't a failed guess, failures to do so are only logged,
+// they leave one failure too many counted.
+func (s DefaultAuthService) releaseLoginAttempt(ctx context.Context, attempt *loginAttempt) {
+	for key := range attempt.keys {
+		if apiErr := s.attemptRepo.Release(ctx, key); apiErr != nil {
+			s.l.WarnContext(ctx, "login attempt not released", "err", apiErr.WithCauses(), "key", key)
+		}
+	}
+}
+
+// Unlock lifts the lockout of a user's account and forgets its failed logins, by email and by username,
+// and its wrong MFA codes.
+func (s DefaultAuthService) Unlock(ctx context.Context, userID string) lib.APIError {
+	login, apiErr := s.repo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return s.attemptRepo.Clear(ctx, accountAttemptKey(domain.UserCredentialEmail, login.Email),
+		accountAttemptKey(domain.UserCredentialUsername, login.Username), mfaAttemptKey(login.UserID))
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+func TestAttemptPolicyRetryAfter(t *testing.T) {
+	now := time.Now()
+	policy := attemptPolicy{freeAttempts: 3, lockoutAfter: 10, maxDelay: time.Minute, lockout: 30 * time.Minute}
+
+	testCases := []struct {
+		name    string
+		failure domain.LoginFailure
+		want    time.Duration
+	}{
+		{"No_Failures", domain.LoginFailure{}, 0},
+		{"Free_Attempts", domain.LoginFailure{Failures: 2, LastFailedAt: now}, 0},
+		{"First_Delay", domain.LoginFailure{Failures: 3, LastFailedAt: now}, time.Second},
+		{"Doubled_Delay", domain.LoginFailure{Failures: 5, LastFailedAt: now}, 4 * time.Second},
+		{"Delay_Elapsed", domain.LoginFailure{Failures: 5, LastFailedAt: now.Add(-5 * time.Second)}, 0},
+		{"Delay_Partly_Elapsed", domain.LoginFailure{Failures: 5, LastFailedAt: now.Add(-time.Second)}, 3 * time.Second},
+		{"Max_Delay", domain.LoginFailure{Failures: 9, LastFailedAt: now}, time.Minute},
+		{"Large_Failures", domain.LoginFailure{Failures: 1000, LastFailedAt: now}, time.Minute},
+		{"Locked", domain.LoginFailure{Failures: 10, LastFailedAt: now, LockedUntil: now.Add(20 * time.Minute)},
+			20 * time.Minute},
+		{"Lock_Expired", domain.LoginFailure{Failures: 10, LastFailedAt: now.Add(-30 * time.Minute),
+			LockedUntil: now.Add(-time.Second)}, 0},
+		{"Reset_After_Quiet_Period", domain.LoginFailure{Failures: 9, LastFailedAt: now.Add(-loginFailuresResetAfter)}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := policy.retryAfter(&tc.failure, now); got != tc.want {
+				t.Errorf("retryAfter() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// memAttemptRepo keeps login failures in memory like LoginAttemptRepoDB, without resets.
+type memAttemptRepo struct {
+	failures map[string]*domain.LoginFailure
+}
+
+func (r *memAttemptRepo) Reserve(_ context.Context, key string, _ time.Time) (*domain.LoginFailure, lib.APIError) {
+	f, ok := r.failures[key]
+	if !ok {
+		f = &domain.LoginFailure{Key: key}
+		r.failures[key] = f
+	}
+
+	prior := *f
+	f.Failures++
+	f.LastFailedAt = time.Now()
+
+	return &prior, nil
+}
+
+func (r *memAttemptRepo) Release(_ context.Context, keys ...string) lib.APIError {
+	for _, key := range keys {
+		if f, ok := r.failures[key]; ok && f.Failures > 0 {
+			f.Failures--
+		}
+	}
+
+	return nil
+}
+
+func (r *memAttemptRepo) Lock(_ context.Context, key string, until time.Time) lib.APIError {
+	r.failures[key].LockedUntil = until
+	return nil
+}
+
+func (r *memAttemptRepo) Clear(_ context.Context, keys ...string) lib.APIError {
+	for _, key := range keys {
+		delete(r.failures, key)
+	}
+
+	return nil
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	repo := &memAttemptRepo{failures: make(map[string]*domain.LoginFailure)}
+	s := DefaultAuthService{attemptRepo: repo, l: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	keys := map[string]attemptPolicy{"account:email:a@b.c": accountAttempts}
+
+	// attempts in flight count before any of them failed, a burst beyond the free attempts is rejected
+	for i := 0; i < accountAttempts.freeAttempts; i++ {
+		if _, apiErr := s.reserveLoginAttempt(context.Background(), keys); apiErr != nil {
+			t.Fatalf("reserveLoginAttempt() attempt %d unexpected error = %v", i+1, apiErr)
+		}
+	}
+
+	_, apiErr := s.reserveLoginAttempt(context.Background(), keys)
+	if apiErr == nil || apiErr.Code() != http.StatusTooManyRequests {
+		t.Fatalf("reserveLoginAttempt() error = %v, want 429", apiErr)
+	}
+
+	// the rejected attempt is released, it wasn't a guess
+	if got := repo.failures["account:email:a@b.c"].Failures; got != accountAttempts.freeAttempts {
+		t.Errorf("failures = %d, want %d", got, accountAttempts.freeAttempts)
+	}
+}
+
+func TestFailLoginAttempt(t *testing.T) {
+	repo := &memAttemptRepo{failures: make(map[string]*domain.LoginFailure)}
+	s := DefaultAuthService{attemptRepo: repo, l: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	key := "mfa:user-1"
+	repo.failures[key] = &domain.LoginFailure{Key: key, Failures: accountAttempts.lockoutAfter}
+
+	attempt := &loginAttempt{keys: map[string]attemptPolicy{key: accountAttempts},
+		failures: map[string]int{key: accountAttempts.lockoutAfter}}
+
+	if apiErr := s.failLoginAttempt(context.Background(), attempt); apiErr != nil {
+		t.Fatalf("failLoginAttempt() unexpected error = %v", apiErr)
+	}
+
+	if !repo.failures[key].LockedUntil.After(time.Now()) {
+		t.Errorf("failLoginAttempt() didn't lock %s after %d failures", key, accountAttempts.lockoutAfter)
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+const (
+	magicLinkRequestsPerEmail = 3
+	magicLinkRequestsPerIP    = 10
+	magicLinkRequestsWindow   = 15 * time.Minute
+)
+
+type MagicLinkService interface {
+	RequestLink(ctx context.Context, req domain.MagicLinkRequest) lib.APIError
+	Consume(ctx context.Context, req domain.ConsumeMagicLinkRequest) (*domain.LoginResponse, lib.APIError)
+}
+
+// DefaultMagicLinkService logs users in without a password, with single use links mailed to their verified email.
+type DefaultMagicLinkService struct {
+	repo        domain.AuthRepository
+	tokenRepo   domain.OneTimeTokenRepository
+	authService AuthService
+	links       linkSender
+	limiter     requestLimiter
+	l           *slog.Logger
+}
+
+func NewMagicLinkService(repo domain.AuthRepository, tokenRepo domain.OneTimeTokenRepository,
+	authService AuthService, n notifier.Notifier, l *slog.Logger) DefaultMagicLinkService {
+	return DefaultMagicLinkService{
+		repo:        repo,
+		tokenRepo:   tokenRepo,
+		authService: authService,
+		links:       linkSender{tokenRepo: tokenRepo, notifier: n, l: l},
+		limiter:     newRequestLimiter(magicLinkRequestsPerEmail, magicLinkRequestsPerIP, magicLinkRequestsWindow),
+		l:           l,
+	}
+}
+
+// RequestLink sends a single use login link to the active user with a verified email.
+// To avoid revealing which accounts exist, it succeeds without sending anything if there is no such user.
+// Requests are rate limited per email and per client ip.
+func (s DefaultMagicLinkService) RequestLink(ctx context.Context, req domain.MagicLinkRequest) lib.APIError {
+	if err := lib.ValidateEmail(req.Email); err != nil {
+		return lib.BadRequestError(err.Error())
+	}
+
+	email := strings.ToLower(req.Email)
+
+	if !s.limiter.allow(ctx, email) {
+		return lib.RateLimitError("too many login link requests, try again later")
+	}
+
+	login, apiErr := s.repo.FindByIdentity(ctx, domain.LoginRequest{Email: email})
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusNotFound {
+			s.l.InfoContext(ctx, "login link requested for unknown user", "identity", email)
+			return nil
+		}
+
+		return apiErr
+	}
+
+	// links are only sent to emails their active users verified owning
+	if login.Status != domain.StatusActive || !login.EmailVerified {
+		s.l.InfoContext(ctx, "login link requested for inactive or unverified user", "userId", login.UserID)
+		return nil
+	}
+
+	return s.links.send(ctx, login, oneTimeLink{
+		purpose:  domain.TokenPurposeMagicLink,
+		duration: domain.MagicLinkTokenDuration,
+		subject:  "Your login link",
+		action:   "log in",
+		path:     "/login/magic-link",
+	})
+}
+
+// Consume exchanges a login link token for a session like a password login, users with MFA enabled
+// still get a challenge token only. Each token works once, and only for users that are still active
+// with a verified email.
+func (s DefaultMagicLinkService) Consume(ctx context.Context,
+	req domain.ConsumeMagicLinkRequest) (*domain.LoginResponse, lib.APIError) {
+	if req.Token == "" {
+		return nil, lib.BadRequestError("login token must be provided")
+	}
+
+	userID, apiErr := s.tokenRepo.Consume(ctx, domain.TokenPurposeMagicLink, securetoken.Hash(req.Token))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	login, apiErr := s.repo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if login.Status != domain.StatusActive || !login.EmailVerified {
+		return nil, lib.BadRequestError("token is invalid or has expired")
+	}
+
+	return s.authService.CompleteLogin(ctx, login, domain.AuthEvent{UserID: login.UserID, Identifier: login.Email,
+		Event: domain.AuthEventLoginSucceeded, Reason: domain.AuthReasonMagicLink})
+}
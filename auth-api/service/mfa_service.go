@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+	"github.com/ashtishad/instabid-wallet/lib/totp"
+)
+
+type MFAService interface {
+	Enroll(ctx context.Context, claims *domain.AccessTokenClaims) (*domain.MFAEnrollment, lib.APIError)
+	Confirm(ctx context.Context, userID string, req domain.ConfirmMFARequest) ([]string, lib.APIError)
+	Reset(ctx context.Context, userID string) lib.APIError
+}
+
+type DefaultMFAService struct {
+	repo domain.MFARepository
+	l    *slog.Logger
+}
+
+func NewMFAService(repo domain.MFARepository, l *slog.Logger) DefaultMFAService {
+	return DefaultMFAService{
+		repo: repo,
+		l:    l,
+	}
+}
+
+// Enroll generates a new TOTP secret for the authenticated user, it's enforced on login once confirmed.
+// Enrolling again before confirming replaces the secret.
+func (s DefaultMFAService) Enroll(ctx context.Context, claims *domain.AccessTokenClaims) (*domain.MFAEnrollment,
+	lib.APIError) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating totp secret", "err", err.Error())
+		return nil, lib.InternalServerError("cannot generate mfa secret", err)
+	}
+
+	if apiErr := s.repo.SaveSecret(ctx, claims.UserID, secret); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return &domain.MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(domain.MFAIssuer, claims.Email, secret),
+	}, nil
+}
+
+// Confirm enables MFA with the first code of the enrolled secret and returns new recovery codes,
+// they are shown only once, only their hashes are stored.
+func (s DefaultMFAService) Confirm(ctx context.Context, userID string, req domain.ConfirmMFARequest) ([]string,
+	lib.APIError) {
+	mfa, apiErr := s.repo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusNotFound {
+			return nil, lib.BadRequestError("mfa must be enrolled before confirming")
+		}
+
+		return nil, apiErr
+	}
+
+	if mfa.Confirmed {
+		return nil, lib.ConflictError("mfa is already enabled")
+	}
+
+	step, ok := totp.Validate(mfa.Secret, req.Code, time.Now())
+	if !ok {
+		return nil, lib.BadRequestError("mfa code is invalid")
+	}
+
+	codes := make([]string, domain.RecoveryCodeCount)
+	hashes := make([]string, domain.RecoveryCodeCount)
+
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			s.l.ErrorContext(ctx, "failed generating recovery code", "err", err.Error())
+			return nil, lib.InternalServerError("cannot generate recovery codes", err)
+		}
+
+		codes[i] = code
+		hashes[i] = securetoken.Hash(normalizeRecoveryCode(code))
+	}
+
+	if apiErr = s.repo.Confirm(ctx, userID, step, hashes); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return codes, nil
+}
+
+// Reset disables MFA of a user, e.g. after losing the authenticator, the user may enroll again.
+func (s DefaultMFAService) Reset(ctx context.Context, userID string) lib.APIError {
+	return s.repo.Delete(ctx, userID)
+}
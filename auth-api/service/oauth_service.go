@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+type OAuthService interface {
+	ClientCredentials(ctx context.Context, req domain.ClientCredentialsRequest) (*domain.TokenResponse,
+		*domain.OAuthError)
+	AuthenticateClient(ctx context.Context, clientID string, secret string) (*domain.OAuthClient, *domain.OAuthError)
+	CreateClient(ctx context.Context, req domain.CreateOAuthClientRequest) (*domain.OAuthClient, string, lib.APIError)
+	FindClients(ctx context.Context) ([]domain.OAuthClient, lib.APIError)
+	DisableClient(ctx context.Context, clientID string) lib.APIError
+}
+
+type DefaultOAuthService struct {
+	repo domain.OAuthClientRepository
+	keys *jwtutils.KeySet
+	l    *slog.Logger
+}
+
+func NewOAuthService(repo domain.OAuthClientRepository, keys *jwtutils.KeySet, l *slog.Logger) DefaultOAuthService {
+	return DefaultOAuthService{
+		repo: repo,
+		keys: keys,
+		l:    l,
+	}
+}
+
+// ClientCredentials issues an access token to an authenticated service client, RFC 6749 section 4.4.
+// Without a requested scope the token gets every scope of the client, otherwise exactly the requested ones,
+// each of them must be allowed for the client.
+func (s DefaultOAuthService) ClientCredentials(ctx context.Context,
+	req domain.ClientCredentialsRequest) (*domain.TokenResponse, *domain.OAuthError) {
+	client, oauthErr := s.AuthenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	scopes, ok := resolveScopes(req.Scope, client.Scopes)
+	if !ok {
+		return nil, &domain.OAuthError{Status: http.StatusBadRequest, Code: domain.OAuthErrInvalidScope,
+			Description: "requested scope is not allowed for the client"}
+	}
+
+	tokenID, err := securetoken.Generate(tokenIDSize)
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating token id", "err", err.Error())
+		return nil, serverError()
+	}
+
+	accessToken, apiErr := domain.NewAuthToken(client.ClaimsForClient(scopes, tokenID), s.keys, s.l).NewAccessToken()
+	if apiErr != nil {
+		return nil, serverError()
+	}
+
+	return &domain.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   domain.TokenTypeBearer,
+		ExpiresIn:   int(domain.ClientAccessTokenDuration.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// AuthenticateClient returns the enabled client the id and secret belong to, or an invalid_client error.
+func (s DefaultOAuthService) AuthenticateClient(ctx context.Context, clientID string,
+	secret string) (*domain.OAuthClient, *domain.OAuthError) {
+	client, apiErr := s.repo.FindByID(ctx, clientID)
+	if apiErr != nil && apiErr.Code() != http.StatusNotFound {
+		return nil, serverError()
+	}
+
+	// hashes are compared in constant time, unknown clients are compared against an empty hash
+	var secretHash string
+	if client != nil {
+		secretHash = client.SecretHash
+	}
+
+	validSecret := subtle.ConstantTimeCompare([]byte(securetoken.Hash(secret)), []byte(secretHash)) == 1
+	if client == nil || client.Disabled || !validSecret {
+		return nil, &domain.OAuthError{Status: http.StatusUnauthorized, Code: domain.OAuthErrInvalidClient,
+			Description: "client authentication failed"}
+	}
+
+	return client, nil
+}
+
+// CreateClient registers a service client and returns it with its secret, the secret is shown only once.
+func (s DefaultOAuthService) CreateClient(ctx context.Context,
+	req domain.CreateOAuthClientRequest) (*domain.OAuthClient, string, lib.APIError) {
+	if apiErr := validateCreateOAuthClientRequest(req); apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	clientID, err := securetoken.Generate(domain.ClientIDSize)
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating client id", "err", err.Error())
+		return nil, "", lib.InternalServerError("cannot generate client credentials", err)
+	}
+
+	secret, err := securetoken.Generate(domain.ClientSecretSize)
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed generating client secret", "err", err.Error())
+		return nil, "", lib.InternalServerError("cannot generate client credentials", err)
+	}
+
+	client, apiErr := s.repo.Create(ctx, domain.OAuthClient{
+		ClientID:   clientID,
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		SecretHash: securetoken.Hash(secret),
+	})
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	return client, secret, nil
+}
+
+func (s DefaultOAuthService) FindClients(ctx context.Context) ([]domain.OAuthClient, lib.APIError) {
+	return s.repo.FindAll(ctx)
+}
+
+// DisableClient stops a client from getting new access tokens, issued ones work until they expire.
+func (s DefaultOAuthService) DisableClient(ctx context.Context, clientID string) lib.APIError {
+	return s.repo.Disable(ctx, clientID)
+}
+
+func serverError() *domain.OAuthError {
+	return &domain.OAuthError{Status: http.StatusInternalServerError, Code: domain.OAuthErrServerError}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/ratelimit"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+// oneTimeLink describes a link to the client app carrying a single use token, e.g. a password reset link.
+type oneTimeLink struct {
+	purpose  string
+	duration time.Duration
+	subject  string
+	action   string
+	path     string
+}
+
+// linkSender mails one time links to users, their tokens are kept hashed in the one time token store.
+// Sending a link invalidates the links sent to the user earlier for the same purpose.
+type linkSender struct {
+	tokenRepo domain.OneTimeTokenRepository
+	notifier  notifier.Notifier
+	l         *slog.Logger
+}
+
+func (ls linkSender) send(ctx context.Context, login *domain.Login, link oneTimeLink) lib.APIError {
+	token, err := securetoken.Generate(domain.OneTimeTokenSize)
+	if err != nil {
+		ls.l.ErrorContext(ctx, "failed generating one time token", "err", err.Error(), "purpose", link.purpose)
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	expiresAt := time.Now().Add(link.duration)
+	if apiErr := ls.tokenRepo.Save(ctx, login.UserID, link.purpose, securetoken.Hash(token),
+		expiresAt); apiErr != nil {
+		return apiErr
+	}
+
+	msg := notifier.Message{
+		To:      login.Email,
+		Subject: link.subject,
+		Body: fmt.Sprintf("Use the link below to %s, it expires in %s.\n%s%s?token=%s",
+			link.action, link.duration, os.Getenv("APP_URL"), link.path, url.QueryEscape(token)),
+	}
+
+	if err = ls.notifier.Notify(ctx, msg); err != nil {
+		ls.l.ErrorContext(ctx, "unable to send one time link", "err", err.Error(), "purpose", link.purpose)
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// requestLimiter rate limits requests that send mail per email or username and per client ip.
+type requestLimiter struct {
+	identity *ratelimit.Limiter
+	ip       *ratelimit.Limiter
+}
+
+func newRequestLimiter(perIdentity int, perIP int, window time.Duration) requestLimiter {
+	return requestLimiter{
+		identity: ratelimit.New(perIdentity, window),
+		ip:       ratelimit.New(perIP, window),
+	}
+}
+
+// allow records a request for identity from the client ip in the context and reports whether it's within limits.
+func (rl requestLimiter) allow(ctx context.Context, identity string) bool {
+	clientIP, _ := ctx.Value(domain.ClientIPKey).(string)
+	return rl.identity.Allow(strings.ToLower(identity)) && rl.ip.Allow(clientIP)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+)
+
+func TestRequestLimiterAllow(t *testing.T) {
+	rl := newRequestLimiter(2, 2, time.Minute)
+	ctxFrom := func(ip string) context.Context {
+		return context.WithValue(context.Background(), domain.ClientIPKey, ip)
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		identity string
+		want     bool
+	}{
+		{name: "First", ip: "10.0.0.1", identity: "john@example.com", want: true},
+		{name: "Same_Identity_Other_Case", ip: "10.0.0.2", identity: "John@Example.com", want: true},
+		{name: "Identity_Limit", ip: "10.0.0.3", identity: "john@example.com", want: false},
+		{name: "Other_Identity", ip: "10.0.0.1", identity: "jane@example.com", want: true},
+		{name: "IP_Limit", ip: "10.0.0.1", identity: "jim@example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rl.allow(ctxFrom(tt.ip), tt.identity); got != tt.want {
+				t.Errorf("allow(%q, %q) = %v, want %v", tt.ip, tt.identity, got, tt.want)
+			}
+		})
+	}
+}
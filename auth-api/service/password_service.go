@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/hashpass"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/password"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+const (
+	resetRequestsPerIdentity = 3
+	resetRequestsPerIP       = 10
+	resetRequestsWindow      = 15 * time.Minute
+
+	changeRequestsPerUser = 5
+	changeRequestsPerIP   = 20
+	changeRequestsWindow  = 15 * time.Minute
+)
+
+type PasswordService interface {
+	RequestReset(ctx context.Context, req domain.ResetPasswordRequest) lib.APIError
+	ConfirmReset(ctx context.Context, req domain.ConfirmResetPasswordRequest) lib.APIError
+	Change(ctx context.Context, userID string, req domain.ChangePasswordRequest) lib.APIError
+}
+
+type DefaultPasswordService struct {
+	repo          domain.AuthRepository
+	tokenRepo     domain.OneTimeTokenRepository
+	authService   AuthService
+	policy        password.Policy
+	links         linkSender
+	limiter       requestLimiter
+	changeLimiter requestLimiter
+	l             *slog.Logger
+}
+
+func NewPasswordService(repo domain.AuthRepository, tokenRepo domain.OneTimeTokenRepository, authService AuthService,
+	policy password.Policy, n notifier.Notifier, l *slog.Logger) DefaultPasswordService {
+	return DefaultPasswordService{
+		repo:          repo,
+		tokenRepo:     tokenRepo,
+		authService:   authService,
+		policy:        policy,
+		links:         linkSender{tokenRepo: tokenRepo, notifier: n, l: l},
+		limiter:       newRequestLimiter(resetRequestsPerIdentity, resetRequestsPerIP, resetRequestsWindow),
+		changeLimiter: newRequestLimiter(changeRequestsPerUser, changeRequestsPerIP, changeRequestsWindow),
+		l:             l,
+	}
+}
+
+// RequestReset sends a single use password reset link to the user found by email or username.
+// To avoid revealing which accounts exist, it succeeds without sending anything if no user is found.
+// Requests are rate limited per email or username and per client ip.
+func (s DefaultPasswordService) RequestReset(ctx context.Context, req domain.ResetPasswordRequest) lib.APIError {
+	if apiErr := validateResetPasswordRequest(req); apiErr != nil {
+		return apiErr
+	}
+
+	identity := strings.ToLower(req.Email + req.Username)
+
+	if !s.limiter.allow(ctx, identity) {
+		return lib.RateLimitError("too many password reset requests, try again later")
+	}
+
+	login, apiErr := s.repo.FindByIdentity(ctx, domain.LoginRequest{Username: req.Username, Email: req.Email})
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusNotFound {
+			s.l.InfoContext(ctx, "password reset requested for unknown user", "identity", identity)
+			return nil
+		}
+
+		return apiErr
+	}
+
+	return s.links.send(ctx, login, oneTimeLink{
+		purpose:  domain.TokenPurposePasswordReset,
+		duration: domain.PasswordResetTokenDuration,
+		subject:  "Reset your password",
+		action:   "reset your password",
+		path:     "/reset-password",
+	})
+}
+
+// ConfirmReset consumes a password reset token and sets the new password of its owner in one transaction,
+// then logs the user out of every session. The token is only consumed if the new password meets the policy.
+func (s DefaultPasswordService) ConfirmReset(ctx context.Context, req domain.ConfirmResetPasswordRequest) lib.APIError {
+	if req.Token == "" {
+		return lib.BadRequestError("reset token must be provided")
+	}
+
+	tokenHash := securetoken.Hash(req.Token)
+
+	userID, apiErr := s.tokenRepo.Peek(ctx, domain.TokenPurposePasswordReset, tokenHash)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	hashes, apiErr := s.repo.FindPasswordHashes(ctx, userID, s.policy.HistorySize)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	hashedPass, apiErr := s.hashNewPassword(ctx, userID, req.NewPassword, hashes)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	apiErr = s.repo.ResetPassword(ctx, userID, tokenHash, hashedPass, s.policy.HistorySize-1)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return s.logoutAfterPasswordChange(ctx, userID)
+}
+
+// Change sets a new password for a logged-in user who knows the current one, then logs the user out
+// of every session. Requests are rate limited per user and per client ip.
+func (s DefaultPasswordService) Change(ctx context.Context, userID string,
+	req domain.ChangePasswordRequest) lib.APIError {
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return lib.BadRequestError("current and new password must be provided")
+	}
+
+	if !s.changeLimiter.allow(ctx, userID) {
+		return lib.RateLimitError("too many password change requests, try again later")
+	}
+
+	hashes, apiErr := s.repo.FindPasswordHashes(ctx, userID, max(s.policy.HistorySize, 1))
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if _, err := hashpass.Verify(hashes[0], req.CurrentPassword); err != nil {
+		s.l.InfoContext(ctx, "password change with wrong current password", "userId", userID)
+		return lib.BadRequestError("current password is incorrect")
+	}
+
+	hashedPass, apiErr := s.hashNewPassword(ctx, userID, req.NewPassword, hashes)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return s.setPassword(ctx, userID, hashedPass)
+}
+
+// hashNewPassword checks a new password of a user against the password policy, including the history rule
+// against the user's current and previous password hashes, and hashes it. Every broken rule is returned
+// as the details of a validation error.
+func (s DefaultPasswordService) hashNewPassword(ctx context.Context, userID string, newPassword string,
+	hashes []string) (string, lib.APIError) {
+	login, apiErr := s.repo.FindByUserID(ctx, userID)
+	if apiErr != nil {
+		return "", apiErr
+	}
+
+	violations := s.policy.Check(ctx, newPassword, login.Username, login.Email)
+
+	for i := 0; i < len(hashes) && i < s.policy.HistorySize; i++ {
+		if _, err := hashpass.Verify(hashes[i], newPassword); err == nil {
+			violations = append(violations, s.policy.HistoryViolation())
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return "", lib.ValidationError("password doesn't meet the password policy", violations)
+	}
+
+	return hashpass.Generate(ctx, newPassword, s.l)
+}
+
+// setPassword stores the new password hash, keeping the replaced one in the history, and logs out every session.
+func (s DefaultPasswordService) setPassword(ctx context.Context, userID string, hashedPass string) lib.APIError {
+	if apiErr := s.repo.UpdatePassword(ctx, userID, hashedPass, s.policy.HistorySize-1); apiErr != nil {
+		return apiErr
+	}
+
+	return s.logoutAfterPasswordChange(ctx, userID)
+}
+
+// logoutAfterPasswordChange logs the user out of every session once its password changed.
+func (s DefaultPasswordService) logoutAfterPasswordChange(ctx context.Context, userID string) lib.APIError {
+	if apiErr := s.authService.LogoutAll(ctx, userID); apiErr != nil {
+		s.l.ErrorContext(ctx, "password changed but sessions were not revoked", "err", apiErr.WithCauses(),
+			"userId", userID)
+		return apiErr
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ashtishad/instabid-wallet/auth-api/domain"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+)
+
+// builtinRoles are the roles of the user_roles enum, users always have one of them, and the unverified
+// pseudo-role of users who have not verified their email, so they can't be deleted.
+var builtinRoles = map[string]bool{
+	"admin": true, "moderator": true, "merchant": true, "user": true, policy.RoleUnverified: true,
+}
+
+type RBACService interface {
+	FindRoles(ctx context.Context) ([]domain.Role, lib.APIError)
+	CreateRole(ctx context.Context, req domain.CreateRoleRequest) lib.APIError
+	DeleteRole(ctx context.Context, name string) lib.APIError
+	FindPermissions(ctx context.Context) ([]domain.Permission, lib.APIError)
+	CreatePermission(ctx context.Context, req domain.CreatePermissionRequest) (*domain.Permission, lib.APIError)
+	DeletePermission(ctx context.Context, id int64) lib.APIError
+	Grant(ctx context.Context, role string, permissionID int64, anyUser bool) lib.APIError
+	Revoke(ctx context.Context, role string, permissionID int64) lib.APIError
+}
+
+type DefaultRBACService struct {
+	repo        domain.RBACRepository
+	permissions *policy.RolePermissions
+	l           *slog.Logger
+}
+
+func NewRBACService(repo domain.RBACRepository, permissions *policy.RolePermissions,
+	l *slog.Logger) DefaultRBACService {
+	return DefaultRBACService{
+		repo:        repo,
+		permissions: permissions,
+		l:           l,
+	}
+}
+
+func (s DefaultRBACService) FindRoles(ctx context.Context) ([]domain.Role, lib.APIError) {
+	return s.repo.FindRoles(ctx)
+}
+
+func (s DefaultRBACService) CreateRole(ctx context.Context, req domain.CreateRoleRequest) lib.APIError {
+	if apiErr := validateCreateRoleRequest(req); apiErr != nil {
+		return apiErr
+	}
+
+	return s.repo.CreateRole(ctx, domain.Role{Name: req.Name, Description: req.Description})
+}
+
+func (s DefaultRBACService) DeleteRole(ctx context.Context, name string) lib.APIError {
+	if builtinRoles[name] {
+		return lib.BadRequestError(fmt.Sprintf("built-in role %s can't be deleted", name))
+	}
+
+	return s.reloadAfter(ctx, s.repo.DeleteRole(ctx, name))
+}
+
+func (s DefaultRBACService) FindPermissions(ctx context.Context) ([]domain.Permission, lib.APIError) {
+	return s.repo.FindPermissions(ctx)
+}
+
+func (s DefaultRBACService) CreatePermission(ctx context.Context,
+	req domain.CreatePermissionRequest) (*domain.Permission, lib.APIError) {
+	if err := policy.ValidatePattern(req.Route); err != nil {
+		return nil, lib.BadRequestError(err.Error())
+	}
+
+	return s.repo.CreatePermission(ctx, domain.Permission{Route: req.Route, Description: req.Description})
+}
+
+func (s DefaultRBACService) DeletePermission(ctx context.Context, id int64) lib.APIError {
+	return s.reloadAfter(ctx, s.repo.DeletePermission(ctx, id))
+}
+
+func (s DefaultRBACService) Grant(ctx context.Context, role string, permissionID int64, anyUser bool) lib.APIError {
+	return s.reloadAfter(ctx, s.repo.Grant(ctx, role, permissionID, anyUser))
+}
+
+func (s DefaultRBACService) Revoke(ctx context.Context, role string, permissionID int64) lib.APIError {
+	return s.reloadAfter(ctx, s.repo.Revoke(ctx, role, permissionID))
+}
+
+// reloadAfter reloads the policy snapshot of this instance after a successful change of grants,
+// so it's effective immediately here and within the sync interval on other instances.
+func (s DefaultRBACService) reloadAfter(ctx context.Context, apiErr lib.APIError) lib.APIError {
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if apiErr = s.permissions.Load(ctx); apiErr != nil {
+		s.l.WarnContext(ctx, "grants changed but the policy reload failed, it's retried on next sync",
+			"err", apiErr.WithCauses())
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+BEGIN;
+
+create table if not exists refresh_tokens
+(
+    id         bigserial   not null primary key,
+    token_hash varchar(64) not null unique,
+    family_id  uuid        not null,
+    user_id    uuid        not null REFERENCES users (user_id) on delete cascade,
+    used_at    timestamptz,
+    revoked_at timestamptz,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists refresh_tokens_family_id_idx on refresh_tokens (family_id);
+create index if not exists refresh_tokens_user_id_idx on refresh_tokens (user_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists user_token_revocations;
+drop table if exists revoked_tokens;
+
+commit;
@@ -0,0 +1,19 @@
+BEGIN;
+
+create table if not exists revoked_tokens
+(
+    token_id   varchar(64) not null primary key,
+    user_id    uuid        not null REFERENCES users (user_id) on delete cascade,
+    expires_at timestamptz not null,
+    revoked_at timestamptz not null default now()
+);
+
+create index if not exists revoked_tokens_expires_at_idx on revoked_tokens (expires_at);
+
+create table if not exists user_token_revocations
+(
+    user_id        uuid        not null primary key REFERENCES users (user_id) on delete cascade,
+    revoked_before timestamptz not null
+);
+
+COMMIT;
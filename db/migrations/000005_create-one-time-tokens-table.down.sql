@@ -0,0 +1,5 @@
+begin;
+
+drop table if exists one_time_tokens;
+
+commit;
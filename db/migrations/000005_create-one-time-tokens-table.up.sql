@@ -0,0 +1,16 @@
+BEGIN;
+
+create table if not exists one_time_tokens
+(
+    id         bigserial   not null primary key,
+    token_hash varchar(64) not null unique,
+    user_id    uuid        not null REFERENCES users (user_id) on delete cascade,
+    purpose    varchar(32) not null,
+    expires_at timestamptz not null,
+    used_at    timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists one_time_tokens_user_id_purpose_idx on one_time_tokens (user_id, purpose);
+
+COMMIT;
@@ -0,0 +1,7 @@
+begin;
+
+drop table if exists role_permissions;
+drop table if exists permissions;
+drop table if exists roles;
+
+commit;
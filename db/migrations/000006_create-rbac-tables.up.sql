@@ -0,0 +1,48 @@
+BEGIN;
+
+create table if not exists roles
+(
+    name        varchar(32)  not null primary key,
+    description varchar(256) not null default '',
+    created_at  timestamptz  not null default now()
+);
+
+create table if not exists permissions
+(
+    id          bigserial    not null primary key,
+    route       varchar(256) not null unique,
+    description varchar(256) not null default '',
+    created_at  timestamptz  not null default now()
+);
+
+create table if not exists role_permissions
+(
+    role          varchar(32) not null REFERENCES roles (name) on delete cascade,
+    permission_id bigint      not null REFERENCES permissions (id) on delete cascade,
+    created_at    timestamptz not null default now(),
+    primary key (role, permission_id)
+);
+
+insert into roles (name, description)
+values ('admin', 'Full access to user management'),
+       ('moderator', 'Moderates users and their content'),
+       ('merchant', 'Sells on the auction platform'),
+       ('user', 'Regular wallet user')
+on conflict (name) do nothing;
+
+insert into permissions (route, description)
+values ('POST:/users', 'Register a new user'),
+       ('POST:/users/:user_id', 'Create the profile of a user')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'POST:/users'),
+             ('admin', 'POST:/users/:user_id'),
+             ('moderator', 'POST:/users/:user_id'),
+             ('merchant', 'POST:/users/:user_id'),
+             ('user', 'POST:/users/:user_id')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists mfa_recovery_codes;
+drop table if exists user_mfa;
+
+commit;
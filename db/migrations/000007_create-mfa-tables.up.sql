@@ -0,0 +1,23 @@
+BEGIN;
+
+create table if not exists user_mfa
+(
+    user_id        uuid        not null primary key REFERENCES users (user_id) on delete cascade,
+    secret         varchar(64) not null,
+    confirmed_at   timestamptz,
+    last_used_step bigint      not null default 0,
+    created_at     timestamptz not null default now()
+);
+
+create table if not exists mfa_recovery_codes
+(
+    id         bigserial   not null primary key,
+    user_id    uuid        not null REFERENCES users (user_id) on delete cascade,
+    code_hash  varchar(64) not null unique,
+    used_at    timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists mfa_recovery_codes_user_id_idx on mfa_recovery_codes (user_id);
+
+COMMIT;
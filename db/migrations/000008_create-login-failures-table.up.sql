@@ -0,0 +1,11 @@
+BEGIN;
+
+create table if not exists login_failures
+(
+    key            varchar(400) not null primary key,
+    failures       integer      not null default 0,
+    last_failed_at timestamptz  not null default now(),
+    locked_until   timestamptz
+);
+
+COMMIT;
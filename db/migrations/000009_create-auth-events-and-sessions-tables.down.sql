@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists auth_events;
+drop table if exists auth_sessions;
+
+commit;
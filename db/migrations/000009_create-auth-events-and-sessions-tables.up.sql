@@ -0,0 +1,39 @@
+BEGIN;
+
+create table if not exists auth_sessions
+(
+    id           uuid         not null primary key,
+    user_id      uuid         not null REFERENCES users (user_id) on delete cascade,
+    ip           varchar(64)  not null default '',
+    user_agent   varchar(512) not null default '',
+    created_at   timestamptz  not null default now(),
+    last_seen_at timestamptz  not null default now(),
+    revoked_at   timestamptz
+);
+
+create index if not exists auth_sessions_user_id_idx on auth_sessions (user_id);
+
+-- sessions started before this migration are listed without device details
+insert into auth_sessions (id, user_id, created_at, last_seen_at)
+select family_id, user_id, min(created_at), max(created_at)
+from refresh_tokens
+where revoked_at is null
+group by family_id, user_id
+on conflict (id) do nothing;
+
+create table if not exists auth_events
+(
+    id         bigserial    not null primary key,
+    user_id    uuid         REFERENCES users (user_id) on delete set null,
+    identifier varchar(320) not null default '',
+    event      varchar(32)  not null,
+    reason     varchar(64)  not null default '',
+    ip         varchar(64)  not null default '',
+    user_agent varchar(512) not null default '',
+    created_at timestamptz  not null default now()
+);
+
+create index if not exists auth_events_user_id_created_at_idx on auth_events (user_id, created_at);
+create index if not exists auth_events_created_at_idx on auth_events (created_at);
+
+COMMIT;
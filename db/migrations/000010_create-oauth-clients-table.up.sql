@@ -0,0 +1,13 @@
+BEGIN;
+
+create table if not exists oauth_clients
+(
+    client_id   varchar(64)   not null primary key,
+    name        varchar(128)  not null,
+    secret_hash varchar(64)   not null,
+    scopes      varchar(2048) not null default '',
+    disabled_at timestamptz,
+    created_at  timestamptz   not null default now()
+);
+
+COMMIT;
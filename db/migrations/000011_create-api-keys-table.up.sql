@@ -0,0 +1,19 @@
+BEGIN;
+
+create table if not exists api_keys
+(
+    key_id       uuid          not null default uuid_generate_v4() primary key,
+    user_id      uuid          not null REFERENCES users (user_id) on delete cascade,
+    label        varchar(128)  not null,
+    prefix       varchar(16)   not null unique,
+    key_hash     varchar(64)   not null unique,
+    scopes       varchar(2048) not null default '',
+    allowed_ips  varchar(2048) not null default '',
+    last_used_at timestamptz,
+    revoked_at   timestamptz,
+    created_at   timestamptz   not null default now()
+);
+
+create index if not exists api_keys_user_id_idx on api_keys (user_id);
+
+COMMIT;
@@ -0,0 +1,16 @@
+begin;
+
+delete
+from roles
+where name = 'unverified';
+
+update users
+set status = 'active'
+where status = 'unverified';
+
+alter table users
+    drop column if exists email_verified_at;
+
+-- postgres can't drop a value of an enum type, 'unverified' stays in user_status unused
+
+commit;
@@ -0,0 +1,18 @@
+BEGIN;
+
+alter type user_status add value if not exists 'unverified';
+
+alter table users
+    add column if not exists email_verified_at timestamptz;
+
+-- users registered before verification existed keep their access
+update users
+set email_verified_at = created_at
+where email_verified_at is null;
+
+-- unverified users are authorized by the grants of this pseudo-role, whatever their role is
+insert into roles (name, description)
+values ('unverified', 'Users who have not verified their email yet')
+on conflict (name) do nothing;
+
+COMMIT;
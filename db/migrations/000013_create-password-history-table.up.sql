@@ -0,0 +1,13 @@
+BEGIN;
+
+create table if not exists password_history
+(
+    id          bigserial    not null primary key,
+    user_id     uuid         not null REFERENCES users (user_id) on delete cascade,
+    hashed_pass varchar(128) not null,
+    created_at  timestamptz  not null default now()
+);
+
+create index if not exists password_history_user_id_idx on password_history (user_id, created_at desc);
+
+COMMIT;
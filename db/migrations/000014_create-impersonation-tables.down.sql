@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists impersonation_requests;
+drop table if exists impersonations;
+
+commit;
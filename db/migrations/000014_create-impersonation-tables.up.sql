@@ -0,0 +1,29 @@
+BEGIN;
+
+create table if not exists impersonations
+(
+    impersonation_id uuid         not null default uuid_generate_v4() primary key,
+    admin_id         uuid         REFERENCES users (user_id) on delete set null,
+    user_id          uuid         not null REFERENCES users (user_id) on delete cascade,
+    reason           varchar(512) not null,
+    ip               varchar(64)  not null default '',
+    created_at       timestamptz  not null default now(),
+    expires_at       timestamptz  not null
+);
+
+create index if not exists impersonations_user_id_created_at_idx on impersonations (user_id, created_at);
+
+create table if not exists impersonation_requests
+(
+    id               bigserial     not null primary key,
+    impersonation_id uuid          not null REFERENCES impersonations (impersonation_id) on delete cascade,
+    method           varchar(16)   not null,
+    path             varchar(2048) not null,
+    status           int           not null,
+    ip               varchar(64)   not null default '',
+    created_at       timestamptz   not null default now()
+);
+
+create index if not exists impersonation_requests_impersonation_id_idx on impersonation_requests (impersonation_id);
+
+COMMIT;
@@ -0,0 +1,11 @@
+begin;
+
+delete from permissions where route = 'GET:/users';
+
+drop index if exists users_email_prefix_idx;
+drop index if exists users_username_prefix_idx;
+drop index if exists users_created_at_id_idx;
+drop index if exists users_role_id_idx;
+drop index if exists users_status_id_idx;
+
+commit;
@@ -0,0 +1,21 @@
+BEGIN;
+
+-- GET /users pages over users.id, filtered by status, role, created date and username or email prefix
+create index if not exists users_status_id_idx on users (status, id);
+create index if not exists users_role_id_idx on users (role, id);
+create index if not exists users_created_at_id_idx on users (created_at, id);
+create index if not exists users_username_prefix_idx on users (lower(username::text) text_pattern_ops, id);
+create index if not exists users_email_prefix_idx on users (lower(email::text) text_pattern_ops, id);
+
+insert into permissions (route, description)
+values ('GET:/users', 'List users with filters')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/users'),
+             ('moderator', 'GET:/users')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,11 @@
+begin;
+
+delete
+from permissions
+where route in ('GET:/users/:user_id', 'PUT:/users/:user_id', 'PATCH:/users/:user_id', 'DELETE:/users/:user_id');
+
+drop trigger if exists user_profiles_set_updated_at on user_profiles;
+drop trigger if exists users_set_updated_at on users;
+drop function if exists set_updated_at();
+
+commit;
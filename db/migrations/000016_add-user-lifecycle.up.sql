@@ -0,0 +1,60 @@
+BEGIN;
+
+-- keeps updated_at current on every update, whichever service makes it
+create or replace function set_updated_at() returns trigger as
+$$
+begin
+    new.updated_at = now();
+    return new;
+end;
+$$ language plpgsql;
+
+drop trigger if exists users_set_updated_at on users;
+create trigger users_set_updated_at
+    before update
+    on users
+    for each row
+    when (old.* is distinct from new.*)
+execute function set_updated_at();
+
+drop trigger if exists user_profiles_set_updated_at on user_profiles;
+create trigger user_profiles_set_updated_at
+    before update
+    on user_profiles
+    for each row
+    when (old.* is distinct from new.*)
+execute function set_updated_at();
+
+insert into permissions (route, description)
+values ('GET:/users/:user_id', 'Fetch a user'),
+       ('PUT:/users/:user_id', 'Update a user'),
+       ('PATCH:/users/:user_id', 'Update some details of a user'),
+       ('DELETE:/users/:user_id', 'Delete a user')
+on conflict (route) do nothing;
+
+-- users act on themselves only, admins on anyone, unverified users may fix a mistyped email
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/users/:user_id'),
+             ('admin', 'PUT:/users/:user_id'),
+             ('admin', 'PATCH:/users/:user_id'),
+             ('admin', 'DELETE:/users/:user_id'),
+             ('moderator', 'GET:/users/:user_id'),
+             ('moderator', 'PUT:/users/:user_id'),
+             ('moderator', 'PATCH:/users/:user_id'),
+             ('moderator', 'DELETE:/users/:user_id'),
+             ('merchant', 'GET:/users/:user_id'),
+             ('merchant', 'PUT:/users/:user_id'),
+             ('merchant', 'PATCH:/users/:user_id'),
+             ('merchant', 'DELETE:/users/:user_id'),
+             ('user', 'GET:/users/:user_id'),
+             ('user', 'PUT:/users/:user_id'),
+             ('user', 'PATCH:/users/:user_id'),
+             ('user', 'DELETE:/users/:user_id'),
+             ('unverified', 'GET:/users/:user_id'),
+             ('unverified', 'PUT:/users/:user_id'),
+             ('unverified', 'PATCH:/users/:user_id')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,10 @@
+begin;
+
+delete
+from permissions
+where route in ('GET:/users/:user_id/profile', 'PUT:/users/:user_id/profile', 'PATCH:/users/:user_id/profile');
+
+alter table user_profiles
+    drop column if exists version;
+
+commit;
@@ -0,0 +1,30 @@
+BEGIN;
+
+-- incremented on every update, profiles are served with it as their ETag and updated only with a matching If-Match
+alter table user_profiles
+    add column if not exists version bigint not null default 1;
+
+insert into permissions (route, description)
+values ('GET:/users/:user_id/profile', 'Fetch the profile of a user'),
+       ('PUT:/users/:user_id/profile', 'Replace the profile of a user'),
+       ('PATCH:/users/:user_id/profile', 'Update some details of the profile of a user')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/users/:user_id/profile'),
+             ('admin', 'PUT:/users/:user_id/profile'),
+             ('admin', 'PATCH:/users/:user_id/profile'),
+             ('moderator', 'GET:/users/:user_id/profile'),
+             ('moderator', 'PUT:/users/:user_id/profile'),
+             ('moderator', 'PATCH:/users/:user_id/profile'),
+             ('merchant', 'GET:/users/:user_id/profile'),
+             ('merchant', 'PUT:/users/:user_id/profile'),
+             ('merchant', 'PATCH:/users/:user_id/profile'),
+             ('user', 'GET:/users/:user_id/profile'),
+             ('user', 'PUT:/users/:user_id/profile'),
+             ('user', 'PATCH:/users/:user_id/profile')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
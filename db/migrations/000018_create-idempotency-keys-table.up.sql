@@ -0,0 +1,20 @@
+BEGIN;
+
+-- responses of mutating requests by Idempotency-Key, replayed when a client retries the same request
+create table if not exists idempotency_keys
+(
+    scope           varchar(128) not null,
+    idempotency_key varchar(255) not null,
+    fingerprint     varchar(64)  not null,
+    status          int,
+    headers         jsonb,
+    body            bytea,
+    locked_until    timestamptz,
+    expires_at      timestamptz  not null,
+    created_at      timestamptz  not null default now(),
+    primary key (scope, idempotency_key)
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
+
+COMMIT;
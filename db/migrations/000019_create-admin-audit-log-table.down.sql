@@ -0,0 +1,10 @@
+begin;
+
+delete
+from permissions
+where route in ('PUT:/users/:user_id/role', 'GET:/users/inactive', 'PUT:/users/:user_id/activate',
+                'PUT:/users/:user_id/deactivate');
+
+drop table if exists admin_audit_log;
+
+commit;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- every change admins make to users, with the reason given for it
+create table if not exists admin_audit_log
+(
+    id          bigserial    not null primary key,
+    admin_id    uuid         REFERENCES users (user_id) on delete set null,
+    user_id     uuid         not null REFERENCES users (user_id) on delete cascade,
+    action      varchar(32)  not null,
+    old_value   varchar(32)  not null,
+    new_value   varchar(32)  not null,
+    reason_code varchar(32)  not null,
+    note        varchar(512) not null default '',
+    ip          varchar(45)  not null default '',
+    created_at  timestamptz  not null default now()
+);
+
+create index if not exists admin_audit_log_user_id_idx on admin_audit_log (user_id, created_at desc);
+
+insert into permissions (route, description)
+values ('PUT:/users/:user_id/role', 'Change the role of a user'),
+       ('GET:/users/inactive', 'List inactive users'),
+       ('PUT:/users/:user_id/activate', 'Activate a user'),
+       ('PUT:/users/:user_id/deactivate', 'Deactivate a user')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'PUT:/users/:user_id/role'),
+             ('admin', 'GET:/users/inactive'),
+             ('admin', 'PUT:/users/:user_id/activate'),
+             ('admin', 'PUT:/users/:user_id/deactivate')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
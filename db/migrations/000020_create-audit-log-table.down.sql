@@ -0,0 +1,10 @@
+begin;
+
+delete
+from permissions
+where route = 'GET:/audit';
+
+drop table if exists audit_log;
+drop function if exists audit_log_append_only();
+
+commit;
@@ -0,0 +1,54 @@
+BEGIN;
+
+-- security-relevant actions of every service, append-only and hash chained: each entry's hash covers
+-- the hash of the entry before it, so changing or removing an entry breaks the chain from there on.
+-- actor and target aren't foreign keys, entries outlive the users they are about.
+create table if not exists audit_log
+(
+    id         bigserial    not null primary key,
+    actor      varchar(128) not null default '',
+    target     varchar(128) not null default '',
+    action     varchar(64)  not null,
+    diff       jsonb        not null default '{}',
+    ip         varchar(45)  not null default '',
+    created_at timestamptz  not null,
+    prev_hash  char(64)     not null,
+    hash       char(64)     not null unique
+);
+
+create index if not exists audit_log_actor_idx on audit_log (actor, id desc);
+create index if not exists audit_log_target_idx on audit_log (target, id desc);
+create index if not exists audit_log_created_at_idx on audit_log (created_at);
+
+create or replace function audit_log_append_only() returns trigger as
+$$
+begin
+    raise exception 'audit_log is append-only, % is not allowed', tg_op;
+end;
+$$ language plpgsql;
+
+drop trigger if exists audit_log_no_update_delete on audit_log;
+create trigger audit_log_no_update_delete
+    before update or delete
+    on audit_log
+    for each row
+execute function audit_log_append_only();
+
+drop trigger if exists audit_log_no_truncate on audit_log;
+create trigger audit_log_no_truncate
+    before truncate
+    on audit_log
+    for each statement
+execute function audit_log_append_only();
+
+insert into permissions (route, description)
+values ('GET:/audit', 'Search the audit log')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/audit')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
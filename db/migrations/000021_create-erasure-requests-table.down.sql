@@ -0,0 +1,15 @@
+begin;
+
+delete
+from permissions
+where route in ('GET:/users/:user_id/export', 'POST:/users/:user_id/erasure', 'GET:/users/:user_id/erasure',
+                'DELETE:/users/:user_id/erasure', 'GET:/erasure-requests', 'PUT:/erasure-requests/:request_id/approve',
+                'PUT:/erasure-requests/:request_id/reject');
+
+alter table users
+    drop column if exists erased_at;
+
+drop table if exists erasure_requests;
+drop type if exists erasure_status;
+
+commit;
@@ -0,0 +1,71 @@
+BEGIN;
+
+create type erasure_status as enum ('pending', 'approved', 'rejected', 'cancelled', 'completed');
+
+-- requests to erase the personal data of a user, approved by an admin and carried out after a cooling-off period,
+-- during which the user can still cancel. Requests are kept after the user is erased, as the record of it.
+create table if not exists erasure_requests
+(
+    request_id    uuid           not null default uuid_generate_v4() primary key,
+    user_id       uuid           not null REFERENCES users (user_id),
+    requested_by  uuid           REFERENCES users (user_id) on delete set null,
+    reason        varchar(512)   not null default '',
+    status        erasure_status not null default 'pending',
+    decided_by    uuid           REFERENCES users (user_id) on delete set null,
+    decided_at    timestamptz,
+    decision_note varchar(512)   not null default '',
+    erase_after   timestamptz,
+    completed_at  timestamptz,
+    created_at    timestamptz    not null default now()
+);
+
+-- a user has at most one open request
+create unique index if not exists erasure_requests_open_user_id_idx on erasure_requests (user_id)
+    where status in ('pending', 'approved');
+create index if not exists erasure_requests_due_idx on erasure_requests (erase_after)
+    where status = 'approved';
+
+-- erased users keep their row, with pseudonyms in place of their personal data
+alter table users
+    add column if not exists erased_at timestamptz;
+
+insert into permissions (route, description)
+values ('GET:/users/:user_id/export', 'Export the personal data of a user'),
+       ('POST:/users/:user_id/erasure', 'Request the erasure of the personal data of a user'),
+       ('GET:/users/:user_id/erasure', 'Fetch the latest erasure request of a user'),
+       ('DELETE:/users/:user_id/erasure', 'Cancel the open erasure request of a user'),
+       ('GET:/erasure-requests', 'List erasure requests'),
+       ('PUT:/erasure-requests/:request_id/approve', 'Approve an erasure request'),
+       ('PUT:/erasure-requests/:request_id/reject', 'Reject an erasure request')
+on conflict (route) do nothing;
+
+-- users export and erase themselves only, admins anyone and decide on the requests
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/users/:user_id/export'),
+             ('admin', 'POST:/users/:user_id/erasure'),
+             ('admin', 'GET:/users/:user_id/erasure'),
+             ('admin', 'DELETE:/users/:user_id/erasure'),
+             ('admin', 'GET:/erasure-requests'),
+             ('admin', 'PUT:/erasure-requests/:request_id/approve'),
+             ('admin', 'PUT:/erasure-requests/:request_id/reject'),
+             ('moderator', 'GET:/users/:user_id/export'),
+             ('moderator', 'POST:/users/:user_id/erasure'),
+             ('moderator', 'GET:/users/:user_id/erasure'),
+             ('moderator', 'DELETE:/users/:user_id/erasure'),
+             ('merchant', 'GET:/users/:user_id/export'),
+             ('merchant', 'POST:/users/:user_id/erasure'),
+             ('merchant', 'GET:/users/:user_id/erasure'),
+             ('merchant', 'DELETE:/users/:user_id/erasure'),
+             ('user', 'GET:/users/:user_id/export'),
+             ('user', 'POST:/users/:user_id/erasure'),
+             ('user', 'GET:/users/:user_id/erasure'),
+             ('user', 'DELETE:/users/:user_id/erasure'),
+             ('unverified', 'GET:/users/:user_id/export'),
+             ('unverified', 'POST:/users/:user_id/erasure'),
+             ('unverified', 'GET:/users/:user_id/erasure'),
+             ('unverified', 'DELETE:/users/:user_id/erasure')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,5 @@
+begin;
+
+delete from permissions where route = 'POST:/users/import';
+
+commit;
@@ -0,0 +1,13 @@
+BEGIN;
+
+insert into permissions (route, description)
+values ('POST:/users/import', 'Import users in bulk from CSV or JSON Lines')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'POST:/users/import')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
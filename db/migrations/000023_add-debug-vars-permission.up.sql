@@ -0,0 +1,13 @@
+BEGIN;
+
+insert into permissions (route, description)
+values ('GET:/debug/vars', 'Read expvar metrics of the user-api')
+on conflict (route) do nothing;
+
+insert into role_permissions (role, permission_id)
+select grants.role, p.id
+from (values ('admin', 'GET:/debug/vars')) as grants (role, route)
+         join permissions p on p.route = grants.route
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,13 @@
+begin;
+
+create or replace function audit_log_append_only() returns trigger as
+$$
+begin
+    raise exception 'audit_log is append-only, % is not allowed', tg_op;
+end;
+$$ language plpgsql;
+
+alter table audit_log
+    drop column if exists personal;
+
+commit;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- personal data changed by an action, names, emails and addresses, kept apart from the diff and not covered
+-- by the hash, so it can be erased with its subject's data without breaking the chain.
+alter table audit_log
+    add column if not exists personal jsonb;
+
+-- the only change allowed to an entry is clearing its personal data
+create or replace function audit_log_append_only() returns trigger as
+$$
+begin
+    if tg_op = 'UPDATE' and new.personal is null
+        and (new.id, new.actor, new.target, new.action, new.diff, new.ip, new.created_at, new.prev_hash, new.hash)
+            is not distinct from
+            (old.id, old.actor, old.target, old.action, old.diff, old.ip, old.created_at, old.prev_hash, old.hash) then
+        return new;
+    end if;
+
+    raise exception 'audit_log is append-only, % is not allowed', tg_op;
+end;
+$$ language plpgsql;
+
+COMMIT;
@@ -0,0 +1,6 @@
+begin;
+
+alter table erasure_requests
+    drop column if exists impersonated_by;
+
+commit;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- the admin who filed a request while impersonating its requester, they can't decide on it
+alter table erasure_requests
+    add column if not exists impersonated_by uuid REFERENCES users (user_id) on delete set null;
+
+COMMIT;
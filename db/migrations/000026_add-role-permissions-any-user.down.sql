@@ -0,0 +1,6 @@
+begin;
+
+alter table role_permissions
+    drop column if exists any_user;
+
+commit;
@@ -0,0 +1,25 @@
+BEGIN;
+
+-- routes with a user id path parameter are granted for the user itself, unless granted for any user
+alter table role_permissions
+    add column if not exists any_user boolean not null default false;
+
+update role_permissions rp
+set any_user = true
+from permissions p
+where p.id = rp.permission_id
+  and rp.role = 'admin'
+  and p.route in ('POST:/users/:user_id',
+                  'GET:/users/:user_id',
+                  'PUT:/users/:user_id',
+                  'PATCH:/users/:user_id',
+                  'DELETE:/users/:user_id',
+                  'GET:/users/:user_id/profile',
+                  'PUT:/users/:user_id/profile',
+                  'PATCH:/users/:user_id/profile',
+                  'GET:/users/:user_id/export',
+                  'POST:/users/:user_id/erasure',
+                  'GET:/users/:user_id/erasure',
+                  'DELETE:/users/:user_id/erasure');
+
+COMMIT;
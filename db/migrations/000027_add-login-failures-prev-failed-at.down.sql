@@ -0,0 +1,6 @@
+begin;
+
+alter table login_failures
+    drop column if exists prev_failed_at;
+
+commit;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- attempts are counted before the credentials are checked, the time of the failure before the latest one
+-- is kept so the backoff of an attempt is decided by the failures before it
+alter table login_failures
+    add column if not exists prev_failed_at timestamptz;
+
+COMMIT;
@@ -0,0 +1,8 @@
+begin;
+
+alter table erasure_requests
+    drop column if exists erase_failures,
+    drop column if exists last_error,
+    drop column if exists failed_at;
+
+commit;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- erasures that fail are retried after a while, the other due requests are carried out meanwhile
+alter table erasure_requests
+    add column if not exists erase_failures integer not null default 0,
+    add column if not exists last_error     text    not null default '',
+    add column if not exists failed_at      timestamptz;
+
+COMMIT;
@@ -10,11 +10,13 @@ import (
 // Error() returns customized string with hiding internal error.
 // WithCauses includes internal actual error as causes.
 // Wrap() is for manually wrapping actual error to api error, which not included in Error() method.
+// Details() returns structured details safe to show to clients, e.g. every rule a field breaks, or nil.
 type APIError interface {
 	Error() string
 	WithCauses() string
 	Wrap(err error) APIError
 	Code() int
+	Details() any
 }
 
 // apiError is a concrete implementation of the APIError interface.
@@ -22,6 +24,7 @@ type apiError struct {
 	Message    string `json:"message"`
 	StatusCode int    `json:"status"`
 	Causes     string `json:"causes"`
+	Detail     any    `json:"details,omitempty"`
 }
 
 // Code returns http status code
@@ -29,6 +32,21 @@ func (e *apiError) Code() int {
 	return e.StatusCode
 }
 
+// Details returns structured details of the error for clients, nil if there are none.
+func (e *apiError) Details() any {
+	return e.Detail
+}
+
+// ErrorBody returns the json response body of an error, its message and its details if there are any.
+func ErrorBody(err APIError) map[string]any {
+	body := map[string]any{"error": err.Error()}
+	if details := err.Details(); details != nil {
+		body["details"] = details
+	}
+
+	return body
+}
+
 // Error returns error message and code. But hides internal server/db related errors
 func (e *apiError) Error() string {
 	return e.Message
@@ -76,6 +94,19 @@ func BadRequestError(message string) APIError {
 	}
 }
 
+// ValidationError creates a new APIError for bad requests with structured details, e.g. each rule
+// a password breaks, returns http.StatusBadRequest 400.
+// Example usage:
+//
+//	err := ValidationError("password doesn't meet the policy", violations)
+func ValidationError(message string, details any) APIError {
+	return &apiError{
+		Message:    message,
+		StatusCode: http.StatusBadRequest,
+		Detail:     details,
+	}
+}
+
 // NotFoundError creates a new APIError for not found errors.
 // returns http.StatusNotFound 404.
 // Example usage:
@@ -100,6 +131,42 @@ func UnauthorizedError(message string) APIError {
 	}
 }
 
+// ForbiddenError creates a new APIError for authenticated requests not allowed to do what they ask.
+// returns http.StatusForbidden 403.
+// Example usage:
+//
+//	err := ForbiddenError("only admins can change the status")
+func ForbiddenError(message string) APIError {
+	return &apiError{
+		Message:    message,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+// PreconditionFailedError creates a new APIError for conditional requests whose condition doesn't hold,
+// such as an If-Match header with an outdated ETag, returns http.StatusPreconditionFailed 412.
+// Example usage:
+//
+//	err := PreconditionFailedError("profile was changed since it was read")
+func PreconditionFailedError(message string) APIError {
+	return &apiError{
+		Message:    message,
+		StatusCode: http.StatusPreconditionFailed,
+	}
+}
+
+// PreconditionRequiredError creates a new APIError for requests that must be conditional but aren't,
+// returns http.StatusPreconditionRequired 428.
+// Example usage:
+//
+//	err := PreconditionRequiredError("if-match header is required")
+func PreconditionRequiredError(message string) APIError {
+	return &apiError{
+		Message:    message,
+		StatusCode: http.StatusPreconditionRequired,
+	}
+}
+
 // RateLimitError creates a new APIError for rate limit error.
 // returns http.StatusTooManyRequests 429.
 // Example usage:
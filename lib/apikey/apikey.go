@@ -0,0 +1,126 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+)
+
+const (
+	// Scheme is the authorization scheme API keys are presented with, "Authorization: ApiKey <key>".
+	Scheme = "ApiKey"
+
+	// TokenTypeHint tells the introspection endpoint the token is an API key.
+	TokenTypeHint = "api_key"
+
+	keyTag     = "iwk"
+	prefixSize = 8
+	secretSize = 32
+
+	// legacyPrefixSize is the prefix size of keys generated before prefixes grew, they remain valid.
+	legacyPrefixSize = 4
+)
+
+var ErrInvalidAllowlist = errors.New("allowlist entries must be IP addresses or CIDR prefixes")
+
+// Generate returns a new API key "iwk_<prefix>_<secret>" and its prefix.
+// The prefix identifies the key in listings and logs, only the hash of the whole key should be persisted.
+func Generate() (string, string, error) {
+	b := make([]byte, prefixSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("unable to read random bytes: %w", err)
+	}
+
+	prefix := hex.EncodeToString(b)
+
+	secret, err := securetoken.Generate(secretSize)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to generate secret: %w", err)
+	}
+
+	return keyTag + "_" + prefix + "_" + secret, prefix, nil
+}
+
+// Prefix returns the prefix of a well-formed API key, false if key isn't one.
+func Prefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, keyTag+"_")
+	if !ok {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || secret == "" || (len(prefix) != 2*prefixSize && len(prefix) != 2*legacyPrefixSize) {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(prefix); err != nil {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+// IsKey reports whether s is formatted like an API key, so it's never mistaken for a JWT.
+func IsKey(s string) bool {
+	_, ok := Prefix(s)
+	return ok
+}
+
+// ValidateAllowlist checks every entry is an IP address like "203.0.113.7" or a CIDR prefix like "10.0.0.0/8".
+func ValidateAllowlist(allowlist []string) error {
+	for _, entry := range allowlist {
+		if _, err := parseEntry(entry); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAllowlist, entry)
+		}
+	}
+
+	return nil
+}
+
+// AllowsIP reports whether ip is in the allowlist, an empty allowlist allows every address.
+func AllowsIP(allowlist []string, ip string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, entry := range allowlist {
+		if p, err := parseEntry(entry); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseEntry parses an allowlist entry, single addresses are prefixes of their full bit length.
+func parseEntry(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		p, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix: %w", err)
+		}
+
+		return p.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address: %w", err)
+	}
+
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
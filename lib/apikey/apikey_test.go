@@ -0,0 +1,100 @@
+package apikey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() unexpected error = %v", err)
+	}
+
+	got, ok := Prefix(key)
+	if !ok || got != prefix {
+		t.Errorf("Prefix(%q) = %q, %v, want %q, true", key, got, ok, prefix)
+	}
+
+	other, _, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() unexpected error = %v", err)
+	}
+
+	if key == other {
+		t.Errorf("Generate() returned the same key twice: %s", key)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantPrefix string
+		wantOk     bool
+	}{
+		{name: "Valid", key: "iwk_0a1b2c3d4e5f6a7b_c2VjcmV0_-", wantPrefix: "0a1b2c3d4e5f6a7b", wantOk: true},
+		{name: "Legacy_Prefix", key: "iwk_0a1b2c3d_c2VjcmV0_-", wantPrefix: "0a1b2c3d", wantOk: true},
+		{name: "Missing_Tag", key: "0a1b2c3d_c2VjcmV0", wantOk: false},
+		{name: "Prefix_Not_Hex", key: "iwk_zzzzzzzz_c2VjcmV0", wantOk: false},
+		{name: "Short_Prefix", key: "iwk_0a1b_c2VjcmV0", wantOk: false},
+		{name: "Odd_Prefix", key: "iwk_0a1b2c3d4e_c2VjcmV0", wantOk: false},
+		{name: "Missing_Secret", key: "iwk_0a1b2c3d_", wantOk: false},
+		{name: "JWT", key: "eyJhbGciOiJFZERTQSJ9.eyJzdWIiOiIxIn0.c2ln", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, ok := Prefix(tt.key)
+			if prefix != tt.wantPrefix || ok != tt.wantOk {
+				t.Errorf("Prefix() = %q, %v, want %q, %v", prefix, ok, tt.wantPrefix, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAllowsIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		ip        string
+		want      bool
+	}{
+		{name: "Empty_Allowlist", allowlist: nil, ip: "198.51.100.1", want: true},
+		{name: "Exact_Address", allowlist: []string{"203.0.113.7"}, ip: "203.0.113.7", want: true},
+		{name: "Other_Address", allowlist: []string{"203.0.113.7"}, ip: "203.0.113.8", want: false},
+		{name: "In_Prefix", allowlist: []string{"10.0.0.0/8"}, ip: "10.20.30.40", want: true},
+		{name: "Not_In_Prefix", allowlist: []string{"10.0.0.0/8"}, ip: "11.0.0.1", want: false},
+		{name: "IPv4_Mapped", allowlist: []string{"10.0.0.0/8"}, ip: "::ffff:10.0.0.1", want: true},
+		{name: "IPv6_Prefix", allowlist: []string{"2001:db8::/32"}, ip: "2001:db8::1", want: true},
+		{name: "Invalid_IP", allowlist: []string{"10.0.0.0/8"}, ip: "not-an-ip", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AllowsIP(tt.allowlist, tt.ip); got != tt.want {
+				t.Errorf("AllowsIP(%v, %q) = %v, want %v", tt.allowlist, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAllowlist(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		wantErr   error
+	}{
+		{name: "Valid", allowlist: []string{"203.0.113.7", "10.0.0.0/8", "2001:db8::/32"}, wantErr: nil},
+		{name: "Hostname", allowlist: []string{"example.com"}, wantErr: ErrInvalidAllowlist},
+		{name: "Invalid_Prefix_Length", allowlist: []string{"10.0.0.0/33"}, wantErr: ErrInvalidAllowlist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAllowlist(tt.allowlist); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,21 @@ func InitServerConfig(portEnv string) *http.Server {
 	}
 }
 
+// TrustedProxies returns the addresses and CIDR prefixes of the reverse proxies in front of the services,
+// from the comma separated TRUSTED_PROXIES environment variable. Client IPs are only taken from
+// X-Forwarded-For when a request comes from one of them, without any the connection's remote address is used.
+func TrustedProxies() []string {
+	var proxies []string
+
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+
+	return proxies
+}
+
 func InitSlogger() *slog.Logger {
 	handlerOpts := GetSlogConf()
 	l := slog.New(slog.NewTextHandler(os.Stdout, handlerOpts))
@@ -63,17 +79,18 @@ func InitDB(l *slog.Logger) *sql.DB {
 // If error happened during setting env variable, then logs error and exits application.
 func SanityCheck(l *slog.Logger) {
 	defaultEnvVars := map[string]string{
-		"API_SCHEME":    "http",
-		"API_HOST":      "127.0.0.1",
-		"USER_API_PORT": "8000",
-		"AUTH_API_PORT": "8001",
-		"DB_USER":       "postgres",
-		"DB_PASSWD":     "postgres",
-		"DB_HOST":       "127.0.0.1",
-		"DB_PORT":       "5432",
-		"DB_NAME":       "instabid",
-		"GIN_MODE":      "debug",
-		"HMACSecret":    "hmacSampleSecret",
+		"API_SCHEME":     "http",
+		"API_HOST":       "127.0.0.1",
+		"USER_API_PORT":  "8000",
+		"AUTH_API_PORT":  "8001",
+		"ADMIN_API_PORT": "8004",
+		"DB_USER":        "postgres",
+		"DB_PASSWD":      "postgres",
+		"DB_HOST":        "127.0.0.1",
+		"DB_PORT":        "5432",
+		"DB_NAME":        "instabid",
+		"GIN_MODE":       "debug",
+		"APP_URL":        "http://127.0.0.1:3000",
 	}
 
 	for key, defaultValue := range defaultEnvVars {
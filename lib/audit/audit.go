@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Actions recorded by the services, named <resource>.<verb>.
+const (
+	ActionLogin         = "auth.login"
+	ActionRoleChange    = "user.change_role"
+	ActionStatusChange  = "user.change_status"
+	ActionUserUpdate    = "user.update"
+	ActionUserDelete    = "user.delete"
+	ActionUserImport    = "user.import"
+	ActionProfileCreate = "profile.create"
+	ActionProfileUpdate = "profile.update"
+	ActionDataExport    = "user.export"
+	ActionErasureCreate = "erasure.request"
+	ActionErasureCancel = "erasure.cancel"
+	ActionErasureDecide = "erasure.decide"
+	ActionUserErase     = "user.erase"
+)
+
+// GenesisHash is the previous hash of the first entry of the chain.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+var (
+	ErrBrokenLink   = errors.New("previous hash doesn't match the hash of the entry before it")
+	ErrHashMismatch = errors.New("hash doesn't match the content of the entry")
+)
+
+// Change is the value of a field before and after an action, nil if the field didn't exist before or after.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff is the change of every field an action changed, by field name. Secrets like passwords never belong in it.
+type Diff map[string]Change
+
+// Add records a change of field, unless before and after are equal.
+func (d Diff) Add(field string, before, after any) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	d[field] = Change{Before: before, After: after}
+}
+
+// PersonalFieldsKey is the field of a diff listing the personal fields an action changed,
+// their values are kept apart in Entry.Personal.
+const PersonalFieldsKey = "personalFields"
+
+// SplitPersonal moves the changes of the personal fields out of the diff into the returned personal diff,
+// the diff keeps their names, sorted, under PersonalFieldsKey.
+func (d Diff) SplitPersonal(fields ...string) Diff {
+	personal := Diff{}
+	names := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		if c, ok := d[field]; ok {
+			personal[field] = c
+			names = append(names, field)
+
+			delete(d, field)
+		}
+	}
+
+	if len(names) > 0 {
+		slices.Sort(names)
+		d[PersonalFieldsKey] = Change{After: names}
+	}
+
+	return personal
+}
+
+// Entry is a security-relevant action of an actor on a target, chained to the entry before it by PrevHash.
+// Actor is the user id of whoever acted, or "client:<id>" for service clients, Target the id of what was acted on.
+// Personal holds the changes of personal data, like names and addresses, which the hash doesn't cover,
+// so they can be erased with their subject's data while the chain stays intact, see Diff.SplitPersonal.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Target    string    `json:"target"`
+	Action    string    `json:"action"`
+	Diff      Diff      `json:"diff"`
+	Personal  Diff      `json:"personal,omitempty"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+	PrevHash  string    `json:"prevHash"`
+	Hash      string    `json:"hash"`
+}
+
+// hashInput is what the hash of an entry covers, its fields are marshaled in this order.
+type hashInput struct {
+	PrevHash  string          `json:"prevHash"`
+	Actor     string          `json:"actor"`
+	Target    string          `json:"target"`
+	Action    string          `json:"action"`
+	Diff      json.RawMessage `json:"diff"`
+	IP        string          `json:"ip"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// ComputeHash returns the hex sha256 of the entry's content and its PrevHash, the id isn't covered,
+// it's assigned once the entry is stored.
+func ComputeHash(e Entry) (string, error) {
+	diff, err := canonicalDiff(e.Diff)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(hashInput{
+		PrevHash:  e.PrevHash,
+		Actor:     e.Actor,
+		Target:    e.Target,
+		Action:    e.Action,
+		Diff:      diff,
+		IP:        e.IP,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalDiff marshals a diff the same way before it's stored and after it's read back,
+// object keys sorted and values as they decode from JSON, whatever types they were recorded with.
+func canonicalDiff(d Diff) ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("{}"), nil
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err = json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// Chain verifies entries link up, given one at a time in id order from the first entry.
+type Chain struct {
+	head    string
+	checked int
+}
+
+func NewChain() *Chain {
+	return &Chain{head: GenesisHash}
+}
+
+// Next checks the entry follows the one before it and its hash matches its content.
+// Verification goes on from the entry's hash either way, so every tampered entry is reported, not only the first.
+func (c *Chain) Next(e Entry) error {
+	c.checked++
+
+	var errs error
+
+	if e.PrevHash != c.head {
+		errs = errors.Join(errs, ErrBrokenLink)
+	}
+
+	hash, err := ComputeHash(e)
+	if err != nil {
+		errs = errors.Join(errs, err)
+	} else if hash != e.Hash {
+		errs = errors.Join(errs, ErrHashMismatch)
+	}
+
+	c.head = e.Hash
+
+	return errs
+}
+
+// Head returns the hash of the last entry checked, worth keeping outside the database:
+// removing entries from the end of the chain is only noticed by comparing it with an earlier head.
+func (c *Chain) Head() string {
+	return c.head
+}
+
+// Checked returns the number of entries checked.
+func (c *Chain) Checked() int {
+	return c.checked
+}
+
+// Broken is an entry that failed verification.
+type Broken struct {
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Report is the result of verifying the chain.
+type Report struct {
+	Checked int      `json:"checked"`
+	Head    string   `json:"head"`
+	Broken  []Broken `json:"broken"`
+}
+
+// OK reports whether every entry verified.
+func (r *Report) OK() bool {
+	return len(r.Broken) == 0
+}
+
+// Actor is who makes the requests handled with a context, entries recorded with it are attributed to them.
+type Actor struct {
+	ID string
+	IP string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFromContext returns the actor set by WithActor, empty if there's none.
+func ActorFromContext(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey{}).(Actor)
+	return a
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newChain returns n entries chained from the genesis hash, as AppendTx would store them.
+func newChain(t *testing.T, n int) []Entry {
+	t.Helper()
+
+	entries := make([]Entry, 0, n)
+	prev := GenesisHash
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	for i := 1; i <= n; i++ {
+		e := Entry{
+			ID:        int64(i),
+			Actor:     "admin-1",
+			Target:    "user-1",
+			Action:    ActionRoleChange,
+			Diff:      Diff{"role": {Before: "user", After: "moderator"}, "attempt": {Before: nil, After: i}},
+			IP:        "127.0.0.1",
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Second),
+			PrevHash:  prev,
+		}
+
+		hash, err := ComputeHash(e)
+		if err != nil {
+			t.Fatalf("ComputeHash() error = %v", err)
+		}
+
+		e.Hash = hash
+		prev = hash
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+func TestComputeHash(t *testing.T) {
+	e := newChain(t, 1)[0]
+
+	// entries read back from the database have their diff decoded from JSON and times in another location
+	var readBack Entry
+
+	b, _ := json.Marshal(e)
+	if err := json.Unmarshal(b, &readBack); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	readBack.CreatedAt = readBack.CreatedAt.In(time.FixedZone("UTC+6", 6*60*60))
+
+	if got, _ := ComputeHash(readBack); got != e.Hash {
+		t.Errorf("ComputeHash() of the entry read back = %s, want %s", got, e.Hash)
+	}
+
+	changed := e
+	changed.Diff = Diff{"role": {Before: "user", After: "admin"}}
+
+	if got, _ := ComputeHash(changed); got == e.Hash {
+		t.Error("ComputeHash() of a changed entry matches the original hash")
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(entries []Entry) []Entry
+		wantBroken map[int64]error
+	}{
+		{name: "Intact", tamper: func(entries []Entry) []Entry { return entries }},
+		{name: "Changed_Entry", tamper: func(entries []Entry) []Entry {
+			entries[1].Actor = "admin-2"
+			return entries
+		}, wantBroken: map[int64]error{2: ErrHashMismatch}},
+		{name: "Changed_Entry_Rehashed", tamper: func(entries []Entry) []Entry {
+			entries[1].Actor = "admin-2"
+			entries[1].Hash, _ = ComputeHash(entries[1])
+
+			return entries
+		}, wantBroken: map[int64]error{3: ErrBrokenLink}},
+		{name: "Removed_Entry", tamper: func(entries []Entry) []Entry {
+			return append(entries[:1], entries[2:]...)
+		}, wantBroken: map[int64]error{3: ErrBrokenLink}},
+		{name: "Swapped_Entries", tamper: func(entries []Entry) []Entry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}, wantBroken: map[int64]error{3: ErrBrokenLink, 2: ErrBrokenLink, 4: ErrBrokenLink}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChain()
+			entries := tt.tamper(newChain(t, 4))
+			broken := map[int64]error{}
+
+			for _, e := range entries {
+				if err := chain.Next(e); err != nil {
+					broken[e.ID] = err
+				}
+			}
+
+			if len(broken) != len(tt.wantBroken) {
+				t.Fatalf("Next() failed for entries %v, want %v", broken, tt.wantBroken)
+			}
+
+			for id, wantErr := range tt.wantBroken {
+				if !errors.Is(broken[id], wantErr) {
+					t.Errorf("Next() of entry %d error = %v, want %v", id, broken[id], wantErr)
+				}
+			}
+
+			if chain.Checked() != len(entries) || chain.Head() != entries[len(entries)-1].Hash {
+				t.Errorf("Checked(), Head() = %d, %s, want %d, %s", chain.Checked(), chain.Head(), len(entries),
+					entries[len(entries)-1].Hash)
+			}
+		})
+	}
+}
+
+func TestDiffAdd(t *testing.T) {
+	d := Diff{}
+	d.Add("username", "alice", "alice")
+	d.Add("email", "a@example.com", "b@example.com")
+	d.Add("address", nil, "Dhaka")
+
+	if len(d) != 2 {
+		t.Fatalf("Add() recorded %v, want the email and address changes", d)
+	}
+
+	if d["email"].Before != "a@example.com" || d["address"].After != "Dhaka" {
+		t.Errorf("Add() recorded %v", d)
+	}
+}
+
+func TestDiffSplitPersonal(t *testing.T) {
+	d := Diff{"email": {Before: "a@test.com", After: "b@test.com"}, "userName": {Before: "alice01", After: "alice02"},
+		"status": {Before: "active", After: "inactive"}}
+
+	personal := d.SplitPersonal("userName", "email", "address")
+
+	if len(personal) != 2 || personal["email"].After != "b@test.com" || personal["userName"].After != "alice02" {
+		t.Errorf("SplitPersonal() personal = %+v", personal)
+	}
+
+	if _, ok := d["email"]; ok || len(d) != 2 || d["status"].After != "inactive" {
+		t.Errorf("SplitPersonal() left diff = %+v", d)
+	}
+
+	names, _ := d[PersonalFieldsKey].After.([]string)
+	if len(names) != 2 || names[0] != "email" || names[1] != "userName" {
+		t.Errorf("diff[%s] = %v, want [email userName]", PersonalFieldsKey, d[PersonalFieldsKey].After)
+	}
+
+	if personal = (Diff{"status": {After: "active"}}).SplitPersonal("email"); len(personal) != 0 {
+		t.Errorf("SplitPersonal() of a diff without personal fields = %+v", personal)
+	}
+}
+
+func TestComputeHashExcludesPersonal(t *testing.T) {
+	e := newChain(t, 1)[0]
+	e.Personal = Diff{"email": {After: "a@test.com"}}
+
+	hash, err := ComputeHash(e)
+	if err != nil {
+		t.Fatalf("ComputeHash() error = %v", err)
+	}
+
+	if hash != e.Hash {
+		t.Errorf("ComputeHash() changed with the personal diff, erasing it would break the chain")
+	}
+}
@@ -0,0 +1,293 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+// recordTimeout bounds appending an entry by Record, independent of the request it's recorded for.
+const recordTimeout = 5 * time.Second
+
+// Log records entries of the audit log.
+type Log interface {
+	Record(ctx context.Context, e Entry)
+}
+
+// Finder searches the audit log.
+type Finder interface {
+	Find(ctx context.Context, f Filter) ([]Entry, lib.APIError)
+}
+
+// SubjectFinder finds the entries about a user, for exports of the user's personal data.
+type SubjectFinder interface {
+	FindBySubject(ctx context.Context, id string) ([]Entry, lib.APIError)
+}
+
+// Filter selects entries by actor, target and action, created in [From, To) and before the entry BeforeID,
+// zero values match every entry.
+type Filter struct {
+	Actor    string
+	Target   string
+	Action   string
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// StoreDB keeps the audit log in the audit_log table, shared by every service. The table is append-only,
+// updates and deletes are rejected by the database, except for clearing the personal data of entries.
+type StoreDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewStoreDB(db *sql.DB, l *slog.Logger) *StoreDB {
+	return &StoreDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Record appends an entry attributed to the actor of ctx, unless the entry has its own.
+// It's called once the change is made, so the entry is appended even if ctx is cancelled or times out meanwhile,
+// waiting up to recordTimeout for the append lock. Failures are logged along with the entry,
+// so it's never lost from the logs.
+func (s *StoreDB) Record(ctx context.Context, e Entry) {
+	if e.Actor == "" && e.IP == "" {
+		actor := ActorFromContext(ctx)
+		e.Actor, e.IP = actor.ID, actor.IP
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	if _, apiErr := s.Append(ctx, e); apiErr != nil {
+		s.l.ErrorContext(ctx, "audit log entry not recorded", "err", apiErr.WithCauses(), "action", e.Action,
+			"actor", e.Actor, "target", e.Target)
+		return
+	}
+
+	s.l.InfoContext(ctx, "audit log entry recorded", "action", e.Action, "actor", e.Actor, "target", e.Target)
+}
+
+// Append appends an entry in its own transaction and returns it with its id and hashes.
+func (s *StoreDB) Append(ctx context.Context, e Entry) (*Entry, lib.APIError) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		s.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, s.l)
+
+	var entry *Entry
+	if entry, err = AppendTx(ctx, tx, e); err != nil {
+		s.l.ErrorContext(ctx, "unable to append audit log entry", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return entry, nil
+}
+
+// AppendTx appends an entry in tx, for actions recorded in the same transaction as the change they make.
+// Appends are serialized by a transaction-level advisory lock, held until tx ends, so no two entries
+// chain to the same previous entry.
+func AppendTx(ctx context.Context, tx *sql.Tx, e Entry) (*Entry, error) {
+	sqlLock := `SELECT pg_advisory_xact_lock(hashtext('audit_log'))`
+	sqlLastHash := `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`
+	sqlInsert := `INSERT INTO audit_log (actor, target, action, diff, personal, ip, created_at, prev_hash, hash)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+	if _, err := tx.ExecContext(ctx, sqlLock); err != nil {
+		return nil, err
+	}
+
+	e.PrevHash = GenesisHash
+	if err := tx.QueryRowContext(ctx, sqlLastHash).Scan(&e.PrevHash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// postgres keeps microseconds, the hash must cover the time as it's read back
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	diff, err := canonicalDiff(e.Diff)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Hash, err = ComputeHash(e); err != nil {
+		return nil, err
+	}
+
+	var personal []byte
+	if len(e.Personal) > 0 {
+		if personal, err = json.Marshal(e.Personal); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.QueryRowContext(ctx, sqlInsert, e.Actor, e.Target, e.Action, diff, personal, e.IP, e.CreatedAt,
+		e.PrevHash, e.Hash).Scan(&e.ID); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Find returns up to f.Limit+1 entries matching f, newest first, so the caller can tell whether there's a next page.
+func (s *StoreDB) Find(ctx context.Context, f Filter) ([]Entry, lib.APIError) {
+	sqlFind := `SELECT id, actor, target, action, diff, personal, ip, created_at, prev_hash, hash FROM audit_log
+				WHERE ($1 = '' OR actor = $1)
+				  AND ($2 = '' OR target = $2)
+				  AND ($3 = '' OR action = $3)
+				  AND ($4::timestamptz IS NULL OR created_at >= $4)
+				  AND ($5::timestamptz IS NULL OR created_at < $5)
+				  AND ($6 = 0 OR id < $6)
+				ORDER BY id DESC LIMIT $7`
+
+	rows, err := s.db.QueryContext(ctx, sqlFind, f.Actor, f.Target, f.Action,
+		sql.NullTime{Time: f.From, Valid: !f.From.IsZero()}, sql.NullTime{Time: f.To, Valid: !f.To.IsZero()},
+		f.BeforeID, f.Limit+1)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to query audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0, f.Limit+1)
+
+	for rows.Next() {
+		var e Entry
+		if e, err = scanEntry(rows); err != nil {
+			s.l.ErrorContext(ctx, "unable to scan audit log entry", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.l.ErrorContext(ctx, "unable to iterate audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return entries, nil
+}
+
+// FindBySubject returns every entry the user with the id acted in or was the target of, oldest first,
+// for exports of the user's personal data.
+func (s *StoreDB) FindBySubject(ctx context.Context, id string) ([]Entry, lib.APIError) {
+	sqlFind := `SELECT id, actor, target, action, diff, personal, ip, created_at, prev_hash, hash FROM audit_log
+				WHERE actor = $1 OR target = $1 ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, sqlFind, id)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to query audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+
+	for rows.Next() {
+		var e Entry
+		if e, err = scanEntry(rows); err != nil {
+			s.l.ErrorContext(ctx, "unable to scan audit log entry", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.l.ErrorContext(ctx, "unable to iterate audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return entries, nil
+}
+
+// Verify walks the whole chain from the first entry and reports every entry that was changed, or follows
+// removed entries.
+func (s *StoreDB) Verify(ctx context.Context) (*Report, lib.APIError) {
+	sqlAll := `SELECT id, actor, target, action, diff, personal, ip, created_at, prev_hash, hash FROM audit_log
+			   ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, sqlAll)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to query audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	chain := NewChain()
+	report := Report{Broken: []Broken{}}
+
+	for rows.Next() {
+		var e Entry
+		if e, err = scanEntry(rows); err != nil {
+			s.l.ErrorContext(ctx, "unable to scan audit log entry", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if chainErr := chain.Next(e); chainErr != nil {
+			report.Broken = append(report.Broken, Broken{ID: e.ID, Reason: chainErr.Error()})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		s.l.ErrorContext(ctx, "unable to iterate audit log", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	report.Checked = chain.Checked()
+	report.Head = chain.Head()
+
+	return &report, nil
+}
+
+// Contains reports whether the chain has an entry with the hash, to check a head kept from an earlier
+// verification is still part of it, so entries weren't removed from the end.
+func (s *StoreDB) Contains(ctx context.Context, hash string) (bool, lib.APIError) {
+	sqlExists := `SELECT EXISTS (SELECT 1 FROM audit_log WHERE hash = $1)`
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, sqlExists, hash).Scan(&exists); err != nil {
+		s.l.ErrorContext(ctx, "unable to query audit log", "err", err.Error())
+		return false, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return exists, nil
+}
+
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	var e Entry
+	var diff, personal []byte
+
+	if err := rows.Scan(&e.ID, &e.Actor, &e.Target, &e.Action, &diff, &personal, &e.IP, &e.CreatedAt, &e.PrevHash,
+		&e.Hash); err != nil {
+		return e, err
+	}
+
+	e.CreatedAt = e.CreatedAt.UTC()
+
+	if personal != nil {
+		if err := json.Unmarshal(personal, &e.Personal); err != nil {
+			return e, err
+		}
+	}
+
+	return e, json.Unmarshal(diff, &e.Diff)
+}
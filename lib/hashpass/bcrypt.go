@@ -0,0 +1,50 @@
+package hashpass
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcrypt uses the cost passwords were hashed with before argon2id became the default.
+var DefaultBcrypt = Bcrypt{Cost: 10}
+
+// Bcrypt hashes passwords with bcrypt, its modular crypt format carries the cost.
+// Only the first 72 bytes of a password are hashed, longer passwords are rejected by Hash.
+type Bcrypt struct {
+	Cost int
+}
+
+// nolint:wrapcheck
+func (b Bcrypt) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashedPassword), nil
+}
+
+func (b Bcrypt) Supports(encoded string) bool {
+	return hasPrefix(encoded, "$2a$", "$2b$", "$2y$")
+}
+
+func (b Bcrypt) Compare(encoded string, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return ErrMismatch
+	default:
+		return fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
+	}
+}
+
+func (b Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+
+	return err != nil || cost != b.Cost
+}
@@ -0,0 +1,75 @@
+package hashpass
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+var (
+	ErrMismatch          = errors.New("password doesn't match the hash")
+	ErrUnsupportedFormat = errors.New("unsupported password hash format")
+)
+
+// Hasher hashes passwords into a self-describing encoded form, which carries the algorithm and its
+// parameters, so hashes made with other parameters or algorithms can still be verified.
+type Hasher interface {
+	// Hash returns the encoded hash of a password.
+	Hash(password string) (string, error)
+	// Supports reports whether the encoded hash is in this hasher's format.
+	Supports(encoded string) bool
+	// Compare checks a password against an encoded hash in this hasher's format, returns ErrMismatch if it differs.
+	Compare(encoded string, password string) error
+	// NeedsRehash reports whether an encoded hash in this hasher's format was made with outdated parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// Default hashes new passwords, hashes of other algorithms or outdated parameters are upgraded to it on login.
+var Default Hasher = DefaultArgon2id
+
+// hashers verify every supported format, the default first.
+var hashers = []Hasher{DefaultArgon2id, DefaultBcrypt}
+
+// Generate hashes a given password with the Default hasher.
+func Generate(ctx context.Context, pass string, l *slog.Logger) (string, lib.APIError) {
+	hashedPassword, err := Default.Hash(pass)
+	if err != nil {
+		l.WarnContext(ctx, lib.ErrHashingPassword, "err", err)
+		return "", lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	return hashedPassword, nil
+}
+
+// Verify checks a password against an encoded hash of any supported format.
+// needsRehash is true when the password matched, but the hash isn't in the Default hasher's format
+// or uses outdated parameters, so the caller should store a fresh hash of the password.
+func Verify(encoded string, password string) (needsRehash bool, err error) {
+	for _, h := range hashers {
+		if !h.Supports(encoded) {
+			continue
+		}
+
+		if err = h.Compare(encoded, password); err != nil {
+			return false, err
+		}
+
+		return !Default.Supports(encoded) || Default.NeedsRehash(encoded), nil
+	}
+
+	return false, ErrUnsupportedFormat
+}
+
+// hasPrefix reports whether an encoded hash starts with any of the prefixes.
+func hasPrefix(encoded string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(encoded, p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package hashpass
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	const password = "correct horse battery"
+
+	mustHash := func(h Hasher) string {
+		encoded, err := h.Hash(password)
+		if err != nil {
+			t.Fatalf("Hash() unexpected error = %v", err)
+		}
+
+		return encoded
+	}
+
+	weakArgon2id := DefaultArgon2id
+	weakArgon2id.Iterations = 1
+
+	tests := []struct {
+		name            string
+		encoded         string
+		password        string
+		wantNeedsRehash bool
+		wantErr         error
+	}{
+		{name: "Argon2id", encoded: mustHash(DefaultArgon2id), password: password},
+		{name: "Argon2id_Mismatch", encoded: mustHash(DefaultArgon2id), password: "wrong password",
+			wantErr: ErrMismatch},
+		{name: "Argon2id_Outdated_Params", encoded: mustHash(weakArgon2id), password: password,
+			wantNeedsRehash: true},
+		{name: "Bcrypt", encoded: mustHash(Bcrypt{Cost: 4}), password: password, wantNeedsRehash: true},
+		{name: "Bcrypt_Mismatch", encoded: mustHash(Bcrypt{Cost: 4}), password: "wrong password",
+			wantErr: ErrMismatch},
+		{name: "Malformed_Argon2id", encoded: "$argon2id$v=19$m=19456$salt$key", password: password,
+			wantErr: ErrUnsupportedFormat},
+		{name: "Unsupported", encoded: "plaintext", password: password, wantErr: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash, err := Verify(tt.encoded, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if needsRehash != tt.wantNeedsRehash {
+				t.Errorf("Verify() needsRehash = %v, want %v", needsRehash, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
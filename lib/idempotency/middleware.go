@@ -0,0 +1,158 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	MaxKeyLength = 255
+
+	// MaxBodySize limits the request bodies read to fingerprint them.
+	MaxBodySize = 1 << 20
+
+	// finishTimeout limits recording or releasing a key once the request was handled.
+	finishTimeout = 5 * time.Second
+)
+
+// replayedHeaders are the response headers recorded and replayed along with the status and body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// ScopeFunc returns the scope of a request's idempotency key, usually the authenticated user or client,
+// so clients can't replay each other's responses.
+type ScopeFunc func(c *gin.Context) string
+
+// Middleware makes mutating requests with an Idempotency-Key header safe to retry. The response of the first
+// request with a key is recorded and replayed for retries with the same method, path and body.
+// A key reused for a different request is rejected with 422, a retry while the first request is still
+// in flight with 409. Server errors aren't recorded, a retry runs the request again.
+// Requests without the header, and safe methods, are passed through.
+func Middleware(store Store, scope ScopeFunc, l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderKey)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if len(key) > MaxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key can't exceed 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodySize))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unable to read request body"})
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		s := scope(c)
+		fingerprint := Fingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		rec, locked, apiErr := store.Begin(ctx, s, key, fingerprint)
+		if apiErr != nil {
+			c.AbortWithStatusJSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+			return
+		}
+
+		if !locked {
+			replay(c, rec, fingerprint)
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		// the request was handled, its key is recorded or released even if the client went away meanwhile
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		defer cancel()
+
+		if w.Status() >= http.StatusInternalServerError {
+			_ = store.Release(ctx, s, key)
+			return
+		}
+
+		res := Record{Fingerprint: fingerprint, Status: w.Status(), Header: make(http.Header), Body: w.body.Bytes()}
+		for _, h := range replayedHeaders {
+			if v := w.Header().Get(h); v != "" {
+				res.Header.Set(h, v)
+			}
+		}
+
+		if apiErr = store.Complete(ctx, s, key, res); apiErr != nil {
+			l.ErrorContext(ctx, "response not recorded, a retry runs the request again", "err", apiErr.WithCauses(),
+				"status", res.Status)
+		}
+	}
+}
+
+// Fingerprint identifies a request by its method, uri and body, a key may only be retried with the same one.
+func Fingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay responds to a retry with the recorded response, or rejects it.
+func replay(c *gin.Context, rec *Record, fingerprint string) {
+	switch {
+	case rec.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			gin.H{"error": "idempotency key was already used for a different request"})
+	case !rec.Completed():
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict,
+			gin.H{"error": "a request with this idempotency key is still in progress, retry later"})
+	default:
+		for h := range rec.Header {
+			c.Header(h, rec.Header.Get(h))
+		}
+
+		c.Header(HeaderReplayed, "true")
+		c.Data(rec.Status, rec.Header.Get("Content-Type"), rec.Body)
+		c.Abort()
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordingWriter keeps a copy of the response body to record it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/gin-gonic/gin"
+)
+
+// memStore is an in-memory Store for tests.
+type memStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func (s *memStore) Begin(_ context.Context, scope, key, fingerprint string) (*Record, bool, lib.APIError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[scope+key]; ok {
+		return rec, false, nil
+	}
+
+	s.records[scope+key] = &Record{Fingerprint: fingerprint}
+
+	return nil, true, nil
+}
+
+func (s *memStore) Complete(_ context.Context, scope, key string, res Record) lib.APIError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[scope+key] = &res
+
+	return nil
+}
+
+func (s *memStore) Release(_ context.Context, scope, key string) lib.APIError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, scope+key)
+
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &memStore{records: map[string]*Record{"user-1in-flight": {Fingerprint: Fingerprint(http.MethodPost,
+		"/users", []byte(`{"n":1}`))}}}
+	calls := 0
+
+	r := gin.New()
+	r.Use(Middleware(store, func(c *gin.Context) string { return "user-1" }, slog.Default()))
+	r.POST("/users", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/users/1")
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unexpected"})
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		key          string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "First", path: "/users", key: "k1", body: `{"n":1}`, wantStatus: http.StatusCreated,
+			wantBody: `{"calls":1}`, wantCalls: 1},
+		{name: "Retry_Replayed", path: "/users", key: "k1", body: `{"n":1}`, wantStatus: http.StatusCreated,
+			wantBody: `{"calls":1}`, wantReplayed: true, wantCalls: 1},
+		{name: "Other_Body", path: "/users", key: "k1", body: `{"n":2}`, wantStatus: http.StatusUnprocessableEntity,
+			wantCalls: 1},
+		{name: "In_Flight", path: "/users", key: "in-flight", body: `{"n":1}`, wantStatus: http.StatusConflict,
+			wantCalls: 1},
+		{name: "Without_Key", path: "/users", body: `{"n":1}`, wantStatus: http.StatusCreated,
+			wantBody: `{"calls":2}`, wantCalls: 2},
+		{name: "Server_Error", path: "/fail", key: "k2", wantStatus: http.StatusInternalServerError, wantCalls: 3},
+		{name: "Server_Error_Retried", path: "/fail", key: "k2", wantStatus: http.StatusInternalServerError,
+			wantCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set(HeaderKey, tt.key)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+
+			if replayed := w.Header().Get(HeaderReplayed) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+
+			if tt.wantReplayed && w.Header().Get("Location") != "/users/1" {
+				t.Errorf("Location = %q, want the recorded one", w.Header().Get("Location"))
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// ctxStore is a memStore failing to record responses with a canceled context, like a database would.
+type ctxStore struct {
+	memStore
+}
+
+func (s *ctxStore) Complete(ctx context.Context, scope, key string, res Record) lib.APIError {
+	if err := ctx.Err(); err != nil {
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return s.memStore.Complete(ctx, scope, key, res)
+}
+
+func TestMiddlewareClientGone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &ctxStore{memStore{records: map[string]*Record{}}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := gin.New()
+	r.Use(Middleware(store, func(c *gin.Context) string { return "user-1" }, slog.Default()))
+	r.POST("/users", func(c *gin.Context) {
+		cancel() // the client disconnects while the request is handled
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`)).WithContext(ctx)
+	req.Header.Set(HeaderKey, "key")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if rec := store.records["user-1key"]; rec == nil || !rec.Completed() {
+		t.Errorf("response of a request whose client went away wasn't recorded: %+v", rec)
+	}
+}
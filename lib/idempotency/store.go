@@ -0,0 +1,178 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const (
+	// DefaultTTL is how long responses are kept for retries, keys can be reused for other requests afterward.
+	DefaultTTL = 24 * time.Hour
+
+	// DefaultLockTimeout is how long a key stays locked by a request in flight, after it a retry may take it over,
+	// in case the instance handling the request went away before recording the response.
+	DefaultLockTimeout = time.Minute
+
+	DefaultPurgeInterval = time.Hour
+)
+
+// Record is the request an idempotency key was first used for, and its response once it completed.
+type Record struct {
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Completed reports whether the response was recorded, otherwise the request is still in flight.
+func (r *Record) Completed() bool {
+	return r.Status != 0
+}
+
+// Store records responses by scope and idempotency key, scope separates the keys of different clients.
+type Store interface {
+	// Begin locks a key for a request with the given fingerprint and returns true,
+	// or returns the record of the earlier request that used the key.
+	Begin(ctx context.Context, scope, key, fingerprint string) (*Record, bool, lib.APIError)
+
+	// Complete records the response of the request holding the key.
+	Complete(ctx context.Context, scope, key string, res Record) lib.APIError
+
+	// Release unlocks a key without a response, so the request can be retried.
+	Release(ctx context.Context, scope, key string) lib.APIError
+}
+
+// StoreDB is a Store in the idempotency_keys table, shared by every instance of a service.
+type StoreDB struct {
+	db *sql.DB
+	l  *slog.Logger
+
+	ttl         time.Duration
+	lockTimeout time.Duration
+}
+
+func NewStoreDB(db *sql.DB, l *slog.Logger) *StoreDB {
+	return &StoreDB{
+		db:          db,
+		l:           l,
+		ttl:         DefaultTTL,
+		lockTimeout: DefaultLockTimeout,
+	}
+}
+
+// Begin locks a key for a request, unless it's used by an unexpired earlier request.
+// A key locked by a request that didn't complete within the lock timeout is taken over by a retry.
+func (s *StoreDB) Begin(ctx context.Context, scope, key, fingerprint string) (*Record, bool, lib.APIError) {
+	sqlLock := `INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, locked_until, expires_at)
+				VALUES ($1, $2, $3, now() + make_interval(secs => $4), now() + make_interval(secs => $5))
+				ON CONFLICT (scope, idempotency_key) DO UPDATE
+				SET fingerprint = excluded.fingerprint, status = NULL, headers = NULL, body = NULL,
+					locked_until = excluded.locked_until, expires_at = excluded.expires_at, created_at = now()
+				WHERE idempotency_keys.expires_at <= now()
+				   OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= now()
+					   AND idempotency_keys.fingerprint = excluded.fingerprint)
+				RETURNING true`
+	sqlFind := `SELECT fingerprint, coalesce(status, 0), headers, body FROM idempotency_keys
+				WHERE scope = $1 AND idempotency_key = $2`
+
+	var locked bool
+
+	err := s.db.QueryRowContext(ctx, sqlLock, scope, key, fingerprint, s.lockTimeout.Seconds(),
+		s.ttl.Seconds()).Scan(&locked)
+	if err == nil {
+		return nil, true, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		s.l.ErrorContext(ctx, "unable to lock idempotency key", "err", err.Error())
+		return nil, false, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	var (
+		rec    Record
+		header []byte
+	)
+
+	err = s.db.QueryRowContext(ctx, sqlFind, scope, key).Scan(&rec.Fingerprint, &rec.Status, &header, &rec.Body)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to query idempotency key", "err", err.Error())
+		return nil, false, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if len(header) > 0 {
+		if err = json.Unmarshal(header, &rec.Header); err != nil {
+			s.l.ErrorContext(ctx, "unable to decode recorded headers", "err", err.Error())
+			return nil, false, lib.InternalServerError(lib.ErrUnexpected, err)
+		}
+	}
+
+	return &rec, false, nil
+}
+
+// Complete records the response of a request, it's replayed for retries until the key expires.
+func (s *StoreDB) Complete(ctx context.Context, scope, key string, res Record) lib.APIError {
+	sqlComplete := `UPDATE idempotency_keys SET status = $3, headers = $4, body = $5, locked_until = NULL
+					WHERE scope = $1 AND idempotency_key = $2`
+
+	header, err := json.Marshal(res.Header)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to encode response headers", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	if _, err = s.db.ExecContext(ctx, sqlComplete, scope, key, res.Status, header, res.Body); err != nil {
+		s.l.ErrorContext(ctx, "unable to record idempotent response", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// Release deletes the lock of a request that didn't complete, so a retry runs it again.
+func (s *StoreDB) Release(ctx context.Context, scope, key string) lib.APIError {
+	sqlRelease := `DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND status IS NULL`
+
+	if _, err := s.db.ExecContext(ctx, sqlRelease, scope, key); err != nil {
+		s.l.ErrorContext(ctx, "unable to release idempotency key", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// Purge deletes expired keys, they are taken over by new requests anyway, so it only reclaims space.
+func (s *StoreDB) Purge(ctx context.Context) lib.APIError {
+	sqlPurge := `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+
+	if _, err := s.db.ExecContext(ctx, sqlPurge); err != nil {
+		s.l.ErrorContext(ctx, "unable to purge idempotency keys", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// StartPurge purges expired keys every interval until ctx is done.
+func (s *StoreDB) StartPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = s.Purge(ctx)
+			}
+		}
+	}()
+}
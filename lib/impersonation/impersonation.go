@@ -0,0 +1,100 @@
+package impersonation
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ClaimActor is the claim of impersonation tokens carrying the admin acting as the token's user (RFC 8693 act),
+// e.g. {"act": {"UserID": "<admin uuid>"}}. The token's own claims are those of the impersonated user.
+const ClaimActor = "act"
+
+const mapKeyUserID = "UserID"
+
+// MoneyMovingRoutes are the route patterns of resources that move funds, impersonation tokens may read them,
+// but are never authorized to change them, whatever the impersonated user's role is granted.
+var MoneyMovingRoutes = []string{
+	"*:/wallets/**",
+	"*:/transfers/**",
+	"*:/payments/**",
+	"*:/withdrawals/**",
+	"*:/deposits/**",
+	"*:/bids/**",
+}
+
+// Actor is the admin acting as another user with an impersonation token.
+type Actor struct {
+	UserID string
+}
+
+// ActorFromClaims returns the admin user id of an impersonation token and true, or false for other tokens.
+func ActorFromClaims(claims jwt.MapClaims) (string, bool) {
+	act, ok := claims[ClaimActor].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	userID, _ := act[mapKeyUserID].(string)
+
+	return userID, userID != ""
+}
+
+// Allows reports whether an impersonation token may access routeName ("METHOD:/full/path"),
+// reads are allowed everywhere, money-moving routes can't be changed.
+func Allows(routeName string) bool {
+	method, _, _ := strings.Cut(routeName, ":")
+	if method == http.MethodGet || method == http.MethodHead {
+		return true
+	}
+
+	return !policy.ScopesAuthorize(MoneyMovingRoutes, routeName)
+}
+
+// Request is a request made with an impersonation token, recorded so the impersonated user can review it.
+type Request struct {
+	ImpersonationID string
+	Method          string
+	Path            string
+	Status          int
+	IP              string
+}
+
+// Recorder records requests made with impersonation tokens.
+type Recorder interface {
+	Record(ctx context.Context, r Request)
+}
+
+// RecorderDB records requests in the impersonation_requests table, impersonations are created by the auth-api.
+type RecorderDB struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewRecorderDB(db *sql.DB, l *slog.Logger) *RecorderDB {
+	return &RecorderDB{
+		db: db,
+		l:  l,
+	}
+}
+
+// Record stores a request, failures are logged along with the request, so it's never lost from the logs.
+func (r *RecorderDB) Record(ctx context.Context, req Request) {
+	sqlInsert := `INSERT INTO impersonation_requests (impersonation_id, method, path, status, ip)
+				  VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.ExecContext(ctx, sqlInsert, req.ImpersonationID, req.Method, req.Path, req.Status, req.IP)
+	if err != nil {
+		r.l.ErrorContext(ctx, "unable to record impersonated request", "err", err.Error(),
+			"impersonationId", req.ImpersonationID, "method", req.Method, "path", req.Path, "status", req.Status)
+		return
+	}
+
+	r.l.InfoContext(ctx, "impersonated request", "impersonationId", req.ImpersonationID, "method", req.Method,
+		"path", req.Path, "status", req.Status)
+}
@@ -0,0 +1,52 @@
+package impersonation
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		routeName string
+		want      bool
+	}{
+		{routeName: "GET:/users/:user_id", want: true},
+		{routeName: "POST:/users/:user_id/profile", want: true},
+		{routeName: "GET:/wallets/:wallet_id", want: true},
+		{routeName: "POST:/wallets", want: false},
+		{routeName: "POST:/transfers", want: false},
+		{routeName: "DELETE:/bids/:bid_id", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.routeName, func(t *testing.T) {
+			if got := Allows(tt.routeName); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.routeName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActorFromClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   string
+		wantOk bool
+	}{
+		{name: "Impersonation", claims: jwt.MapClaims{ClaimActor: map[string]interface{}{"UserID": "admin-1"}},
+			want: "admin-1", wantOk: true},
+		{name: "Regular", claims: jwt.MapClaims{"UserID": "user-1"}},
+		{name: "Empty_Actor", claims: jwt.MapClaims{ClaimActor: map[string]interface{}{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ActorFromClaims(tt.claims)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ActorFromClaims() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package jwtutils
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIntrospectionClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{
+			name: "User_Token",
+			claims: jwt.MapClaims{"TokenType": tokenTypeAccess, "Username": "johndoe1", "UserID": "user-1",
+				"Email": "john@example.com", "Role": "user", "Status": "active", "SessionID": "session-1",
+				"jti": "token-1", "sub": "user-1", "iat": float64(1700000000), "exp": float64(1700003600)},
+		},
+		{
+			name: "Client_Token",
+			claims: jwt.MapClaims{"TokenType": tokenTypeAccess, "client_id": "wallet-api", "scope": "GET:/users/*",
+				"jti": "token-2", "sub": "wallet-api", "iat": float64(1700000000), "exp": float64(1700000900)},
+		},
+		{
+			name: "API_Key",
+			claims: jwt.MapClaims{"TokenType": tokenTypeAccess, "Username": "merchant1", "UserID": "user-3",
+				"Email": "shop@example.com", "Role": "merchant", "Status": "active", "SessionID": "",
+				"api_key_id": "key-1", "scope": "POST:/users/*", "sub": "user-3"},
+		},
+		{
+			name: "Impersonation_Token",
+			claims: jwt.MapClaims{"TokenType": tokenTypeAccess, "Username": "johndoe1", "UserID": "user-1",
+				"Email": "john@example.com", "Role": "user", "Status": "active", "SessionID": "impersonation-1",
+				"act": map[string]interface{}{"UserID": "admin-1"}, "jti": "token-3", "sub": "user-1",
+				"iat": float64(1700000000), "exp": float64(1700000900)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewIntrospection(tt.claims)
+			if !i.Active || i.TokenType != tokenTypeBearer {
+				t.Fatalf("NewIntrospection() = %+v, want an active bearer token", i)
+			}
+
+			got := i.Claims()
+			if len(got) != len(tt.claims) {
+				t.Errorf("Claims() = %v, want %v", got, tt.claims)
+			}
+
+			for k, v := range tt.claims {
+				if !reflect.DeepEqual(got[k], v) {
+					t.Errorf("Claims()[%q] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "user-api" || secret != "s3cret" || r.URL.Path != IntrospectPath {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		i := Introspection{Active: r.PostFormValue("token") == "active-token", UserID: "user-1", Role: "user"}
+		_ = json.NewEncoder(w).Encode(i)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("unable to parse server url: %v", err)
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatalf("unable to split server host: %v", err)
+	}
+
+	t.Setenv("API_SCHEME", u.Scheme)
+	t.Setenv("API_HOST", host)
+	t.Setenv("AUTH_API_PORT", port)
+	t.Setenv("AUTH_CLIENT_ID", "user-api")
+
+	tests := []struct {
+		name         string
+		token        string
+		clientSecret string
+		wantErr      error
+	}{
+		{name: "Active", token: "active-token", clientSecret: "s3cret"},
+		{name: "Inactive", token: "expired-token", clientSecret: "s3cret", wantErr: ErrInactiveToken},
+		{name: "Wrong_Client_Secret", token: "active-token", clientSecret: "wrong", wantErr: ErrUnauthorized},
+		{name: "Missing_Client_Secret", token: "active-token", wantErr: ErrEmptyClientCredentials},
+		{name: "Empty_Token", token: "", clientSecret: "s3cret", wantErr: ErrEmptyToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_CLIENT_SECRET", tt.clientSecret)
+
+			claims, err := IntrospectToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IntrospectToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && (claims["UserID"] != "user-1" || claims["TokenType"] != tokenTypeAccess) {
+				t.Errorf("IntrospectToken() claims = %v", claims)
+			}
+		})
+	}
+}
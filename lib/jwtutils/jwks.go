@@ -0,0 +1,202 @@
+package jwtutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	JWKSPath = "/.well-known/jwks.json"
+
+	jwksCacheTTL        = 10 * time.Minute
+	jwksMinRefetchDelay = 10 * time.Second
+)
+
+var ErrJWKSUnavailable = errors.New("unable to fetch JWKS")
+
+// JWKS is a JSON Web Key Set as defined in RFC 7517.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key, RSA keys use N and E, Ed25519 keys (RFC 8037) use Crv and X.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// NewJWK encodes the public part of a key.
+func NewJWK(k *Key) (JWK, error) {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, ErrUnsupportedKey
+	}
+
+	return jwk, nil
+}
+
+// Key decodes the JWK into a verification only Key.
+func (j JWK) Key() (*Key, error) {
+	switch {
+	case j.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+
+		return NewKey(j.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())})
+	case j.Kty == "OKP" && j.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key: %w", errors.Join(err, ErrUnsupportedKey))
+		}
+
+		return NewKey(j.Kid, ed25519.PublicKey(x))
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// JWKSCache is a KeyResolver backed by a remote JWKS endpoint.
+// Keys are cached for a while, an unknown key id triggers a refetch so newly rotated keys are picked up,
+// refetches are spaced out to protect the endpoint from tokens with made up key ids.
+// A single fetch runs at a time, without holding the lock, cached keys are served meanwhile and callers
+// waiting for an unknown key id share its result. If a refetch fails, previously fetched keys keep being served.
+type JWKSCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*Key
+	fetchedAt time.Time
+	// fetching is closed once the fetch in flight is done, nil if there's none
+	fetching chan struct{}
+	fetchErr error
+}
+
+func NewJWKSCache(url string, client *http.Client) *JWKSCache {
+	return &JWKSCache{url: url, client: client, keys: make(map[string]*Key)}
+}
+
+func (c *JWKSCache) ResolveKey(kid string) (*Key, error) {
+	c.mu.Lock()
+
+	k, ok := c.keys[kid]
+	sinceFetch := time.Since(c.fetchedAt)
+
+	if ok && (sinceFetch < jwksCacheTTL || c.fetching != nil) {
+		c.mu.Unlock()
+		return k, nil
+	}
+
+	if done := c.fetching; done != nil {
+		c.mu.Unlock()
+		<-done
+
+		return c.fetched(kid)
+	}
+
+	if !ok && sinceFetch < jwksMinRefetchDelay {
+		c.mu.Unlock()
+		return nil, ErrUnknownKeyID
+	}
+
+	done := make(chan struct{})
+	c.fetching = done
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	keys, err := c.fetch()
+
+	c.mu.Lock()
+	if err == nil {
+		c.keys = keys
+	}
+
+	c.fetchErr = err
+	c.fetching = nil
+	c.mu.Unlock()
+	close(done)
+
+	if err != nil && ok {
+		return k, nil
+	}
+
+	return c.fetched(kid)
+}
+
+// fetched returns a key after a fetch, or the error of the fetch if the key isn't known.
+func (c *JWKSCache) fetched(kid string) (*Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k, ok := c.keys[kid]; ok {
+		return k, nil
+	}
+
+	if c.fetchErr != nil {
+		return nil, c.fetchErr
+	}
+
+	return nil, ErrUnknownKeyID
+}
+
+// fetch fetches the remote key set, it's called without holding the lock.
+func (c *JWKSCache) fetch() (map[string]*Key, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJWKSUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrJWKSUnavailable, resp.StatusCode)
+	}
+
+	var set JWKS
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJWKSUnavailable, err)
+	}
+
+	keys := make(map[string]*Key, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		k, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+
+		keys[k.ID] = k
+	}
+
+	return keys, nil
+}
@@ -1,36 +1,133 @@
 package jwtutils
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	mapKeyTokenID   = "jti"
+	mapKeySessionID = "SessionID"
+
+	authAPITimeout = 5 * time.Second
+)
+
+// authAPIClient is shared by every call to the auth-api, so connections to it are reused.
+var authAPIClient = &http.Client{
+	Timeout: authAPITimeout,
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
 var (
-	HMACSecret        = []byte(os.Getenv("HMACSecret"))
-	ErrUnauthorized   = errors.New("unauthorized")
-	ErrEmptyToken     = errors.New("token cannot be empty")
-	ErrEmptyEnvVars   = errors.New("API_HOST, AUTH_API_PORT, or API_SCHEME cannot be empty")
-	ErrInvalidURL     = errors.New("could not build a valid URL")
-	ErrEmptyRouteName = errors.New("routeName cannot be empty")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrEmptyToken   = errors.New("token cannot be empty")
+	ErrEmptyEnvVars = errors.New("API_HOST, AUTH_API_PORT, or API_SCHEME cannot be empty")
+	ErrInvalidURL   = errors.New("could not build a valid URL")
+	ErrTokenRevoked = errors.New("token has been revoked")
 )
 
+// RevocationList reports whether a validly signed and unexpired token was revoked before its expiry.
+// Tokens issued at the very time their user's tokens were revoked are revoked too, iat has millisecond
+// precision, so does a token issued in the same millisecond after the revocation, it fails closed.
+type RevocationList interface {
+	IsRevoked(tokenID, sessionID, userID string, issuedAt time.Time) bool
+}
+
+// mu guards the revocation lists and the key resolver, services running in one process share them.
+var (
+	mu              sync.RWMutex
+	revocationLists []RevocationList
+	keyResolver     KeyResolver
+)
+
+func init() {
+	// iat is issued with millisecond precision, so a token issued right after its user's tokens were revoked
+	// isn't taken as issued at the same time, see RevocationList
+	jwt.TimePrecision = time.Millisecond
+}
+
+// UseRevocationList makes ParseAndValidateToken reject tokens revoked in rl, it must be called before serving
+// requests. Every list in use is consulted, a token revoked in any of them is rejected.
+func UseRevocationList(rl RevocationList) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	revocationLists = append(revocationLists, rl)
+}
+
+// UseKeyResolver makes ParseAndValidateToken verify signatures with keys resolved by r,
+// it must be called before serving requests. Without it, keys are fetched from the auth-api JWKS endpoint.
+func UseKeyResolver(r KeyResolver) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	keyResolver = r
+}
+
+// resolver returns the key resolver in use, by default a JWKS cache of the auth-api, located by
+// the JWKS_URL environment variable or built from API_SCHEME, API_HOST and AUTH_API_PORT.
+func resolver() KeyResolver {
+	mu.RLock()
+	r := keyResolver
+	mu.RUnlock()
+
+	if r != nil {
+		return r
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if keyResolver == nil {
+		jwksURL := os.Getenv("JWKS_URL")
+		if jwksURL == "" {
+			u, err := buildAuthAPIURL(JWKSPath)
+			if err == nil {
+				jwksURL = u.String()
+			}
+		}
+
+		keyResolver = NewJWKSCache(jwksURL, authAPIClient)
+	}
+
+	return keyResolver
+}
+
 // ParseAndValidateToken parses a JWT token string and validates its signature.
+// Tokens must be signed with RS256 or EdDSA by a key whose id (kid header) the key resolver knows.
+// If a revocation list is in use, revoked tokens are rejected with ErrTokenRevoked.
 // The function returns the parsed token if it's valid, and an error otherwise.
 // nolint:wrapcheck
 func ParseAndValidateToken(tokenStr string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, ok := token.Header["kid"].(string)
+		if !ok || kid == "" {
+			return nil, ErrMissingKeyID
+		}
+
+		key, err := resolver().ResolveKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.Algorithm {
 			return nil, jwt.ErrTokenSignatureInvalid
 		}
 
-		return HMACSecret, nil
-	})
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		switch {
@@ -47,81 +144,56 @@ func ParseAndValidateToken(tokenStr string) (*jwt.Token, error) {
 		}
 	}
 
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && IsRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+
 	return token, nil
 }
 
-// VerifyTokenWithAuthAPI sends a request to the Auth APIs verify endpoint to validate a JWT token.
-// The function takes a JWT token string as input and returns its claims if the token is valid.
-// If the token is invalid or an error occurs (e.g., failed to build the URL, HTTP request failure, etc.),
-// the function will return an error.
-func VerifyTokenWithAuthAPI(tokenStr string, routeName string, pathUserID string) (jwt.MapClaims, error) {
-	verifyURL, err := buildVerifyURL(tokenStr, routeName, pathUserID)
-	if err != nil {
-		return nil, fmt.Errorf("error building URL: %w", err)
-	}
-
-	var resp *http.Response
+// IsRevoked consults the revocation lists in use by the token id, session id, subject and issue time claims,
+// for claims validated before, e.g. cached ones, whose token may have been revoked since.
+func IsRevoked(claims jwt.MapClaims) bool {
+	mu.RLock()
+	lists := revocationLists
+	mu.RUnlock()
 
-	// nolint:gosec // using env variables to build secure verify url
-	resp, err = http.Get(verifyURL)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get response from verify url:%w", err)
+	if len(lists) == 0 {
+		return false
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, ErrUnauthorized
-	}
+	tokenID, _ := claims[mapKeyTokenID].(string)
+	sessionID, _ := claims[mapKeySessionID].(string)
+	userID, _ := claims.GetSubject()
 
-	var response struct {
-		Claims jwt.MapClaims `json:"claims"`
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
 	}
 
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("unable to decode json:%w", err)
+	for _, rl := range lists {
+		if rl.IsRevoked(tokenID, sessionID, userID, issuedAt) {
+			return true
+		}
 	}
 
-	return response.Claims, nil
+	return false
 }
 
-// buildVerifyURL constructs a URL for the verify endpoint of the Auth API.
-// The function takes a JWT token string as an argument, and returns a formatted URL.
-// It uses environment variables "API_HOST" and "AUTH_API_PORT" to determine the APIs location.
-// It returns an error if it fails to construct a valid URL.
-// e.g: http://127.0.0.1:8001/verify?token=JWT_TOKEN&routeName=?&userId=?
-func buildVerifyURL(tokenStr string, routeName string, pathUserID string) (string, error) {
-	if tokenStr == "" {
-		return "", ErrEmptyToken
-	}
-
-	if routeName == "" {
-		return "", ErrEmptyRouteName
-	}
-
+// buildAuthAPIURL constructs the URL of an Auth API endpoint path,
+// using environment variables "API_SCHEME", "API_HOST" and "AUTH_API_PORT".
+func buildAuthAPIURL(path string) (*url.URL, error) {
 	apiHost := os.Getenv("API_HOST")
 	authAPIPort := os.Getenv("AUTH_API_PORT")
 	apiScheme := os.Getenv("API_SCHEME")
 
 	if apiHost == "" || authAPIPort == "" || apiScheme == "" {
-		return "", ErrEmptyEnvVars
+		return nil, ErrEmptyEnvVars
 	}
 
-	u := &url.URL{
+	return &url.URL{
 		Scheme: apiScheme,
 		Host:   fmt.Sprintf("%s:%s", apiHost, authAPIPort),
-		Path:   "/verify",
-	}
-
-	q := u.Query()
-	q.Add("token", tokenStr)
-	q.Add("routeName", routeName)
-	q.Add("userId", pathUserID)
-	u.RawQuery = q.Encode()
-
-	_, err := url.ParseRequestURI(u.String())
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
-	}
-
-	return u.String(), nil
+		Path:   path,
+	}, nil
 }
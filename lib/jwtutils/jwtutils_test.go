@@ -11,6 +11,21 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var testKeySet = newTestKeySet()
+
+// newTestKeySet generates the key set tokens in these tests are signed with and makes it the key resolver.
+func newTestKeySet() *KeySet {
+	k, err := GenerateEd25519Key()
+	if err != nil {
+		panic(err)
+	}
+
+	ks := NewKeySet(k)
+	UseKeyResolver(ks)
+
+	return ks
+}
+
 func TestParseAndValidateToken(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -41,6 +56,13 @@ func TestParseAndValidateToken(t *testing.T) {
 			},
 			wantErr: jwt.ErrTokenSignatureInvalid,
 		},
+		{
+			name: "Unknown_Key_ID",
+			tokenFunc: func() (string, error) {
+				return createTokenWithUnknownKey()
+			},
+			wantErr: ErrUnknownKeyID,
+		},
 		{
 			name: "Expired_Token",
 			tokenFunc: func() (string, error) {
@@ -78,6 +100,76 @@ func TestParseAndValidateToken(t *testing.T) {
 	}
 }
 
+type fakeRevocationList map[string]bool
+
+func (f fakeRevocationList) IsRevoked(tokenID, sessionID, userID string, _ time.Time) bool {
+	return f[tokenID] || f[sessionID] || f[userID]
+}
+
+func TestParseAndValidateTokenRevoked(t *testing.T) {
+	UseRevocationList(fakeRevocationList{"revoked-jti": true})
+	UseRevocationList(fakeRevocationList{"revoked-user": true})
+	t.Cleanup(func() {
+		mu.Lock()
+		revocationLists = nil
+		mu.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		claims  jwt.RegisteredClaims
+		wantErr error
+	}{
+		{name: "Not_Revoked", claims: jwt.RegisteredClaims{ID: "jti", Subject: "user"}, wantErr: nil},
+		{name: "Revoked_Token", claims: jwt.RegisteredClaims{ID: "revoked-jti", Subject: "user"}, wantErr: ErrTokenRevoked},
+		{name: "Revoked_User", claims: jwt.RegisteredClaims{ID: "jti", Subject: "revoked-user"}, wantErr: ErrTokenRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+			tt.claims.IssuedAt = jwt.NewNumericDate(time.Now())
+
+			tokenStr, err := testKeySet.Sign(tt.claims)
+			if err != nil {
+				t.Fatalf("Error generating token: %v", err)
+			}
+
+			_, err = ParseAndValidateToken(tokenStr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseAndValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssuedAtPrecision(t *testing.T) {
+	now := time.Now()
+
+	tokenStr, err := testKeySet.Sign(jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+
+	token, err := ParseAndValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("ParseAndValidateToken() error = %v", err)
+	}
+
+	iat, err := token.Claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		t.Fatalf("GetIssuedAt() = %v, %v", iat, err)
+	}
+
+	// iat is truncated to the millisecond, and decoding its float may take another millisecond off
+	if d := now.Sub(iat.Time); d < 0 || d >= 2*time.Millisecond {
+		t.Errorf("iat = %v, want within 2ms before %v", iat.Time, now)
+	}
+}
+
 func createToken(exp time.Time, specialClaim string) (string, error) {
 	claims := &jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(exp),
@@ -86,25 +178,39 @@ func createToken(exp time.Time, specialClaim string) (string, error) {
 		claims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(HMACSecret)
+	return testKeySet.Sign(claims)
 }
 
+// createTokenWithWrongMethod signs a token with a shared secret, while naming a known key id.
 func createTokenWithWrongMethod() (string, error) {
 	claims := &jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = testKeySet.active.ID
+
+	return token.SignedString([]byte("hmacSampleSecret"))
+}
 
+func createTokenWithUnknownKey() (string, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		return "", err
 	}
 
-	return token.SignedString(privateKey)
+	k, err := NewKey("unknown", privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	return NewKeySet(k).Sign(claims)
 }
 
-func TestBuildVerifyURL(t *testing.T) {
+func TestBuildAuthAPIURL(t *testing.T) {
 	t.Cleanup(func() {
 		if err := os.Unsetenv("API_HOST"); err != nil {
 			t.Errorf("Failed to unset API_HOST: %v", err)
@@ -118,70 +224,49 @@ func TestBuildVerifyURL(t *testing.T) {
 	})
 
 	tests := []struct {
-		name       string
-		tokenStr   string
-		routeName  string
-		pathUserID string
-		host       string
-		port       string
-		scheme     string
-		wantURL    string
-		wantErr    error
+		name    string
+		path    string
+		host    string
+		port    string
+		scheme  string
+		wantURL string
+		wantErr error
 	}{
 		{
-			name:       "Valid",
-			tokenStr:   "token",
-			routeName:  "POST:/users",
-			pathUserID: "123",
-			host:       "localhost",
-			port:       "8080",
-			scheme:     "http",
-			wantURL:    "http://localhost:8080/verify?routeName=POST%3A%2Fusers&token=token&userId=123",
-			wantErr:    nil,
-		},
-		{
-			name:       "EmptyToken",
-			tokenStr:   "",
-			routeName:  "POST:/users",
-			pathUserID: "123",
-			host:       "localhost",
-			port:       "8080",
-			scheme:     "http",
-			wantURL:    "",
-			wantErr:    ErrEmptyToken,
+			name:    "Valid",
+			path:    IntrospectPath,
+			host:    "localhost",
+			port:    "8080",
+			scheme:  "http",
+			wantURL: "http://localhost:8080/introspect",
+			wantErr: nil,
 		},
 		{
-			name:       "EmptyRouteName",
-			tokenStr:   "token",
-			routeName:  "",
-			pathUserID: "123",
-			host:       "localhost",
-			port:       "8080",
-			scheme:     "http",
-			wantURL:    "",
-			wantErr:    ErrEmptyRouteName,
+			name:    "MissingHost",
+			path:    IntrospectPath,
+			host:    "",
+			port:    "8080",
+			scheme:  "http",
+			wantURL: "",
+			wantErr: ErrEmptyEnvVars,
 		},
 		{
-			name:       "MissingHost",
-			tokenStr:   "token",
-			routeName:  "POST:/users",
-			pathUserID: "123",
-			host:       "",
-			port:       "8080",
-			scheme:     "http",
-			wantURL:    "",
-			wantErr:    ErrEmptyEnvVars,
+			name:    "MissingPort",
+			path:    IntrospectPath,
+			host:    "localhost",
+			port:    "",
+			scheme:  "http",
+			wantURL: "",
+			wantErr: ErrEmptyEnvVars,
 		},
 		{
-			name:       "InvalidScheme",
-			tokenStr:   "token",
-			routeName:  "POST:/users",
-			pathUserID: "123",
-			host:       "localhost",
-			port:       "8080",
-			scheme:     "",
-			wantURL:    "",
-			wantErr:    ErrEmptyEnvVars,
+			name:    "InvalidScheme",
+			path:    IntrospectPath,
+			host:    "localhost",
+			port:    "8080",
+			scheme:  "",
+			wantURL: "",
+			wantErr: ErrEmptyEnvVars,
 		},
 	}
 
@@ -196,7 +281,7 @@ func TestBuildVerifyURL(t *testing.T) {
 			if err := os.Setenv("API_SCHEME", tt.scheme); err != nil {
 				t.Fatalf("Failed to set API_SCHEME: %v", err)
 			}
-			gotURL, err := buildVerifyURL(tt.tokenStr, tt.routeName, tt.pathUserID)
+			gotURL, err := buildAuthAPIURL(tt.path)
 
 			if tt.wantErr != nil {
 				if err == nil || !errors.Is(err, tt.wantErr) {
@@ -210,7 +295,7 @@ func TestBuildVerifyURL(t *testing.T) {
 				return
 			}
 
-			if gotURL != tt.wantURL {
+			if gotURL.String() != tt.wantURL {
 				t.Errorf("wanted URL %s, got %s", tt.wantURL, gotURL)
 			}
 		})
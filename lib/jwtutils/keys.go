@@ -0,0 +1,230 @@
+package jwtutils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	pemExt = ".pem"
+)
+
+var (
+	ErrUnknownKeyID      = errors.New("unknown key id")
+	ErrNoSigningKey      = errors.New("key set has no signing key")
+	ErrUnsupportedKey    = errors.New("unsupported key type, must be RSA or Ed25519")
+	ErrMissingKeyID      = errors.New("token header has no key id")
+	ErrInvalidPEM        = errors.New("file does not contain a PEM block")
+	ErrActiveKeyNotFound = errors.New("active key id not found among private keys")
+)
+
+// KeyResolver resolves the public key a token was signed with by its key id (kid).
+type KeyResolver interface {
+	ResolveKey(kid string) (*Key, error)
+}
+
+// Key is a signing or verification key identified by its key id, Private is nil for verification only keys.
+type Key struct {
+	ID        string
+	Algorithm string
+	Public    crypto.PublicKey
+	Private   crypto.Signer
+}
+
+// NewKey wraps an RSA or Ed25519 private or public key, the algorithm is derived from the key type.
+func NewKey(kid string, k any) (*Key, error) {
+	switch v := k.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Algorithm: AlgRS256, Public: &v.PublicKey, Private: v}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Algorithm: AlgEdDSA, Public: v.Public(), Private: v}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Algorithm: AlgRS256, Public: v}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Algorithm: AlgEdDSA, Public: v}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// GenerateEd25519Key generates a new Ed25519 key, its key id is derived from the public key.
+func GenerateEd25519Key() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate ed25519 key: %w", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal public key: %w", err)
+	}
+
+	sum := sha256.Sum256(der)
+
+	return NewKey(hex.EncodeToString(sum[:8]), private)
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+
+	return jwt.SigningMethodRS256
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are still verified with.
+// During rotation the previous keys stay in the set, so tokens they signed remain valid until they expire.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	order  []string
+}
+
+// NewKeySet returns a key set signing with active, it verifies with active and every other given key.
+func NewKeySet(active *Key, others ...*Key) *KeySet {
+	ks := &KeySet{active: active, keys: make(map[string]*Key)}
+
+	for _, k := range append([]*Key{active}, others...) {
+		if k == nil {
+			continue
+		}
+
+		if _, ok := ks.keys[k.ID]; !ok {
+			ks.order = append(ks.order, k.ID)
+		}
+
+		ks.keys[k.ID] = k
+	}
+
+	return ks
+}
+
+// LoadKeySet reads every PEM file in dir, the file name without extension is the key id.
+// Files may hold PKCS#8 or PKCS#1 private keys, or PKIX public keys of retired keys kept for verification.
+// The key with activeKID signs new tokens, if activeKID is empty the private key whose id sorts last is used,
+// so naming key files by creation date makes the newest key active.
+func LoadKeySet(dir string, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+pemExt))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list key files: %w", err)
+	}
+
+	sort.Strings(paths)
+
+	var active *Key
+	keys := make([]*Key, 0, len(paths))
+
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), pemExt)
+
+		k, err := readKeyFile(path, kid)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key %s: %w", kid, err)
+		}
+
+		keys = append(keys, k)
+
+		if k.Private != nil && (activeKID == "" || activeKID == kid) {
+			active = k
+		}
+	}
+
+	if active == nil {
+		if activeKID != "" {
+			return nil, ErrActiveKeyNotFound
+		}
+
+		return nil, ErrNoSigningKey
+	}
+
+	return NewKeySet(active, keys...), nil
+}
+
+// Sign signs the claims with the active key and sets its key id in the token header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil || ks.active.Private == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.active.signingMethod(), claims)
+	token.Header["kid"] = ks.active.ID
+
+	signed, err := token.SignedString(ks.active.Private)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign token: %w", err)
+	}
+
+	return signed, nil
+}
+
+// ResolveKey implements KeyResolver with the keys of the set.
+func (ks *KeySet) ResolveKey(kid string) (*Key, error) {
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	return k, nil
+}
+
+// JWKS returns the public keys of the set as a JSON Web Key Set.
+func (ks *KeySet) JWKS() (JWKS, error) {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.order))}
+
+	for _, kid := range ks.order {
+		jwk, err := NewJWK(ks.keys[kid])
+		if err != nil {
+			return JWKS{}, err
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+func readKeyFile(path string, kid string) (*Key, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	var k any
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", block.Type, err)
+	}
+
+	return NewKey(kid, k)
+}
@@ -0,0 +1,282 @@
+package jwtutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate rsa key: %v", err)
+	}
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate ed25519 key: %v", err)
+	}
+
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatalf("unable to marshal ed25519 key: %v", err)
+	}
+
+	retiredPKIX, err := x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatalf("unable to marshal public key: %v", err)
+	}
+
+	writePEM(t, dir, "2026-01-01", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	writePEM(t, dir, "2026-02-01", "PRIVATE KEY", edPKCS8)
+	writePEM(t, dir, "2025-12-01", "PUBLIC KEY", retiredPKIX)
+
+	tests := []struct {
+		name       string
+		activeKID  string
+		wantActive string
+		wantAlg    string
+		wantErr    error
+	}{
+		{name: "Newest_Is_Active", activeKID: "", wantActive: "2026-02-01", wantAlg: AlgEdDSA},
+		{name: "Explicit_Active", activeKID: "2026-01-01", wantActive: "2026-01-01", wantAlg: AlgRS256},
+		{name: "Public_Key_Cannot_Sign", activeKID: "2025-12-01", wantErr: ErrActiveKeyNotFound},
+		{name: "Unknown_Active", activeKID: "missing", wantErr: ErrActiveKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := LoadKeySet(dir, tt.activeKID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("LoadKeySet() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadKeySet() unexpected error = %v", err)
+			}
+
+			if ks.active.ID != tt.wantActive || ks.active.Algorithm != tt.wantAlg {
+				t.Errorf("active key = %s %s, want %s %s", ks.active.ID, ks.active.Algorithm, tt.wantActive, tt.wantAlg)
+			}
+
+			if len(ks.keys) != 3 {
+				t.Errorf("key set has %d keys, want 3", len(ks.keys))
+			}
+		})
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate rsa key: %v", err)
+	}
+
+	rsaSigner, _ := NewKey("rsa", rsaKey)
+
+	edSigner, err := GenerateEd25519Key()
+	if err != nil {
+		t.Fatalf("unable to generate ed25519 key: %v", err)
+	}
+
+	for _, signer := range []*Key{rsaSigner, edSigner} {
+		t.Run(signer.Algorithm, func(t *testing.T) {
+			jwk, err := NewJWK(signer)
+			if err != nil {
+				t.Fatalf("NewJWK() unexpected error = %v", err)
+			}
+
+			b, err := json.Marshal(jwk)
+			if err != nil {
+				t.Fatalf("unable to marshal jwk: %v", err)
+			}
+
+			var decoded JWK
+			if err = json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("unable to unmarshal jwk: %v", err)
+			}
+
+			verifier, err := decoded.Key()
+			if err != nil {
+				t.Fatalf("Key() unexpected error = %v", err)
+			}
+
+			tokenStr, err := NewKeySet(signer).Sign(jwt.RegisteredClaims{Subject: "user"})
+			if err != nil {
+				t.Fatalf("Sign() unexpected error = %v", err)
+			}
+
+			_, err = jwt.Parse(tokenStr, func(*jwt.Token) (interface{}, error) { return verifier.Public, nil })
+			if err != nil {
+				t.Errorf("token signed by %s does not verify with its jwk: %v", signer.ID, err)
+			}
+		})
+	}
+}
+
+func TestJWKSCache(t *testing.T) {
+	first, _ := GenerateEd25519Key()
+	second, _ := GenerateEd25519Key()
+
+	ks := NewKeySet(first)
+	requests := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		set, err := ks.JWKS()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := NewJWKSCache(srv.URL, srv.Client())
+
+	if _, err := cache.ResolveKey(first.ID); err != nil {
+		t.Fatalf("ResolveKey() unexpected error = %v", err)
+	}
+
+	if _, err := cache.ResolveKey(first.ID); err != nil || requests != 1 {
+		t.Fatalf("cached ResolveKey() error = %v, requests = %d, want 1", err, requests)
+	}
+
+	// rotate keys, an unknown key id is only refetched after the minimum refetch delay
+	ks = NewKeySet(second, first)
+
+	if _, err := cache.ResolveKey(second.ID); !errors.Is(err, ErrUnknownKeyID) || requests != 1 {
+		t.Fatalf("ResolveKey() error = %v, requests = %d, want unknown key id without refetch", err, requests)
+	}
+
+	cache.fetchedAt = time.Now().Add(-jwksMinRefetchDelay)
+
+	if _, err := cache.ResolveKey(second.ID); err != nil || requests != 2 {
+		t.Fatalf("ResolveKey() error = %v, requests = %d, want rotated key after refetch", err, requests)
+	}
+}
+
+func TestJWKSCacheConcurrentRefresh(t *testing.T) {
+	first, _ := GenerateEd25519Key()
+	second, _ := GenerateEd25519Key()
+
+	var ks atomic.Pointer[KeySet]
+	ks.Store(NewKeySet(first))
+
+	var requests atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request is served at once, refetches wait until released
+		if requests.Add(1) > 1 {
+			started <- struct{}{}
+			<-release
+		}
+
+		set, _ := ks.Load().JWKS()
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := NewJWKSCache(srv.URL, srv.Client())
+
+	if _, err := cache.ResolveKey(first.ID); err != nil {
+		t.Fatalf("ResolveKey() unexpected error = %v", err)
+	}
+
+	// rotate keys, the cached key set expired, so the next lookup refetches it
+	ks.Store(NewKeySet(second, first))
+	cache.mu.Lock()
+	cache.fetchedAt = time.Now().Add(-jwksCacheTTL)
+	cache.mu.Unlock()
+
+	refreshed := make(chan error, 1)
+
+	go func() {
+		_, err := cache.ResolveKey(first.ID)
+		refreshed <- err
+	}()
+
+	<-started
+
+	// the old key set stays readable while the refetch is in flight
+	resolved := make(chan error, 1)
+
+	go func() {
+		_, err := cache.ResolveKey(first.ID)
+		resolved <- err
+	}()
+
+	select {
+	case err := <-resolved:
+		if err != nil {
+			t.Fatalf("ResolveKey() during refetch unexpected error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ResolveKey() of a cached key blocked on the refetch")
+	}
+
+	// callers of the rotated key wait for the refetch in flight instead of fetching again
+	var wg sync.WaitGroup
+
+	errs := make([]error, 4)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			_, errs[i] = cache.ResolveKey(second.ID)
+		}(i)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if err := <-refreshed; err != nil {
+		t.Fatalf("refreshing ResolveKey() unexpected error = %v", err)
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("ResolveKey() of rotated key %d unexpected error = %v", i, err)
+		}
+	}
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func writePEM(t *testing.T, dir string, kid string, blockType string, der []byte) {
+	t.Helper()
+
+	b := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+pemExt), b, 0o600); err != nil {
+		t.Fatalf("unable to write key file: %v", err)
+	}
+}
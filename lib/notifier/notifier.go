@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notifier delivers messages such as password reset or verification links to users.
+// Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// FromEnv returns a FileNotifier writing to the NOTIFIER_OUTBOX file if it is set, otherwise a LogNotifier.
+// Both are meant for local development until a mail provider is wired up.
+func FromEnv(l *slog.Logger) Notifier {
+	if path := os.Getenv("NOTIFIER_OUTBOX"); path != "" {
+		return NewFileNotifier(path)
+	}
+
+	return NewLogNotifier(l)
+}
+
+// LogNotifier writes every message to the logger.
+type LogNotifier struct {
+	l *slog.Logger
+}
+
+func NewLogNotifier(l *slog.Logger) *LogNotifier {
+	return &LogNotifier{l: l}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	n.l.InfoContext(ctx, "notification sent", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileNotifier appends every message as a json line to an outbox file.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Notify(_ context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("unable to marshal message: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open outbox: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("unable to write outbox: %w", err)
+	}
+
+	return nil
+}
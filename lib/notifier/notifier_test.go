@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	n := NewFileNotifier(path)
+
+	messages := []Message{
+		{To: "first@test.com", Subject: "first", Body: "first body"},
+		{To: "second@test.com", Subject: "second", Body: "second body"},
+	}
+
+	for _, msg := range messages {
+		if err := n.Notify(context.Background(), msg); err != nil {
+			t.Fatalf("Notify() unexpected error = %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open outbox: %v", err)
+	}
+	defer f.Close()
+
+	var got []Message
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		if err = json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("outbox line is not json: %v", err)
+		}
+
+		got = append(got, msg)
+	}
+
+	if len(got) != len(messages) {
+		t.Fatalf("outbox has %d messages, want %d", len(got), len(messages))
+	}
+
+	for i, msg := range got {
+		if msg.To != messages[i].To || msg.Subject != messages[i].Subject || msg.Body != messages[i].Body {
+			t.Errorf("message %d = %+v, want %+v", i, msg, messages[i])
+		}
+
+		if msg.SentAt.IsZero() {
+			t.Errorf("message %d has no sent at time", i)
+		}
+	}
+}
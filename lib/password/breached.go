@@ -0,0 +1,120 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // sha1 is the format of breached password corpora, not used for security here
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is the length of the SHA-1 hash prefix breached password ranges are split by.
+const prefixLength = 5
+
+// BreachedList reports whether a password has appeared in a data breach.
+// Implementations must be safe for concurrent use.
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// OpenBreachedList opens a local breached password corpus of uppercase hex SHA-1 hashes of passwords,
+// optionally followed by ":<count>" like in the Have I Been Pwned downloads.
+// A directory is read as k-anonymity ranges, one <PREFIX>.txt file per 5 character hash prefix holding
+// the remaining hash suffixes, only the range of a checked password is read.
+// A file is read as one full hash per line and kept in memory, which suits smaller corpora.
+func OpenBreachedList(path string) (BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open breached password list: %w", err)
+	}
+
+	if info.IsDir() {
+		return RangeDir(path), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	return LoadHashSet(f)
+}
+
+// RangeDir is a directory of breached password ranges, named by their SHA-1 hash prefix.
+type RangeDir string
+
+func (d RangeDir) Contains(password string) (bool, error) {
+	hash := hashPassword(password)
+
+	f, err := os.Open(filepath.Join(string(d), hash[:prefixLength]+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("unable to open breached password range: %w", err)
+	}
+	defer f.Close()
+
+	found := false
+
+	err = scanHashes(f, func(suffix string) bool {
+		found = suffix == hash[prefixLength:]
+		return !found
+	})
+
+	return found, err
+}
+
+// HashSet is a breached password corpus held in memory.
+type HashSet map[string]struct{}
+
+// LoadHashSet reads a breached password corpus of one full SHA-1 hash per line.
+func LoadHashSet(r io.Reader) (HashSet, error) {
+	set := HashSet{}
+
+	err := scanHashes(r, func(hash string) bool {
+		set[hash] = struct{}{}
+		return true
+	})
+
+	return set, err
+}
+
+func (s HashSet) Contains(password string) (bool, error) {
+	_, ok := s[hashPassword(password)]
+	return ok, nil
+}
+
+// scanHashes calls fn with the uppercase hash of every non empty line, without the ":<count>" suffix,
+// until fn returns false.
+func scanHashes(r io.Reader, fn func(hash string) bool) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+
+		if !fn(strings.ToUpper(hash)) {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read breached password list: %w", err)
+	}
+
+	return nil
+}
+
+func hashPassword(password string) string {
+	sum := sha1.Sum([]byte(password)) //nolint:gosec
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
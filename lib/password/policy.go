@@ -0,0 +1,259 @@
+package password
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules a password is checked against, they identify the violated rule in structured errors.
+const (
+	RuleLength      = "length"
+	RuleCharClasses = "char_classes"
+	RuleEntropy     = "entropy"
+	RuleIdentity    = "identity"
+	RuleHistory     = "history"
+	RuleBreached    = "breached"
+)
+
+// minIdentityLength is the shortest username or email local part a password is checked not to contain,
+// shorter ones would reject too many unrelated passwords.
+const minIdentityLength = 3
+
+// DefaultPolicy follows NIST SP 800-63B loosely, length and a breach check matter most,
+// composition rules are kept mild.
+var DefaultPolicy = Policy{
+	MinLength:      8,
+	MaxLength:      128,
+	MinCharClasses: 2,
+	MinEntropyBits: 45,
+	HistorySize:    5,
+}
+
+// Violation is a password policy rule a password breaks, with a message for the user.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Violations are every rule a password breaks, nil if it satisfies the policy.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	messages := make([]string, len(v))
+	for i := range v {
+		messages[i] = v[i].Message
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Policy describes what makes a password acceptable for registration, password change and reset.
+// Zero valued limits disable their rule. The history rule needs the stored hashes, so it's enforced
+// by the caller, HistorySize only configures how many of the last passwords can't be reused.
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	MinCharClasses int
+	MinEntropyBits float64
+	HistorySize    int
+	Breached       BreachedList
+
+	l *slog.Logger
+}
+
+// PolicyFromEnv returns DefaultPolicy with limits overridden by PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH,
+// PASSWORD_MIN_CHAR_CLASSES, PASSWORD_MIN_ENTROPY_BITS and PASSWORD_HISTORY_SIZE, and passwords checked against
+// the breached password list at PASSWORD_BREACHED_LIST if it's set. Invalid values are logged and ignored.
+func PolicyFromEnv(l *slog.Logger) Policy {
+	p := DefaultPolicy
+	p.l = l
+
+	intVars := map[string]*int{
+		"PASSWORD_MIN_LENGTH":       &p.MinLength,
+		"PASSWORD_MAX_LENGTH":       &p.MaxLength,
+		"PASSWORD_MIN_CHAR_CLASSES": &p.MinCharClasses,
+		"PASSWORD_HISTORY_SIZE":     &p.HistorySize,
+	}
+
+	for name, field := range intVars {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				l.Warn("invalid password policy setting, using default", "name", name, "value", v)
+				continue
+			}
+
+			*field = n
+		}
+	}
+
+	if v := os.Getenv("PASSWORD_MIN_ENTROPY_BITS"); v != "" {
+		bits, err := strconv.ParseFloat(v, 64)
+		if err != nil || bits < 0 {
+			l.Warn("invalid password policy setting, using default", "name", "PASSWORD_MIN_ENTROPY_BITS",
+				"value", v)
+		} else {
+			p.MinEntropyBits = bits
+		}
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		breached, err := OpenBreachedList(path)
+		if err != nil {
+			l.Error("unable to open breached password list, passwords are not checked against it",
+				"err", err.Error(), "path", path)
+		} else {
+			p.Breached = breached
+		}
+	}
+
+	return p
+}
+
+// Check returns every rule of the policy the password breaks, identities are the username and email
+// of its owner, which the password must not contain. Failures to read the breached password list are logged,
+// the password is accepted by that rule then, so an unavailable list doesn't block every password change.
+func (p Policy) Check(ctx context.Context, password string, identities ...string) Violations {
+	var violations Violations
+
+	n := utf8.RuneCountInString(password)
+	if n < p.MinLength || (p.MaxLength > 0 && n > p.MaxLength) {
+		violations = append(violations, Violation{Rule: RuleLength,
+			Message: fmt.Sprintf("password must be at least %d characters long and no more than %d characters",
+				p.MinLength, p.MaxLength)})
+	}
+
+	classes, poolSize := charClasses(password)
+	if classes < p.MinCharClasses {
+		violations = append(violations, Violation{Rule: RuleCharClasses,
+			Message: fmt.Sprintf("password must contain at least %d of: lowercase letters, uppercase letters, "+
+				"digits, symbols", p.MinCharClasses)})
+	}
+
+	if Entropy(n, poolSize) < p.MinEntropyBits {
+		violations = append(violations, Violation{Rule: RuleEntropy,
+			Message: "password is too predictable, use a longer password or more kinds of characters"})
+	}
+
+	if containsIdentity(password, identities) {
+		violations = append(violations, Violation{Rule: RuleIdentity,
+			Message: "password must not contain your username or email"})
+	}
+
+	if p.isBreached(ctx, password) {
+		violations = append(violations, Violation{Rule: RuleBreached,
+			Message: "password has appeared in a data breach, choose a different one"})
+	}
+
+	return violations
+}
+
+// HistoryViolation is the violation of a password matching one of the last HistorySize passwords of its owner.
+func (p Policy) HistoryViolation() Violation {
+	return Violation{Rule: RuleHistory,
+		Message: fmt.Sprintf("password must not match any of your last %d passwords", p.HistorySize)}
+}
+
+func (p Policy) isBreached(ctx context.Context, password string) bool {
+	if p.Breached == nil {
+		return false
+	}
+
+	breached, err := p.Breached.Contains(password)
+	if err != nil && p.l != nil {
+		p.l.WarnContext(ctx, "unable to check breached password list", "err", err.Error())
+	}
+
+	return breached
+}
+
+// Entropy estimates the bits of entropy of a password of n characters drawn from a pool of poolSize characters,
+// it's an upper bound, as real passwords are rarely random.
+func Entropy(n int, poolSize int) float64 {
+	if n == 0 || poolSize == 0 {
+		return 0
+	}
+
+	return float64(n) * math.Log2(float64(poolSize))
+}
+
+// charClasses counts the kinds of characters a password uses, lowercase and uppercase letters, digits
+// and symbols, and the size of the character pool they make up. Non ASCII characters count as symbols
+// and widen the pool more, since guessers rarely try them.
+func charClasses(password string) (classes int, poolSize int) {
+	var lower, upper, digit, symbol, other bool
+
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	pools := []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}}
+
+	for _, pool := range pools {
+		if pool.used {
+			poolSize += pool.size
+		}
+	}
+
+	if lower {
+		classes++
+	}
+
+	if upper {
+		classes++
+	}
+
+	if digit {
+		classes++
+	}
+
+	if symbol || other {
+		classes++
+	}
+
+	return classes, poolSize
+}
+
+// containsIdentity reports whether the password contains any of the identities, case insensitively.
+// For emails, the local part is checked as well.
+func containsIdentity(password string, identities []string) bool {
+	password = strings.ToLower(password)
+
+	for _, identity := range identities {
+		identity = strings.ToLower(identity)
+
+		candidates := []string{identity}
+		if local, _, ok := strings.Cut(identity, "@"); ok {
+			candidates = append(candidates, local)
+		}
+
+		for _, c := range candidates {
+			if len(c) >= minIdentityLength && strings.Contains(password, c) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
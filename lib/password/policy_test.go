@@ -0,0 +1,89 @@
+package password
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	breached, err := LoadHashSet(strings.NewReader(hashPassword("Summer2023!") + ":120\n"))
+	if err != nil {
+		t.Fatalf("LoadHashSet() unexpected error = %v", err)
+	}
+
+	p := DefaultPolicy
+	p.Breached = breached
+
+	tests := []struct {
+		name       string
+		password   string
+		identities []string
+		wantRules  []string
+	}{
+		{name: "Valid", password: "correct horse battery 9", identities: []string{"johndoe1", "john@example.com"}},
+		{name: "Too_Short", password: "aB3$", wantRules: []string{RuleLength, RuleEntropy}},
+		{name: "Too_Long", password: strings.Repeat("aB3$", 33), wantRules: []string{RuleLength}},
+		{name: "One_Char_Class", password: "abcdefghijkl", wantRules: []string{RuleCharClasses}},
+		{name: "Low_Entropy", password: "abc12345", wantRules: []string{RuleEntropy}},
+		{name: "Contains_Username", password: "xJohnDoe1x!", identities: []string{"johndoe1"},
+			wantRules: []string{RuleIdentity}},
+		{name: "Contains_Email_Local_Part", password: "Mary.Jane-2024", identities: []string{"mary.jane@example.com"},
+			wantRules: []string{RuleIdentity}},
+		{name: "Breached", password: "Summer2023!", wantRules: []string{RuleBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRules []string
+			for _, v := range p.Check(context.Background(), tt.password, tt.identities...) {
+				gotRules = append(gotRules, v.Rule)
+			}
+
+			if !reflect.DeepEqual(gotRules, tt.wantRules) {
+				t.Errorf("Check() rules = %v, want %v", gotRules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	hash := hashPassword("password1")
+
+	content := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + hash[prefixLength:] + ":2427178\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:prefixLength]+".txt"), []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write range file: %v", err)
+	}
+
+	list, err := OpenBreachedList(dir)
+	if err != nil {
+		t.Fatalf("OpenBreachedList() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{name: "Breached", password: "password1", want: true},
+		{name: "Same_Range_Not_Breached", password: "password2", want: false},
+		{name: "Missing_Range", password: "correct horse battery 9", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := list.Contains(tt.password)
+			if err != nil {
+				t.Fatalf("Contains() unexpected error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const (
+	DefaultSyncInterval = 30 * time.Second
+
+	// RoleUnverified is the pseudo-role users are authorized as until they verify their email.
+	RoleUnverified   = "unverified"
+	StatusUnverified = "unverified"
+
+	wildcardSegment = "*"
+	wildcardRest    = "**"
+)
+
+var (
+	ErrInvalidPattern = errors.New("route pattern must look like METHOD:/path, e.g. GET:/users/*")
+	validMethods      = map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "*": true}
+)
+
+// RolePermissions authorizes roles for routes named "METHOD:/full/path" by the grants stored in postgres.
+// Grants are served from an in-memory snapshot, replaced atomically by Load, so checks never hit the database.
+// Granted route patterns may use wildcards, "*" as method matches any method, "*" as path segment
+// matches exactly one segment and a trailing "**" matches any remaining segments,
+// e.g. "GET:/users/*" matches "GET:/users/:user_id" but not "GET:/users/:user_id/profile".
+// Routes with a user id path parameter are granted for the user itself, unless granted for any user.
+type RolePermissions struct {
+	db       *sql.DB
+	l        *slog.Logger
+	snapshot atomic.Pointer[snapshot]
+}
+
+// snapshot holds the grants of every role, those granted for any user apart.
+type snapshot struct {
+	roles   map[string]*grants
+	anyUser map[string]*grants
+}
+
+// grants holds the grants of a role, exact routes in a set and wildcard patterns in a list.
+type grants struct {
+	exact    map[string]bool
+	patterns []pattern
+}
+
+type pattern struct {
+	method   string
+	segments []string
+}
+
+// NewRolePermissions returns RolePermissions backed by the database, it denies everything until loaded.
+func NewRolePermissions(db *sql.DB, l *slog.Logger) *RolePermissions {
+	p := &RolePermissions{db: db, l: l}
+	p.snapshot.Store(&snapshot{})
+
+	return p
+}
+
+// NewStaticRolePermissions returns RolePermissions with a fixed set of grants of routes per role,
+// anyUserRoutes are granted to their roles for any user.
+func NewStaticRolePermissions(roleRoutes map[string][]string,
+	anyUserRoutes map[string][]string) (*RolePermissions, error) {
+	s, err := newSnapshot(roleRoutes, anyUserRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RolePermissions{}
+	p.snapshot.Store(s)
+
+	return p, nil
+}
+
+// EffectiveRole returns the role a user with role and status is authorized as,
+// unverified users get the grants of RoleUnverified instead of those of their role.
+func EffectiveRole(role string, status string) string {
+	if status == StatusUnverified {
+		return RoleUnverified
+	}
+
+	return role
+}
+
+// IsAuthorizedFor reports whether role may access routeName.
+func (p *RolePermissions) IsAuthorizedFor(role string, routeName string) bool {
+	s := p.snapshot.Load()
+
+	return s.roles[role].authorize(routeName) || s.anyUser[role].authorize(routeName)
+}
+
+// IsAuthorizedForAnyUser reports whether role may access routeName on behalf of any user,
+// not only on the user itself.
+func (p *RolePermissions) IsAuthorizedForAnyUser(role string, routeName string) bool {
+	return p.snapshot.Load().anyUser[role].authorize(routeName)
+}
+
+// authorize reports whether the grants match routeName, no grants match nothing.
+func (g *grants) authorize(routeName string) bool {
+	if g == nil {
+		return false
+	}
+
+	routeName = strings.TrimSpace(routeName)
+	if g.exact[routeName] {
+		return true
+	}
+
+	method, path, ok := strings.Cut(routeName, ":")
+	if !ok {
+		return false
+	}
+
+	segments := splitPath(path)
+
+	for _, pt := range g.patterns {
+		if pt.matches(method, segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopesAuthorize reports whether any of the scopes authorizes routeName, scopes of service client tokens are
+// route patterns like the ones granted to roles. Invalid scopes never match.
+func ScopesAuthorize(scopes []string, routeName string) bool {
+	routeName = strings.TrimSpace(routeName)
+
+	method, path, ok := strings.Cut(routeName, ":")
+	if !ok {
+		return false
+	}
+
+	segments := splitPath(path)
+
+	for _, scope := range scopes {
+		pt, err := parsePattern(scope)
+		if err != nil {
+			continue
+		}
+
+		if scope == routeName || pt.matches(method, segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load replaces the snapshot with the grants currently stored in the database.
+func (p *RolePermissions) Load(ctx context.Context) lib.APIError {
+	sqlFindGrants := `SELECT rp.role, p.route, rp.any_user FROM role_permissions rp
+					  JOIN permissions p ON p.id = rp.permission_id`
+
+	rows, err := p.db.QueryContext(ctx, sqlFindGrants)
+	if err != nil {
+		p.l.ErrorContext(ctx, "unable to query role permissions", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	roleRoutes := make(map[string][]string)
+	anyUserRoutes := make(map[string][]string)
+
+	for rows.Next() {
+		var role, route string
+
+		var anyUser bool
+		if err = rows.Scan(&role, &route, &anyUser); err != nil {
+			p.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+			return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if anyUser {
+			anyUserRoutes[role] = append(anyUserRoutes[role], route)
+		} else {
+			roleRoutes[role] = append(roleRoutes[role], route)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		p.l.ErrorContext(ctx, lib.ErrScanRows, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	s, err := newSnapshot(roleRoutes, anyUserRoutes)
+	if err != nil {
+		p.l.ErrorContext(ctx, "invalid route pattern in role permissions", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	p.snapshot.Store(s)
+
+	return nil
+}
+
+// StartSync reloads the snapshot every interval until ctx is done,
+// so grants changed through other instances are picked up.
+func (p *RolePermissions) StartSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = p.Load(ctx)
+			}
+		}
+	}()
+}
+
+// ValidatePattern checks a route pattern has a known method and an absolute path,
+// where "**" may only be the last segment.
+func ValidatePattern(route string) error {
+	_, err := parsePattern(route)
+	return err
+}
+
+func newSnapshot(roleRoutes map[string][]string, anyUserRoutes map[string][]string) (*snapshot, error) {
+	roles, err := newGrants(roleRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	anyUser, err := newGrants(anyUserRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot{roles: roles, anyUser: anyUser}, nil
+}
+
+func newGrants(roleRoutes map[string][]string) (map[string]*grants, error) {
+	s := make(map[string]*grants, len(roleRoutes))
+
+	for role, routes := range roleRoutes {
+		g := &grants{exact: make(map[string]bool)}
+
+		for _, route := range routes {
+			pt, err := parsePattern(route)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", route, err)
+			}
+
+			if pt.isWildcard() {
+				g.patterns = append(g.patterns, pt)
+			} else {
+				g.exact[strings.TrimSpace(route)] = true
+			}
+		}
+
+		s[role] = g
+	}
+
+	return s, nil
+}
+
+func parsePattern(route string) (pattern, error) {
+	method, path, ok := strings.Cut(strings.TrimSpace(route), ":")
+	if !ok || !validMethods[method] || !strings.HasPrefix(path, "/") {
+		return pattern{}, ErrInvalidPattern
+	}
+
+	segments := splitPath(path)
+
+	for i, seg := range segments {
+		if seg == "" || (seg == wildcardRest && i != len(segments)-1) {
+			return pattern{}, ErrInvalidPattern
+		}
+	}
+
+	return pattern{method: method, segments: segments}, nil
+}
+
+func (pt pattern) isWildcard() bool {
+	if pt.method == wildcardSegment {
+		return true
+	}
+
+	for _, seg := range pt.segments {
+		if seg == wildcardSegment || seg == wildcardRest {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pt pattern) matches(method string, segments []string) bool {
+	if pt.method != wildcardSegment && pt.method != method {
+		return false
+	}
+
+	for i, seg := range pt.segments {
+		if seg == wildcardRest {
+			return true
+		}
+
+		if i >= len(segments) || (seg != wildcardSegment && seg != segments[i]) {
+			return false
+		}
+	}
+
+	return len(pt.segments) == len(segments)
+}
+
+// splitPath splits "/users/:user_id" into ["users", ":user_id"], the root path has no segments.
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsAuthorizedFor(t *testing.T) {
+	p, err := NewStaticRolePermissions(map[string][]string{
+		"moderator": {"GET:/users", "GET:/users/*"},
+		"user":      {"POST:/users/:user_id"},
+	}, map[string][]string{"admin": {"*:/users/**"}})
+	if err != nil {
+		t.Fatalf("NewStaticRolePermissions() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		routeName string
+		want      bool
+	}{
+		{name: "Exact", role: "user", routeName: "POST:/users/:user_id", want: true},
+		{name: "Exact_Trimmed", role: "user", routeName: " POST:/users/:user_id ", want: true},
+		{name: "Exact_Other_Method", role: "user", routeName: "GET:/users/:user_id", want: false},
+		{name: "Unknown_Role", role: "merchant", routeName: "POST:/users/:user_id", want: false},
+		{name: "Segment_Wildcard", role: "moderator", routeName: "GET:/users/:user_id", want: true},
+		{name: "Segment_Wildcard_Too_Deep", role: "moderator", routeName: "GET:/users/:user_id/profile", want: false},
+		{name: "Segment_Wildcard_Too_Short", role: "moderator", routeName: "GET:/", want: false},
+		{name: "Rest_Wildcard_Any_Method", role: "admin", routeName: "DELETE:/users/:user_id/profile", want: true},
+		{name: "Rest_Wildcard_No_Remaining", role: "admin", routeName: "POST:/users", want: true},
+		{name: "Rest_Wildcard_Other_Prefix", role: "admin", routeName: "GET:/auctions", want: false},
+		{name: "Malformed_Route", role: "user", routeName: "users", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.IsAuthorizedFor(tt.role, tt.routeName); got != tt.want {
+				t.Errorf("IsAuthorizedFor(%q, %q) = %v, want %v", tt.role, tt.routeName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAuthorizedForAnyUser(t *testing.T) {
+	p, err := NewStaticRolePermissions(map[string][]string{
+		"admin": {"PUT:/users/:user_id"},
+	}, map[string][]string{"admin": {"GET:/users/*"}})
+	if err != nil {
+		t.Fatalf("NewStaticRolePermissions() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		routeName string
+		want      bool
+	}{
+		{name: "Any_User", role: "admin", routeName: "GET:/users/:user_id", want: true},
+		{name: "Own_User_Only", role: "admin", routeName: "PUT:/users/:user_id", want: false},
+		{name: "Not_Granted", role: "admin", routeName: "DELETE:/users/:user_id", want: false},
+		{name: "Unknown_Role", role: "user", routeName: "GET:/users/:user_id", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.IsAuthorizedForAnyUser(tt.role, tt.routeName); got != tt.want {
+				t.Errorf("IsAuthorizedForAnyUser(%q, %q) = %v, want %v", tt.role, tt.routeName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		route   string
+		wantErr error
+	}{
+		{route: "GET:/users/*", wantErr: nil},
+		{route: "*:/users/**", wantErr: nil},
+		{route: "POST:/users/:user_id", wantErr: nil},
+		{route: "FETCH:/users", wantErr: ErrInvalidPattern},
+		{route: "GET:users", wantErr: ErrInvalidPattern},
+		{route: "GET/users", wantErr: ErrInvalidPattern},
+		{route: "GET:/users//profile", wantErr: ErrInvalidPattern},
+		{route: "GET:/**/profile", wantErr: ErrInvalidPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			if err := ValidatePattern(tt.route); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidatePattern(%q) error = %v, wantErr %v", tt.route, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScopesAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		routeName string
+		want      bool
+	}{
+		{name: "Exact", scopes: []string{"POST:/users"}, routeName: "POST:/users", want: true},
+		{name: "Wildcard", scopes: []string{"POST:/users", "GET:/users/*"}, routeName: "GET:/users/:user_id",
+			want: true},
+		{name: "Rest_Wildcard", scopes: []string{"*:/users/**"}, routeName: "PUT:/users/:user_id/profile", want: true},
+		{name: "Not_Granted", scopes: []string{"GET:/users/*"}, routeName: "DELETE:/users/:user_id", want: false},
+		{name: "Invalid_Scope_Ignored", scopes: []string{"users:read"}, routeName: "GET:/users", want: false},
+		{name: "No_Scopes", scopes: nil, routeName: "GET:/users", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopesAuthorize(tt.scopes, tt.routeName); got != tt.want {
+				t.Errorf("ScopesAuthorize(%v, %q) = %v, want %v", tt.scopes, tt.routeName, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is an in-memory fixed window rate limiter keyed by arbitrary strings,
+// e.g. an email address or a client ip. It is safe for concurrent use.
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*window
+	sweepAt time.Time
+}
+
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+// New returns a Limiter allowing limit events per key within every window.
+func New(limit int, per time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  per,
+		now:     time.Now,
+		windows: make(map[string]*window),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (rl *Limiter) Allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	w, ok := rl.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(rl.window)}
+		rl.windows[key] = w
+	}
+
+	if w.count >= rl.limit {
+		return false
+	}
+
+	w.count++
+
+	return true
+}
+
+// sweep drops expired windows at most once per window, so memory stays bounded by the active keys.
+func (rl *Limiter) sweep(now time.Time) {
+	if now.Before(rl.sweepAt) {
+		return
+	}
+
+	for key, w := range rl.windows {
+		if !now.Before(w.resetAt) {
+			delete(rl.windows, key)
+		}
+	}
+
+	rl.sweepAt = now.Add(rl.window)
+}
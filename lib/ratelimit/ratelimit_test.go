@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Now()
+	rl := New(2, time.Minute)
+	rl.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		key     string
+		advance time.Duration
+		want    bool
+	}{
+		{name: "First", key: "a", want: true},
+		{name: "Second", key: "a", want: true},
+		{name: "Over_Limit", key: "a", want: false},
+		{name: "Other_Key", key: "b", want: true},
+		{name: "Still_Limited", key: "a", advance: 30 * time.Second, want: false},
+		{name: "Window_Reset", key: "a", advance: 30 * time.Second, want: true},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+
+		if got := rl.Allow(step.key); got != step.want {
+			t.Errorf("%s: Allow(%q) = %v, want %v", step.name, step.key, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package revocation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const DefaultSyncInterval = 30 * time.Second
+
+// Store is the access token revocation list, persisted in postgres and served from memory.
+// Individual tokens (jti) and sessions (sid) are revoked by id until they expire,
+// all tokens of a user are revoked by recording the time before which they were issued.
+// Every instance keeps its cache in sync by reloading it periodically, see StartSync.
+type Store struct {
+	db *sql.DB
+	l  *slog.Logger
+
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]time.Time
+}
+
+func NewStore(db *sql.DB, l *slog.Logger) *Store {
+	return &Store{
+		db:     db,
+		l:      l,
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]time.Time),
+	}
+}
+
+// IsRevoked reports whether a token with the given id, session and owner was revoked,
+// it only consults the in-memory cache so it is safe to call on every request.
+// Tokens of a user issued at or before the cutoff are revoked, iat has millisecond precision,
+// so a token issued later within the millisecond of the cutoff is revoked as well, it fails closed.
+func (s *Store) IsRevoked(tokenID, sessionID, userID string, issuedAt time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[tokenID]; ok && tokenID != "" {
+		return true
+	}
+
+	if _, ok := s.tokens[sessionID]; ok && sessionID != "" {
+		return true
+	}
+
+	if before, ok := s.users[userID]; ok && !issuedAt.After(before) {
+		return true
+	}
+
+	return false
+}
+
+// RevokeToken revokes a single token or session id until expiresAt, afterward it expires on its own.
+func (s *Store) RevokeToken(ctx context.Context, tokenID, userID string, expiresAt time.Time) lib.APIError {
+	sqlRevokeToken := `INSERT INTO revoked_tokens (token_id, user_id, expires_at) VALUES ($1, $2, $3)
+					   ON CONFLICT (token_id) DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, sqlRevokeToken, tokenID, userID, expiresAt); err != nil {
+		s.l.ErrorContext(ctx, "unable to revoke token", "err", err.Error(), "userId", userID)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	s.mu.Lock()
+	s.tokens[tokenID] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeUser revokes every token of a user issued at or before the given time.
+func (s *Store) RevokeUser(ctx context.Context, userID string, before time.Time) lib.APIError {
+	sqlRevokeUser := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, $2)
+					  ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`
+
+	if _, err := s.db.ExecContext(ctx, sqlRevokeUser, userID, before); err != nil {
+		s.l.ErrorContext(ctx, "unable to revoke user tokens", "err", err.Error(), "userId", userID)
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	s.mu.Lock()
+	s.users[userID] = before
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Load replaces the in-memory cache with the unexpired revocations from the database.
+func (s *Store) Load(ctx context.Context) lib.APIError {
+	tokens, err := s.loadTokens(ctx)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to load revoked tokens", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	users, err := s.loadUsers(ctx)
+	if err != nil {
+		s.l.ErrorContext(ctx, "unable to load user token revocations", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	s.mu.Lock()
+	s.tokens = tokens
+	s.users = users
+	s.mu.Unlock()
+
+	return nil
+}
+
+// StartSync reloads the cache every interval until ctx is done,
+// so revocations written by other instances are picked up.
+func (s *Store) StartSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = s.Load(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Store) loadTokens(ctx context.Context) (map[string]time.Time, error) {
+	sqlFindTokens := `SELECT token_id, expires_at FROM revoked_tokens WHERE expires_at > now()`
+
+	rows, err := s.db.QueryContext(ctx, sqlFindTokens)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query revoked tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make(map[string]time.Time)
+
+	for rows.Next() {
+		var tokenID string
+		var expiresAt time.Time
+
+		if err = rows.Scan(&tokenID, &expiresAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", lib.ErrScanRows, err)
+		}
+
+		tokens[tokenID] = expiresAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", lib.ErrScanRows, err)
+	}
+
+	return tokens, nil
+}
+
+func (s *Store) loadUsers(ctx context.Context) (map[string]time.Time, error) {
+	sqlFindUsers := `SELECT user_id, revoked_before FROM user_token_revocations`
+
+	rows, err := s.db.QueryContext(ctx, sqlFindUsers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query user token revocations: %w", err)
+	}
+	defer rows.Close()
+
+	users := make(map[string]time.Time)
+
+	for rows.Next() {
+		var userID string
+		var before time.Time
+
+		if err = rows.Scan(&userID, &before); err != nil {
+			return nil, fmt.Errorf("%s: %w", lib.ErrScanRows, err)
+		}
+
+		users[userID] = before
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", lib.ErrScanRows, err)
+	}
+
+	return users, nil
+}
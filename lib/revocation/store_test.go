@@ -0,0 +1,48 @@
+package revocation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsRevoked(t *testing.T) {
+	now := time.Now()
+
+	s := NewStore(nil, nil)
+	s.tokens["revoked-jti"] = now.Add(time.Hour)
+	s.tokens["revoked-sid"] = now.Add(time.Hour)
+	s.users["revoked-user"] = now
+
+	tests := []struct {
+		name      string
+		tokenID   string
+		sessionID string
+		userID    string
+		issuedAt  time.Time
+		want      bool
+	}{
+		{name: "Not_Revoked", tokenID: "jti", sessionID: "sid", userID: "user", issuedAt: now, want: false},
+		{name: "Revoked_Token", tokenID: "revoked-jti", sessionID: "sid", userID: "user", issuedAt: now, want: true},
+		{name: "Revoked_Session", tokenID: "jti", sessionID: "revoked-sid", userID: "user", issuedAt: now, want: true},
+		{name: "Issued_Before_User_Revocation", tokenID: "jti", sessionID: "sid", userID: "revoked-user",
+			issuedAt: now.Add(-time.Minute), want: true},
+		{name: "Issued_At_User_Revocation", tokenID: "jti", sessionID: "sid", userID: "revoked-user",
+			issuedAt: now, want: true},
+		{name: "Issued_After_User_Revocation", tokenID: "jti", sessionID: "sid", userID: "revoked-user",
+			issuedAt: now.Add(time.Minute), want: false},
+		// iat has millisecond precision, a token issued within the millisecond of the revocation is revoked
+		{name: "Issued_Same_Millisecond_As_User_Revocation", tokenID: "jti", sessionID: "sid",
+			userID: "revoked-user", issuedAt: now.Truncate(time.Millisecond), want: true},
+		{name: "Issued_Next_Millisecond_After_User_Revocation", tokenID: "jti", sessionID: "sid",
+			userID: "revoked-user", issuedAt: now.Truncate(time.Millisecond).Add(time.Millisecond), want: false},
+		{name: "Empty_Ids", tokenID: "", sessionID: "", userID: "user", issuedAt: now, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsRevoked(tt.tokenID, tt.sessionID, tt.userID, tt.issuedAt); got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
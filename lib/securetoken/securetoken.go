@@ -0,0 +1,27 @@
+package securetoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Generate returns an url safe random token built from size bytes of crypto/rand output.
+// Tokens generated here are opaque to clients, only their Hash should ever be persisted.
+func Generate(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to read random bytes: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the hex encoded sha256 digest of a token.
+// Random tokens carry enough entropy that a fast hash is sufficient for lookups by digest.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package securetoken
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	first, err := Generate(32)
+	if err != nil {
+		t.Fatalf("Generate() unexpected error = %v", err)
+	}
+
+	second, err := Generate(32)
+	if err != nil {
+		t.Fatalf("Generate() unexpected error = %v", err)
+	}
+
+	if first == second {
+		t.Errorf("Generate() returned the same token twice: %s", first)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(first)
+	if err != nil {
+		t.Fatalf("Generate() token is not raw url base64: %v", err)
+	}
+
+	if len(decoded) != 32 {
+		t.Errorf("Generate() decoded length = %d, want 32", len(decoded))
+	}
+}
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "Empty", token: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "Token", token: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hash(tt.token); got != tt.want {
+				t.Errorf("Hash() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
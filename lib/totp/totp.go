@@ -0,0 +1,120 @@
+// Package totp implements RFC 6238 time-based one-time passwords as used by authenticator apps,
+// HMAC-SHA1 with 6 digit codes and a 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 default, authenticator apps expect it
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits     = 6
+	Period     = 30 * time.Second
+	SecretSize = 20
+
+	// Skew is the number of periods before and after the current one a code is accepted in,
+	// it tolerates clock drift and codes entered right before they rolled over.
+	Skew = 1
+)
+
+var (
+	ErrInvalidSecret = errors.New("totp secret must be base32 encoded")
+	b32              = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret returns a new random secret, base32 encoded without padding as authenticator apps expect.
+func GenerateSecret() (string, error) {
+	b := make([]byte, SecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate totp secret: %w", err)
+	}
+
+	return b32.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// key uri of the secret, authenticator apps enrol it as a QR code.
+func URI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step returns the time step (counter) t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code of the secret for time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return generate(sha1.New, key, step, Digits), nil
+}
+
+// Validate checks code against the codes of the secret within Skew steps of t,
+// it returns the matched time step so callers can reject codes of steps already used.
+func Validate(secret string, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(t)
+
+	for step := current - Skew; step <= current+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(generate(sha1.New, key, step, Digits)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
+
+// generate computes the HOTP value (RFC 4226) of key for counter, truncated to digits.
+func generate(h func() hash.Hash, key []byte, counter int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(h, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
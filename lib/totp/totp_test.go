@@ -0,0 +1,108 @@
+package totp
+
+import (
+	"crypto/sha1" //nolint:gosec // RFC 6238 test vectors
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGenerateRFC6238 checks the test vectors of RFC 6238 appendix B.
+func TestGenerateRFC6238(t *testing.T) {
+	seed := "12345678901234567890"
+
+	keys := map[string]struct {
+		h   func() hash.Hash
+		key []byte
+	}{
+		"SHA1":   {h: sha1.New, key: []byte(seed)},
+		"SHA256": {h: sha256.New, key: []byte(strings.Repeat(seed, 2)[:32])},
+		"SHA512": {h: sha512.New, key: []byte(strings.Repeat(seed, 4)[:64])},
+	}
+
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{unix: 59, alg: "SHA1", want: "94287082"},
+		{unix: 59, alg: "SHA256", want: "46119246"},
+		{unix: 59, alg: "SHA512", want: "90693936"},
+		{unix: 1111111109, alg: "SHA1", want: "07081804"},
+		{unix: 1111111109, alg: "SHA256", want: "68084774"},
+		{unix: 1111111109, alg: "SHA512", want: "25091201"},
+		{unix: 1234567890, alg: "SHA1", want: "89005924"},
+		{unix: 2000000000, alg: "SHA1", want: "69279037"},
+		{unix: 20000000000, alg: "SHA1", want: "65353130"},
+		{unix: 20000000000, alg: "SHA512", want: "47863826"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg+"_"+tt.want, func(t *testing.T) {
+			k := keys[tt.alg]
+			if got := generate(k.h, k.key, Step(time.Unix(tt.unix, 0)), 8); got != tt.want {
+				t.Errorf("generate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() unexpected error = %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+
+	codeAt := func(t *testing.T, at time.Time) string {
+		t.Helper()
+
+		code, err := Code(secret, Step(at))
+		if err != nil {
+			t.Fatalf("Code() unexpected error = %v", err)
+		}
+
+		return code
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		wantOk bool
+	}{
+		{name: "Current", secret: secret, code: codeAt(t, now), wantOk: true},
+		{name: "Previous_Step", secret: secret, code: codeAt(t, now.Add(-Period)), wantOk: true},
+		{name: "Next_Step", secret: secret, code: codeAt(t, now.Add(Period)), wantOk: true},
+		{name: "Outside_Skew", secret: secret, code: codeAt(t, now.Add(-2*Period)), wantOk: false},
+		{name: "Wrong_Length", secret: secret, code: "12345", wantOk: false},
+		{name: "Invalid_Secret", secret: "not base32!", code: "123456", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.wantOk {
+				t.Fatalf("Validate() ok = %v, want %v", ok, tt.wantOk)
+			}
+
+			if ok && (step < Step(now)-Skew || step > Step(now)+Skew) {
+				t.Errorf("Validate() step = %d, outside of skew around %d", step, Step(now))
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Instabid Wallet", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Instabid%20Wallet:alice@example.com?algorithm=SHA1&digits=6" +
+		"&issuer=Instabid+Wallet&period=30&secret=JBSWY3DPEHPK3PXP"
+
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
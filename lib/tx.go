@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgUniqueViolation is the postgres error code of a unique constraint violation.
+const pgUniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err is a postgres unique constraint violation,
+// e.g. of a username or email taken by a concurrent request after it was checked.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// RollbackOnError rolls back the transaction if *err is set when it's deferred, logging a warning
+// if the rollback itself fails. Transactions exited early without an error must set err to be rolled back.
+func RollbackOnError(tx *sql.Tx, err *error, l *slog.Logger) {
+	if *err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			l.Warn(ErrTXRollback, "rbErr", rbErr)
+		}
+	}
+}
+
+// Rollback rolls back the transaction unless it was committed, for transactions that are only committed
+// on success or never, e.g. read-only ones. It logs a warning if the rollback itself fails.
+func Rollback(tx *sql.Tx, l *slog.Logger) {
+	if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+		l.Warn(ErrTXRollback, "rbErr", rbErr)
+	}
+}
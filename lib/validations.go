@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"unicode/utf8"
 )
 
 const (
@@ -20,10 +21,11 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
-// ValidatePassword checks password must be at least 8 characters long and no more than 32 characters
+// ValidatePassword checks password must be at least 8 characters long and no more than 128 characters.
+// Passwords are hashed with argon2id, which unlike bcrypt doesn't truncate them, so long passphrases are allowed.
 func ValidatePassword(password string) error {
-	if len(password) < 8 || len(password) > 32 {
-		return errors.New("password must be at least 8 characters long and no more than 32 characters")
+	if n := utf8.RuneCountInString(password); n < 8 || n > 128 {
+		return errors.New("password must be at least 8 characters long and no more than 128 characters")
 	}
 
 	return nil
@@ -0,0 +1,333 @@
+package verifier
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultCacheTTL = 30 * time.Second
+
+	maxCacheEntries = 10000
+	tokenTypeAccess = "access_token"
+	mapKeyTokenType = "TokenType"
+	mapKeyRole      = "Role"
+	mapKeyStatus    = "Status"
+	mapKeyUserID    = "UserID"
+	mapKeyClientID  = "client_id"
+	mapKeyScope     = "scope"
+	mapKeyAPIKeyID  = "api_key_id"
+)
+
+var (
+	ErrForbidden      = errors.New("forbidden")
+	ErrNotAccessToken = errors.New("token is not an access token")
+	ErrTypeAssertion  = errors.New("role or user id not found in token")
+)
+
+// stats are published at /debug/vars by services exposing expvar.
+var stats = expvar.NewMap("jwt_verifier")
+
+type Config struct {
+	// CacheTTL is how long validated claims are reused for the same token, zero disables the cache.
+	// Cached claims are checked against the revocation lists on every use, revocations take effect immediately.
+	CacheTTL time.Duration
+
+	// RemoteFallback verifies tokens with the auth-api introspection endpoint when their signing key can't be
+	// resolved locally, e.g. while the JWKS endpoint is unreachable. Invalid tokens are never retried remotely.
+	RemoteFallback bool
+}
+
+// Verifier authenticates access tokens and authorizes them for routes without a network hop per request.
+// Signatures, expiry and revocation are validated by jwtutils.ParseAndValidateToken with keys from the
+// cached auth-api JWKS, role permissions are evaluated with the shared policy.
+type Verifier struct {
+	permissions *policy.RolePermissions
+	cfg         Config
+
+	mu    sync.Mutex
+	cache map[string]cachedClaims
+}
+
+type cachedClaims struct {
+	claims    jwt.MapClaims
+	expiresAt time.Time
+}
+
+func New(permissions *policy.RolePermissions, cfg Config) *Verifier {
+	return &Verifier{
+		permissions: permissions,
+		cfg:         cfg,
+		cache:       make(map[string]cachedClaims),
+	}
+}
+
+// Verify validates the access token and authorizes its role for routeName ("METHOD:/full/path"),
+// if the route has a user id path parameter it must match the token owner, unless the route is granted for any user.
+// It returns the token claims, ErrForbidden if the token is valid but not authorized, or another error.
+func (v *Verifier) Verify(tokenStr string, routeName string, pathUserID string) (jwt.MapClaims, error) {
+	claims, err := v.authenticate(tokenStr)
+	if err != nil {
+		stats.Add("rejected", 1)
+		return nil, err
+	}
+
+	if err = v.authorize(claims, routeName, pathUserID); err != nil {
+		stats.Add("forbidden", 1)
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// VerifyAPIKey authenticates an API key used from clientIP with the auth-api introspection endpoint
+// and authorizes it for routeName like Verify, the key scopes must allow the route as well.
+// Results are cached per key and client ip like validated tokens.
+func (v *Verifier) VerifyAPIKey(key string, clientIP string, routeName string,
+	pathUserID string) (jwt.MapClaims, error) {
+	claims, err := v.authenticateAPIKey(key, clientIP)
+	if err != nil {
+		stats.Add("rejected", 1)
+		return nil, err
+	}
+
+	if err = v.authorize(claims, routeName, pathUserID); err != nil {
+		stats.Add("forbidden", 1)
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Authenticate validates the access token without authorizing it for a route and returns its claims.
+func (v *Verifier) Authenticate(tokenStr string) (jwt.MapClaims, error) {
+	claims, err := v.authenticate(tokenStr)
+	if err != nil {
+		stats.Add("rejected", 1)
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// authenticate returns the claims of a valid access token, from the cache if possible.
+func (v *Verifier) authenticate(tokenStr string) (jwt.MapClaims, error) {
+	if tokenStr == "" {
+		return nil, jwtutils.ErrEmptyToken
+	}
+
+	key := securetoken.Hash(tokenStr)
+
+	if claims, ok := v.cached(key); ok {
+		stats.Add("cache_hits", 1)
+		if err := v.checkRevoked(key, claims); err != nil {
+			return nil, err
+		}
+
+		return claims, nil
+	}
+
+	stats.Add("cache_misses", 1)
+
+	claims, err := v.parse(tokenStr)
+	if err != nil {
+		if !v.cfg.RemoteFallback || !isKeyResolutionError(err) {
+			return nil, err
+		}
+
+		stats.Add("remote_fallbacks", 1)
+
+		if claims, err = jwtutils.IntrospectToken(tokenStr); err != nil {
+			return nil, fmt.Errorf("remote verification failed: %w", err)
+		}
+	}
+
+	if tokenType, _ := claims[mapKeyTokenType].(string); tokenType != tokenTypeAccess {
+		return nil, ErrNotAccessToken
+	}
+
+	v.store(key, claims)
+
+	return claims, nil
+}
+
+// authenticateAPIKey returns the claims of an active API key, from the cache if possible.
+func (v *Verifier) authenticateAPIKey(key string, clientIP string) (jwt.MapClaims, error) {
+	if key == "" {
+		return nil, jwtutils.ErrEmptyToken
+	}
+
+	cacheKey := securetoken.Hash(clientIP + " " + key)
+
+	if claims, ok := v.cached(cacheKey); ok {
+		stats.Add("cache_hits", 1)
+		if err := v.checkRevoked(cacheKey, claims); err != nil {
+			return nil, err
+		}
+
+		return claims, nil
+	}
+
+	stats.Add("cache_misses", 1)
+
+	claims, err := jwtutils.IntrospectAPIKey(key, clientIP)
+	if err != nil {
+		return nil, fmt.Errorf("api key verification failed: %w", err)
+	}
+
+	if _, ok := claims[mapKeyAPIKeyID].(string); !ok {
+		return nil, jwtutils.ErrUnauthorized
+	}
+
+	v.store(cacheKey, claims)
+
+	return claims, nil
+}
+
+// authorize checks role permissions for the route and that path user ids belong to the token owner.
+func (v *Verifier) authorize(claims jwt.MapClaims, routeName string, pathUserID string) error {
+	// service clients act on behalf of any user, their scopes alone decide which routes they may call
+	if clientID, _ := claims[mapKeyClientID].(string); clientID != "" {
+		scope, _ := claims[mapKeyScope].(string)
+		if !policy.ScopesAuthorize(strings.Fields(scope), routeName) {
+			return ErrForbidden
+		}
+
+		return nil
+	}
+
+	// api keys are limited to their scopes on top of the permissions of their owner
+	if keyID, _ := claims[mapKeyAPIKeyID].(string); keyID != "" {
+		scope, _ := claims[mapKeyScope].(string)
+		if !policy.ScopesAuthorize(strings.Fields(scope), routeName) {
+			return ErrForbidden
+		}
+	}
+
+	// impersonation tokens have the permissions of the impersonated user, except for moving money
+	if _, ok := impersonation.ActorFromClaims(claims); ok && !impersonation.Allows(routeName) {
+		return ErrForbidden
+	}
+
+	role, roleOk := claims[mapKeyRole].(string)
+	userID, userIDOk := claims[mapKeyUserID].(string)
+
+	if !roleOk || !userIDOk {
+		return ErrTypeAssertion
+	}
+
+	status, _ := claims[mapKeyStatus].(string)
+	effectiveRole := policy.EffectiveRole(role, status)
+
+	if !v.permissions.IsAuthorizedFor(effectiveRole, routeName) {
+		return ErrForbidden
+	}
+
+	// users may only act on themselves, unless their role is granted the route for any user
+	if pathUserID != "" && pathUserID != userID && !v.permissions.IsAuthorizedForAnyUser(effectiveRole, routeName) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func (v *Verifier) parse(tokenStr string) (jwt.MapClaims, error) {
+	token, err := jwtutils.ParseAndValidateToken(tokenStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwtutils.ErrUnauthorized
+	}
+
+	return claims, nil
+}
+
+// checkRevoked returns ErrTokenRevoked if cached claims were revoked since they were cached, dropping them.
+// The revocation lists are in memory, so this is cheap enough for every request.
+func (v *Verifier) checkRevoked(key string, claims jwt.MapClaims) error {
+	if !jwtutils.IsRevoked(claims) {
+		return nil
+	}
+
+	v.mu.Lock()
+	delete(v.cache, key)
+	v.mu.Unlock()
+
+	return jwtutils.ErrTokenRevoked
+}
+
+func (v *Verifier) cached(key string) (jwt.MapClaims, bool) {
+	if v.cfg.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !time.Now().Before(entry.expiresAt) {
+		delete(v.cache, key)
+		return nil, false
+	}
+
+	return entry.claims, true
+}
+
+// store caches claims for the cache ttl, but never beyond the token expiry.
+func (v *Verifier) store(key string, claims jwt.MapClaims) {
+	if v.cfg.CacheTTL <= 0 {
+		return
+	}
+
+	expiresAt := time.Now().Add(v.cfg.CacheTTL)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && exp.Before(expiresAt) {
+		expiresAt = exp.Time
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.cache) >= maxCacheEntries {
+		v.evictExpired()
+	}
+
+	if len(v.cache) >= maxCacheEntries {
+		v.cache = make(map[string]cachedClaims)
+	}
+
+	v.cache[key] = cachedClaims{claims: claims, expiresAt: expiresAt}
+}
+
+// evictExpired drops expired entries, the caller must hold the lock.
+func (v *Verifier) evictExpired() {
+	now := time.Now()
+
+	for key, entry := range v.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(v.cache, key)
+		}
+	}
+}
+
+// isKeyResolutionError reports whether local verification failed only because the signing key
+// was unavailable, rather than because the token itself is invalid.
+func isKeyResolutionError(err error) bool {
+	return errors.Is(err, jwtutils.ErrJWKSUnavailable) || errors.Is(err, jwtutils.ErrUnknownKeyID)
+}
@@ -0,0 +1,304 @@
+package verifier
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testClaims struct {
+	TokenType string
+	UserID    string
+	Role      string
+	Status    string
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func TestVerify(t *testing.T) {
+	k, err := jwtutils.GenerateEd25519Key()
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	keys := jwtutils.NewKeySet(k)
+	jwtutils.UseKeyResolver(keys)
+
+	sign := func(tokenType string, role string) string {
+		tokenStr, err := keys.Sign(testClaims{
+			TokenType:        tokenType,
+			UserID:           "user-1",
+			Role:             role,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		})
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+
+		return tokenStr
+	}
+
+	signClient := func(scope string) string {
+		tokenStr, err := keys.Sign(testClaims{
+			TokenType:        tokenTypeAccess,
+			ClientID:         "wallet-api",
+			Scope:            scope,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		})
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+
+		return tokenStr
+	}
+
+	unverified, err := keys.Sign(testClaims{
+		TokenType:        tokenTypeAccess,
+		UserID:           "user-1",
+		Role:             "user",
+		Status:           policy.StatusUnverified,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+
+	permissions, err := policy.NewStaticRolePermissions(map[string][]string{
+		"user":  {"POST:/users/:user_id"},
+		"admin": {"GET:/users/:user_id"},
+	}, map[string][]string{"admin": {"POST:/users/:user_id"}})
+	if err != nil {
+		t.Fatalf("unable to build role permissions: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		routeName  string
+		pathUserID string
+		wantErr    error
+	}{
+		{name: "Authorized", token: sign(tokenTypeAccess, "user"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-1"},
+		{name: "Route_Forbidden", token: sign(tokenTypeAccess, "user"), routeName: "POST:/users",
+			wantErr: ErrForbidden},
+		{name: "Other_User", token: sign(tokenTypeAccess, "user"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-2", wantErr: ErrForbidden},
+		{name: "Admin_Other_User", token: sign(tokenTypeAccess, "admin"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-2"},
+		{name: "Admin_Other_User_Not_Granted", token: sign(tokenTypeAccess, "admin"),
+			routeName: "GET:/users/:user_id", pathUserID: "user-2", wantErr: ErrForbidden},
+		{name: "Unknown_Role", token: sign(tokenTypeAccess, "merchant"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-1", wantErr: ErrForbidden},
+		{name: "Unverified_User", token: unverified, routeName: "POST:/users/:user_id",
+			pathUserID: "user-1", wantErr: ErrForbidden},
+		{name: "Not_Access_Token", token: sign("mfa_challenge", "user"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-1", wantErr: ErrNotAccessToken},
+		{name: "Client_Scope", token: signClient("GET:/users/*"), routeName: "GET:/users/:user_id",
+			pathUserID: "user-2"},
+		{name: "Client_Scope_Forbidden", token: signClient("GET:/users/*"), routeName: "POST:/users/:user_id",
+			pathUserID: "user-2", wantErr: ErrForbidden},
+		{name: "Malformed", token: "invalid_token_string", routeName: "POST:/users",
+			wantErr: jwt.ErrTokenMalformed},
+		{name: "Empty", token: "", routeName: "POST:/users", wantErr: jwtutils.ErrEmptyToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(permissions, Config{CacheTTL: time.Minute})
+
+			_, err := v.Verify(tt.token, tt.routeName, tt.pathUserID)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Verify() unexpected error = %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyCache(t *testing.T) {
+	k, err := jwtutils.GenerateEd25519Key()
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	keys := jwtutils.NewKeySet(k)
+	jwtutils.UseKeyResolver(keys)
+
+	tokenStr, err := keys.Sign(testClaims{
+		TokenType:        tokenTypeAccess,
+		UserID:           "user-1",
+		Role:             "user",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+
+	permissions, err := policy.NewStaticRolePermissions(map[string][]string{"user": {"POST:/users/:user_id"}}, nil)
+	if err != nil {
+		t.Fatalf("unable to build role permissions: %v", err)
+	}
+
+	v := New(permissions, Config{CacheTTL: time.Minute})
+	hitsBefore := cacheHits()
+
+	for i := 0; i < 3; i++ {
+		if _, err = v.Verify(tokenStr, "POST:/users/:user_id", "user-1"); err != nil {
+			t.Fatalf("Verify() unexpected error = %v", err)
+		}
+	}
+
+	if got := cacheHits() - hitsBefore; got != 2 {
+		t.Errorf("cache hits = %d, want 2", got)
+	}
+
+	// cached claims are still authorized per route
+	if _, err = v.Verify(tokenStr, "POST:/users", ""); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Verify() error = %v, wantErr %v", err, ErrForbidden)
+	}
+}
+
+// revokedIDs is a revocation list of token ids.
+type revokedIDs struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func (r *revokedIDs) IsRevoked(tokenID, _, _ string, _ time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.ids[tokenID]
+}
+
+func (r *revokedIDs) revoke(tokenID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ids[tokenID] = true
+}
+
+func TestVerifyCacheRevoked(t *testing.T) {
+	k, err := jwtutils.GenerateEd25519Key()
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	keys := jwtutils.NewKeySet(k)
+	jwtutils.UseKeyResolver(keys)
+
+	revoked := &revokedIDs{ids: make(map[string]bool)}
+	jwtutils.UseRevocationList(revoked)
+
+	tokenStr, err := keys.Sign(testClaims{
+		TokenType: tokenTypeAccess,
+		UserID:    "user-1",
+		Role:      "user",
+		RegisteredClaims: jwt.RegisteredClaims{ID: "token-cached-revoked",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+
+	permissions, err := policy.NewStaticRolePermissions(map[string][]string{"user": {"POST:/users/:user_id"}}, nil)
+	if err != nil {
+		t.Fatalf("unable to build role permissions: %v", err)
+	}
+
+	v := New(permissions, Config{CacheTTL: time.Minute})
+
+	if _, err = v.Verify(tokenStr, "POST:/users/:user_id", "user-1"); err != nil {
+		t.Fatalf("Verify() unexpected error = %v", err)
+	}
+
+	revoked.revoke("token-cached-revoked")
+
+	if _, err = v.Verify(tokenStr, "POST:/users/:user_id", "user-1"); !errors.Is(err, jwtutils.ErrTokenRevoked) {
+		t.Errorf("Verify() error = %v, wantErr %v", err, jwtutils.ErrTokenRevoked)
+	}
+}
+
+func cacheHits() int64 {
+	hits, ok := stats.Get("cache_hits").(interface{ Value() int64 })
+	if !ok {
+		return 0
+	}
+
+	return hits.Value()
+}
+
+func TestAuthorizeAPIKey(t *testing.T) {
+	permissions, err := policy.NewStaticRolePermissions(map[string][]string{
+		"merchant": {"POST:/users/:user_id", "GET:/users/:user_id"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to build role permissions: %v", err)
+	}
+
+	claims := jwt.MapClaims{"TokenType": tokenTypeAccess, "UserID": "user-1", "Role": "merchant",
+		"api_key_id": "key-1", "scope": "GET:/users/*"}
+
+	tests := []struct {
+		name       string
+		routeName  string
+		pathUserID string
+		wantErr    error
+	}{
+		{name: "In_Scope", routeName: "GET:/users/:user_id", pathUserID: "user-1"},
+		{name: "Out_Of_Scope", routeName: "POST:/users/:user_id", pathUserID: "user-1", wantErr: ErrForbidden},
+		{name: "Other_User", routeName: "GET:/users/:user_id", pathUserID: "user-2", wantErr: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(permissions, Config{})
+
+			if err := v.authorize(claims, tt.routeName, tt.pathUserID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeImpersonation(t *testing.T) {
+	permissions, err := policy.NewStaticRolePermissions(map[string][]string{
+		"user": {"GET:/users/:user_id", "POST:/transfers"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to build role permissions: %v", err)
+	}
+
+	claims := jwt.MapClaims{"TokenType": tokenTypeAccess, "UserID": "user-1", "Role": "user",
+		impersonation.ClaimActor: map[string]interface{}{"UserID": "admin-1"}}
+
+	tests := []struct {
+		name      string
+		routeName string
+		wantErr   error
+	}{
+		{name: "Read", routeName: "GET:/users/:user_id"},
+		{name: "Money_Moving", routeName: "POST:/transfers", wantErr: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(permissions, Config{})
+
+			if err := v.authorize(claims, tt.routeName, "user-1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	adminAPI "github.com/ashtishad/instabid-wallet/admin-api/cmd/app"
 	authAPI "github.com/ashtishad/instabid-wallet/auth-api/cmd/app"
 	"github.com/ashtishad/instabid-wallet/lib"
 	userAPI "github.com/ashtishad/instabid-wallet/user-api/cmd/app"
@@ -46,6 +47,15 @@ func main() {
 		wg.Done()
 	}()
 
+	adminServer := lib.InitServerConfig("ADMIN_API_PORT")
+
+	wg.Add(1)
+
+	go func() {
+		adminAPI.Start(adminServer, dbClient, l)
+		wg.Done()
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
@@ -53,10 +63,11 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
-	wg.Add(2)
+	wg.Add(3)
 
 	go lib.GracefulShutdown(ctx, userServer, &wg, "User")
-	go lib.GracefulShutdown(ctx, userServer, &wg, "Auth")
+	go lib.GracefulShutdown(ctx, authServer, &wg, "Auth")
+	go lib.GracefulShutdown(ctx, adminServer, &wg, "Admin")
 
 	wg.Wait()
 }
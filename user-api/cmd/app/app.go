@@ -1,17 +1,33 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"expvar"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/idempotency"
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/password"
+	"github.com/ashtishad/instabid-wallet/lib/policy"
+	"github.com/ashtishad/instabid-wallet/lib/revocation"
+	"github.com/ashtishad/instabid-wallet/lib/securetoken"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
+const verificationSecretSize = 32
+
 func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
 	if os.Getenv("GIN_MODE") != "" {
 		gin.SetMode(os.Getenv("GIN_MODE"))
@@ -20,12 +36,49 @@ func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
 	var r = gin.New()
 	srv.Handler = r
 
+	// client IPs are taken from X-Forwarded-For only behind the configured proxies
+	if err := r.SetTrustedProxies(lib.TrustedProxies()); err != nil {
+		l.Error("invalid TRUSTED_PROXIES", "err", err.Error())
+		os.Exit(1)
+	}
+
 	// wire up the handler
 	userRepositoryDB := domain.NewUserRepoDB(dbClient, l)
-	uh := UserHandlers{service.NewUserService(userRepositoryDB, l)}
+	uh := UserHandlers{service.NewUserService(userRepositoryDB, notifier.FromEnv(l), verificationSecret(l),
+		password.PolicyFromEnv(l), audit.NewStoreDB(dbClient, l), l)}
+
+	// role permissions are loaded from the database and kept in sync
+	permissions := policy.NewRolePermissions(dbClient, l)
+	if apiErr := permissions.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load role permissions", "err", apiErr.WithCauses())
+	}
+
+	permissions.StartSync(context.Background(), policy.DefaultSyncInterval)
+
+	// revoked tokens are rejected, the revocation list is loaded from the database and kept in sync
+	revocations := revocation.NewStore(dbClient, l)
+	if apiErr := revocations.Load(context.Background()); apiErr != nil {
+		l.Error("unable to load token revocation list", "err", apiErr.WithCauses())
+	}
+
+	revocations.StartSync(context.Background(), revocation.DefaultSyncInterval)
+	jwtutils.UseRevocationList(revocations)
+
+	// tokens are verified locally, remote verification by auth-api only as a configured fallback
+	remoteFallback, _ := strconv.ParseBool(os.Getenv("VERIFY_REMOTE_FALLBACK"))
+	v := verifier.New(permissions, verifier.Config{
+		CacheTTL:       verifier.DefaultCacheTTL,
+		RemoteFallback: remoteFallback,
+	})
+
+	// responses of mutating requests are kept for retries with the same Idempotency-Key
+	idempotencyStore := idempotency.NewStoreDB(dbClient, l)
+	idempotencyStore.StartPurge(context.Background(), idempotency.DefaultPurgeInterval)
 
 	// route url mappings
-	setUsersAPIRoutes(r, uh, l)
+	recorder := impersonation.NewRecorderDB(dbClient, l)
+	setUsersAPIRoutes(r, uh, v, recorder, idempotencyStore, l)
+	r.GET("/debug/vars", validateJWTMiddleware(v, recorder, l), gin.WrapH(expvar.Handler()))
 
 	// start server
 	go func() {
@@ -35,11 +88,51 @@ func Start(srv *http.Server, dbClient *sql.DB, l *slog.Logger) {
 	}()
 }
 
-func setUsersAPIRoutes(r *gin.Engine, uh UserHandlers, l *slog.Logger) {
+func setUsersAPIRoutes(r *gin.Engine, uh UserHandlers, v *verifier.Verifier, recorder impersonation.Recorder,
+	idempotencyStore idempotency.Store, l *slog.Logger) {
+	idempotent := idempotency.Middleware(idempotencyStore, idempotencyScope, l)
+
+	r.GET("/users/verify-email", uh.VerifyEmailHandler)
+	r.POST("/users/verify-email/resend", idempotent, uh.ResendVerificationHandler)
+
+	// imports are streamed, the idempotency middleware would read the whole body to fingerprint it,
+	// they can be run again as they are instead
+	r.POST("/users/import", validateJWTMiddleware(v, recorder, l), uh.ImportUsersHandler)
+
 	userRoutes := r.Group("/users")
-	userRoutes.Use(validateJWTMiddleware(l))
+	userRoutes.Use(validateJWTMiddleware(v, recorder, l), idempotent)
 	{
+		userRoutes.GET("", uh.FindUsersHandler)
 		userRoutes.POST("", uh.CreateUserHandler)
 		userRoutes.POST("/:user_id", uh.CreateUserProfileHandler)
+		userRoutes.GET("/:user_id", uh.GetUserHandler)
+		userRoutes.PUT("/:user_id", uh.UpdateUserHandler)
+		userRoutes.PATCH("/:user_id", uh.UpdateUserHandler)
+		userRoutes.DELETE("/:user_id", uh.DeleteUserHandler)
+		userRoutes.GET("/:user_id/profile", uh.GetUserProfileHandler)
+		userRoutes.PUT("/:user_id/profile", uh.UpdateUserProfileHandler)
+		userRoutes.PATCH("/:user_id/profile", uh.UpdateUserProfileHandler)
+		userRoutes.GET("/:user_id/export", uh.ExportUserHandler)
+		userRoutes.POST("/:user_id/erasure", uh.RequestErasureHandler)
+		userRoutes.GET("/:user_id/erasure", uh.GetErasureRequestHandler)
+		userRoutes.DELETE("/:user_id/erasure", uh.CancelErasureHandler)
+	}
+}
+
+// verificationSecret returns the EMAIL_VERIFICATION_SECRET verification links are signed with.
+// Without it a random secret is generated, links then don't survive a restart or work across instances.
+func verificationSecret(l *slog.Logger) []byte {
+	if secret := os.Getenv("EMAIL_VERIFICATION_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	secret, err := securetoken.Generate(verificationSecretSize)
+	if err != nil {
+		l.Error("unable to generate email verification secret", "err", err.Error())
+		os.Exit(1)
 	}
+
+	l.Warn("EMAIL_VERIFICATION_SECRET not defined, signing verification links with an ephemeral secret")
+
+	return []byte(secret)
 }
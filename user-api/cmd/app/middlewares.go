@@ -6,7 +6,10 @@ import (
 	"log/slog"
 	"net/http"
 
-	"github.com/ashtishad/instabid-wallet/lib/jwtutils"
+	"github.com/ashtishad/instabid-wallet/lib/apikey"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/impersonation"
+	"github.com/ashtishad/instabid-wallet/lib/verifier"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,22 +18,27 @@ import (
 const (
 	AuthHeader = "Authorization"
 	Bearer     = "Bearer"
+	APIKey     = apikey.Scheme
+
+	authorizedUserKey = "authorizedUserRequest"
 )
 
 var (
 	ErrAuthHeaderNotFound  = errors.New("authorization header not found")
-	ErrBearerTokenNotFound = errors.New("bearer token not found in auth header")
+	ErrBearerTokenNotFound = errors.New("bearer token or api key not found in auth header")
 	ErrTypeAssertionFailed = errors.New("type assertion failed for one or more token claims")
 )
 
 // validateJWTMiddleware is a Gin middleware function that authorises incoming HTTP requests
-// by validating JWT tokens found in the "Authorization" header.
+// by validating JWT tokens or merchant API keys found in the "Authorization" header.
 // If the token is valid, it extracts the claims and sets them in the Gin context.
-// It builds verify url and sends a get request to auth-api using verifyTokenWithAuthAPI,
-// Otherwise, it responds with a 401 Unauthorized status and aborts the request.
-func validateJWTMiddleware(l *slog.Logger) gin.HandlerFunc {
+// Tokens are verified and authorized for the route locally by the verifier,
+// Otherwise, it responds with a 401 Unauthorized or 403 Forbidden status and aborts the request.
+// Requests with impersonation tokens are limited to reads and recorded with recorder, forbidden ones included.
+// The request context carries the audit log actor, the admin for impersonation tokens.
+func validateJWTMiddleware(v *verifier.Verifier, recorder impersonation.Recorder, l *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenStr, err := extractToken(c)
+		scheme, tokenStr, err := extractToken(c)
 		if err != nil {
 			l.Error("unable to extract token", "err", err.Error())
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -46,9 +54,25 @@ func validateJWTMiddleware(l *slog.Logger) gin.HandlerFunc {
 			pathUserID = c.Param("user_id")
 		}
 
-		claims, err := jwtutils.VerifyTokenWithAuthAPI(tokenStr, routeName, pathUserID)
+		var claims jwt.MapClaims
+		if scheme == APIKey {
+			claims, err = v.VerifyAPIKey(tokenStr, c.ClientIP(), routeName, pathUserID)
+		} else {
+			claims, err = v.Verify(tokenStr, routeName, pathUserID)
+		}
 
 		if err != nil {
+			if errors.Is(err, verifier.ErrForbidden) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				c.Abort()
+
+				if user := impersonatedUser(v, scheme, tokenStr); user != nil {
+					recordImpersonatedRequest(c, recorder, user)
+				}
+
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			c.Abort()
 
@@ -66,40 +90,125 @@ func validateJWTMiddleware(l *slog.Logger) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("authorizedUserRequest", user)
-		c.Next()
+		c.Set(authorizedUserKey, user)
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), auditActor(user, c.ClientIP())))
+
+		if user.ImpersonatorID == "" {
+			c.Next()
+			return
+		}
+
+		// an impersonating admin can't change the users, profiles or erasures of the impersonated user
+		if c.Request.Method != http.MethodGet {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+		} else {
+			c.Next()
+		}
+
+		recordImpersonatedRequest(c, recorder, user)
+	}
+}
+
+// impersonatedUser returns the user of a valid impersonation token, nil for other tokens and API keys.
+func impersonatedUser(v *verifier.Verifier, scheme string, tokenStr string) *domain.AuthorizedUser {
+	if scheme != Bearer {
+		return nil
+	}
+
+	claims, err := v.Authenticate(tokenStr)
+	if err != nil {
+		return nil
+	}
+
+	user, err := getAuthorizedUserFromClaims(claims)
+	if err != nil || user.ImpersonatorID == "" {
+		return nil
+	}
+
+	return user
+}
+
+// recordImpersonatedRequest records a request made with the impersonation token of user, once it's handled.
+func recordImpersonatedRequest(c *gin.Context, recorder impersonation.Recorder, user *domain.AuthorizedUser) {
+	recorder.Record(c.Request.Context(), impersonation.Request{
+		ImpersonationID: user.ImpersonationID,
+		Method:          c.Request.Method,
+		Path:            c.Request.URL.Path,
+		Status:          c.Writer.Status(),
+		IP:              c.ClientIP(),
+	})
+}
+
+// authorizedUser returns the user validateJWTMiddleware authorized the request for.
+func authorizedUser(c *gin.Context) *domain.AuthorizedUser {
+	user, _ := c.MustGet(authorizedUserKey).(*domain.AuthorizedUser)
+	return user
+}
 
-		c.Next()
+// auditActor returns who acts with the authorized user's token, the admin of impersonation tokens,
+// the client of service client tokens.
+func auditActor(user *domain.AuthorizedUser, ip string) audit.Actor {
+	switch {
+	case user.ImpersonatorID != "":
+		return audit.Actor{ID: user.ImpersonatorID, IP: ip}
+	case user.UserID == "" && user.ClientID != "":
+		return audit.Actor{ID: "client:" + user.ClientID, IP: ip}
+	default:
+		return audit.Actor{ID: user.UserID, IP: ip}
 	}
 }
 
-// extractToken retrieves the JWT token from the "Authorization" header.
-// Returns an error if the header is missing or improperly formatted.
-func extractToken(c *gin.Context) (string, error) {
+// idempotencyScope scopes idempotency keys to the authorized user or service client,
+// keys of unauthenticated requests to the client ip.
+func idempotencyScope(c *gin.Context) string {
+	user, ok := c.Value(authorizedUserKey).(*domain.AuthorizedUser)
+
+	switch {
+	case !ok:
+		return "ip:" + c.ClientIP()
+	case user.ClientID != "":
+		return "client:" + user.ClientID
+	default:
+		return "user:" + user.UserID
+	}
+}
+
+// extractToken retrieves the JWT token or API key from the "Authorization" header with its scheme,
+// "Bearer <token>" or "ApiKey <key>". Returns an error if the header is missing or improperly formatted.
+func extractToken(c *gin.Context) (string, string, error) {
 	authHeader := c.GetHeader(AuthHeader)
 	if authHeader == "" {
-		return "", ErrAuthHeaderNotFound
+		return "", "", ErrAuthHeaderNotFound
 	}
 
-	var tokenStr string
-	_, err := fmt.Sscanf(authHeader, Bearer+" %s", &tokenStr)
+	var scheme, tokenStr string
+	_, err := fmt.Sscanf(authHeader, "%s %s", &scheme, &tokenStr)
 
-	if err != nil {
-		return "", ErrBearerTokenNotFound
+	if err != nil || (scheme != Bearer && scheme != APIKey) {
+		return "", "", ErrBearerTokenNotFound
 	}
 
-	return tokenStr, nil
+	return scheme, tokenStr, nil
 }
 
-// getAuthorizedUserFromClaims extracts a User object from a set of JWT claims.
+// getAuthorizedUserFromClaims extracts a User object from a set of JWT claims,
+// tokens of service clients map to a User object with only the client id,
+// API keys map to their owner with the key id, impersonation tokens to the impersonated user with the admin.
 // It returns the User object if all required claims are present and valid,
 // otherwise returns an error.
 func getAuthorizedUserFromClaims(claims jwt.MapClaims) (*domain.AuthorizedUser, error) {
+	if clientID, ok := claims["client_id"].(string); ok && clientID != "" {
+		return &domain.AuthorizedUser{ClientID: clientID}, nil
+	}
+
 	userID, ok1 := claims["UserID"].(string)
 	userName, ok2 := claims["Username"].(string)
 	email, ok3 := claims["Email"].(string)
 	role, ok4 := claims["Role"].(string)
 	status, ok5 := claims["Status"].(string)
+	apiKeyID, _ := claims["api_key_id"].(string)
+	sessionID, _ := claims["SessionID"].(string)
+	impersonatorID, _ := impersonation.ActorFromClaims(claims)
 
 	if ok1 && ok2 && ok3 && ok4 && ok5 {
 		user := &domain.AuthorizedUser{
@@ -108,6 +217,12 @@ func getAuthorizedUserFromClaims(claims jwt.MapClaims) (*domain.AuthorizedUser,
 			Email:    email,
 			Role:     role,
 			Status:   status,
+			APIKeyID: apiKeyID,
+		}
+
+		if impersonatorID != "" {
+			user.ImpersonatorID = impersonatorID
+			user.ImpersonationID = sessionID
 		}
 
 		return user, nil
@@ -2,10 +2,17 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
+	"github.com/ashtishad/instabid-wallet/lib"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/service"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/bundle"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/etag"
 	"github.com/ashtishad/instabid-wallet/user-api/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -32,10 +39,7 @@ func (uh *UserHandlers) CreateUserHandler(c *gin.Context) {
 
 	res, apiErr := uh.s.NewUser(ctx, newUserRequest)
 	if apiErr != nil {
-		c.JSON(apiErr.Code(), gin.H{
-			"error": apiErr.Error(),
-		})
-
+		c.JSON(apiErr.Code(), lib.ErrorBody(apiErr))
 		return
 	}
 
@@ -75,7 +79,387 @@ func (uh *UserHandlers) CreateUserProfileHandler(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etag.Format(res.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"userProfile": &res,
 	})
 }
+
+// VerifyEmailHandler confirms the email of a user with the token from the verification link.
+func (uh *UserHandlers) VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token can't be empty"})
+		return
+	}
+
+	res, apiErr := uh.s.VerifyEmail(c.Request.Context(), token)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": &res,
+	})
+}
+
+// ResendVerificationHandler sends a new verification link, it's accepted whether or not the email is registered.
+func (uh *UserHandlers) ResendVerificationHandler(c *gin.Context) {
+	var req domain.ResendVerificationReqDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if apiErr := uh.s.ResendVerification(c.Request.Context(), req, c.ClientIP()); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// FindUsersHandler lists users a page at a time, see domain.FindUsersReqDTO for the query parameters.
+func (uh *UserHandlers) FindUsersHandler(c *gin.Context) {
+	var req domain.FindUsersReqDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutFindUsers)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.FindUsers(ctx, req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetUserHandler fetches a user by id.
+func (uh *UserHandlers) GetUserHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.FindUser(ctx, authorizedUser(c), c.Param("user_id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": &res,
+	})
+}
+
+// UpdateUserHandler updates a user by id, PUT replaces the username and email, PATCH only the given fields.
+func (uh *UserHandlers) UpdateUserHandler(c *gin.Context) {
+	var req domain.UpdateUserReqDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	replace := c.Request.Method == http.MethodPut
+
+	res, apiErr := uh.s.UpdateUser(ctx, c.Param("user_id"), req, replace)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": &res,
+	})
+}
+
+// DeleteUserHandler soft deletes a user by id.
+func (uh *UserHandlers) DeleteUserHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	if apiErr := uh.s.DeleteUser(ctx, c.Param("user_id")); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUserProfileHandler fetches the profile of a user by id, with its version as the ETag header.
+func (uh *UserHandlers) GetUserProfileHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.FindProfile(ctx, c.Param("user_id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Header("ETag", etag.Format(res.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"userProfile": &res,
+	})
+}
+
+// UpdateUserProfileHandler updates the profile of a user by id, PUT replaces it, PATCH only the given fields.
+// The If-Match header must have the ETag the profile was fetched with.
+func (uh *UserHandlers) UpdateUserProfileHandler(c *gin.Context) {
+	var req domain.UpdateProfileReqDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutCreateUserProfile)
+
+		defer cancel()
+	}
+
+	replace := c.Request.Method == http.MethodPut
+
+	res, apiErr := uh.s.UpdateProfile(ctx, c.Param("user_id"), c.GetHeader("If-Match"), req, replace)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.Header("ETag", etag.Format(res.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"userProfile": &res,
+	})
+}
+
+// ExportUserHandler exports everything held about a user by id, as JSON or with format=zip as a zip archive
+// of JSON files.
+func (uh *UserHandlers) ExportUserHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", utils.ExportFormatJSON)
+	if apiErr := utils.ValidateExportFormat(format); apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{"error": apiErr.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutExport)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.ExportUser(ctx, c.Param("user_id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	filename := fmt.Sprintf("user-%s-export.%s", res.User.UserID, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+
+	if format == utils.ExportFormatJSON {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	if err := bundle.WriteZip(c.Writer, exportFiles(res), res.ExportedAt); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// RequestErasureHandler requests the erasure of the personal data of a user by id, it's carried out
+// once an admin approves it and the cooling-off period ends.
+func (uh *UserHandlers) RequestErasureHandler(c *gin.Context) {
+	var req domain.ErasureReqDTO
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.RequestErasure(ctx, authorizedUser(c), c.Param("user_id"), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"erasureRequest": res,
+	})
+}
+
+// GetErasureRequestHandler fetches the latest erasure request of a user by id.
+func (uh *UserHandlers) GetErasureRequestHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.FindErasureRequest(ctx, c.Param("user_id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"erasureRequest": res,
+	})
+}
+
+// CancelErasureHandler cancels the open erasure request of a user by id.
+func (uh *UserHandlers) CancelErasureHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if gin.Mode() == gin.ReleaseMode {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, utils.TimeoutUser)
+
+		defer cancel()
+	}
+
+	res, apiErr := uh.s.CancelErasure(ctx, c.Param("user_id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"erasureRequest": res,
+	})
+}
+
+// ImportUsersHandler imports users in bulk from the request body, streamed as it's read,
+// see domain.ImportUsersReqDTO for the query parameters. Responds with the import report, for dry runs too.
+// Imports take longer than the server's read and write timeouts allow, they're lifted for the request.
+func (uh *UserHandlers) ImportUsersHandler(c *gin.Context) {
+	var req domain.ImportUsersReqDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rc := http.NewResponseController(c.Writer)
+	if err := errors.Join(rc.SetReadDeadline(time.Time{}), rc.SetWriteDeadline(time.Time{})); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to lift the request deadlines"})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxImportSize)
+
+	res, apiErr := uh.s.ImportUsers(c.Request.Context(), body, req)
+	if apiErr != nil {
+		c.JSON(apiErr.Code(), gin.H{
+			"error": apiErr.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// exportFiles splits an export into the files of its zip archive.
+func exportFiles(res *domain.ExportRespDTO) []bundle.File {
+	return []bundle.File{
+		{Name: "user.json", Content: gin.H{"exportedAt": res.ExportedAt, "user": res.User, "profile": res.Profile}},
+		{Name: "sessions.json", Content: res.Sessions},
+		{Name: "auth_events.json", Content: res.AuthEvents},
+		{Name: "admin_actions.json", Content: res.AdminActions},
+		{Name: "erasure_requests.json", Content: res.ErasureRequests},
+		{Name: "audit_log.json", Content: res.AuditLog},
+	}
+}
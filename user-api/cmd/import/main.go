@@ -0,0 +1,119 @@
+// Command import imports users in bulk from a CSV or JSON Lines file, or stdin with "-", and prints a JSON report
+// of it, with why every line that wasn't imported failed. It exits with status 1 if any line failed.
+//
+//	go run ./user-api/cmd/import -dry-run users.csv
+//	go run ./user-api/cmd/import -format jsonl - < users.jsonl
+//
+// The format is taken from the file extension unless given with -format. CSV files start with a header naming
+// their columns, userName, password and email, status and role optionally, JSON Lines have an object per line
+// with the same fields, admins and moderators can't be imported. It connects to the database of the DB_*
+// environment variables.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/db/conn"
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/password"
+	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
+	"github.com/ashtishad/instabid-wallet/user-api/internal/service"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/userimport"
+)
+
+// auditActor is who imports made with the command are attributed to in the audit log.
+const auditActor = "cli:import"
+
+func main() {
+	format := flag.String("format", "", "csv or jsonl, taken from the file extension if not given")
+	dryRun := flag.Bool("dry-run", false, "validate every line and report what would be imported, without importing")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: import [-format csv|jsonl] [-dry-run] <file|->\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// the report is written to stdout, logs go to stderr
+	l := slog.New(slog.NewTextHandler(os.Stderr, lib.GetSlogConf()))
+
+	os.Exit(run(l, flag.Arg(0), domain.ImportUsersReqDTO{Format: *format, DryRun: *dryRun}))
+}
+
+// run imports the users of the file and writes the report, it returns the exit status.
+func run(l *slog.Logger, path string, req domain.ImportUsersReqDTO) int {
+	var in io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			l.Error("unable to open the input", "err", err.Error())
+			return 2
+		}
+		defer f.Close()
+
+		in = f
+
+		if req.Format == "" {
+			req.Format = formatOf(path)
+		}
+	}
+
+	dbClient := conn.GetDBClient(l)
+	defer func() {
+		if err := dbClient.Close(); err != nil {
+			l.Error("unable to close db", "err", err.Error())
+		}
+	}()
+
+	// only the password policy is used by imports and nothing is sent, chunks are recorded in the audit log
+	// by the repository, attributed to the command
+	s := service.NewUserService(domain.NewUserRepoDB(dbClient, l), nil, nil, password.PolicyFromEnv(l),
+		audit.NewStoreDB(dbClient, l), l)
+	ctx := audit.WithActor(context.Background(), audit.Actor{ID: auditActor})
+
+	res, apiErr := s.ImportUsers(ctx, in, req)
+	if apiErr != nil {
+		l.Error("unable to import users", "err", apiErr.WithCauses())
+		return 2
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(res); err != nil {
+		l.Error("unable to write the report", "err", err.Error())
+		return 2
+	}
+
+	if res.Failed > 0 || res.Error != "" {
+		return 1
+	}
+
+	return 0
+}
+
+// formatOf returns the import format of a file by its extension, .csv or .jsonl and .ndjson.
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return userimport.FormatCSV
+	case ".jsonl", ".ndjson":
+		return userimport.FormatJSONL
+	default:
+		return ""
+	}
+}
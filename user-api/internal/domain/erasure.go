@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+// Erasure request statuses, requests are pending until an admin approves or rejects them.
+// Approved requests are carried out once their cooling-off period ends, until then users can cancel them.
+const (
+	ErasurePending   = "pending"
+	ErasureApproved  = "approved"
+	ErasureRejected  = "rejected"
+	ErasureCancelled = "cancelled"
+	ErasureCompleted = "completed"
+)
+
+// ErasureRequest is a request to erase the personal data of a user.
+type ErasureRequest struct {
+	RequestID      string     `json:"requestId"`
+	UserID         string     `json:"userId"`
+	RequestedBy    *string    `json:"requestedBy"`
+	ImpersonatedBy *string    `json:"impersonatedBy,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	Status         string     `json:"status"`
+	DecidedBy      *string    `json:"decidedBy,omitempty"`
+	DecidedAt      *time.Time `json:"decidedAt,omitempty"`
+	DecisionNote   string     `json:"decisionNote,omitempty"`
+	EraseAfter     *time.Time `json:"eraseAfter,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Session is a login session of a user, the device it was started from and when it was last used.
+type Session struct {
+	SessionID  string     `json:"sessionId"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// AuthEvent is a login or MFA attempt of a user.
+type AuthEvent struct {
+	Event      string    `json:"event"`
+	Reason     string    `json:"reason,omitempty"`
+	Identifier string    `json:"identifier,omitempty"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// AdminAction is a change an admin made to a user, with the reason given for it.
+type AdminAction struct {
+	AdminID    *string   `json:"adminId"`
+	Action     string    `json:"action"`
+	OldValue   string    `json:"oldValue"`
+	NewValue   string    `json:"newValue"`
+	ReasonCode string    `json:"reasonCode"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Export is the personal data held about a user, except the audit log, which is kept apart.
+type Export struct {
+	User            *User
+	Profile         *Profile
+	Sessions        []Session
+	AuthEvents      []AuthEvent
+	AdminActions    []AdminAction
+	ErasureRequests []ErasureRequest
+}
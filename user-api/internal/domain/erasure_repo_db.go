@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+)
+
+const sqlErasureRequestColumns = `request_id, user_id, requested_by, impersonated_by, reason, status, decided_by,
+								  decided_at, decision_note, erase_after, completed_at, created_at`
+
+// Export reads everything held about a user in one read-only snapshot, so the parts are consistent.
+// Returns 404 for unknown users.
+func (d *UserRepoDB) Export(ctx context.Context, uuid string) (*Export, lib.APIError) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	// nothing is written, the transaction is only for the snapshot
+	defer lib.Rollback(tx, d.l)
+
+	var export Export
+	var apiErr lib.APIError
+
+	if export.User, apiErr = d.exportUser(ctx, tx, uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if apiErr = d.exportProfile(ctx, tx, &export); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err = exportRecords(ctx, tx, uuid, &export); err != nil {
+		d.l.ErrorContext(ctx, "unable to export user records", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &export, nil
+}
+
+// InsertErasureRequest creates a pending erasure request, returns 409 if the user already has an open one.
+func (d *UserRepoDB) InsertErasureRequest(ctx context.Context, er ErasureRequest) (*ErasureRequest, lib.APIError) {
+	sqlInsert := `INSERT INTO erasure_requests (user_id, requested_by, impersonated_by, reason) VALUES ($1, $2, $3, $4)
+				  ON CONFLICT (user_id) WHERE status IN ('pending', 'approved') DO NOTHING
+				  RETURNING ` + sqlErasureRequestColumns
+
+	row := d.db.QueryRowContext(ctx, sqlInsert, er.UserID, er.RequestedBy, er.ImpersonatedBy, er.Reason)
+
+	res, err := scanErasureRequest(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.ConflictError("user already has an open erasure request")
+		}
+
+		d.l.ErrorContext(ctx, "unable to insert erasure request", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &res, nil
+}
+
+// FindErasureRequest returns the latest erasure request of a user, returns 404 if it has none.
+func (d *UserRepoDB) FindErasureRequest(ctx context.Context, uuid string) (*ErasureRequest, lib.APIError) {
+	sqlFind := `SELECT ` + sqlErasureRequestColumns + ` FROM erasure_requests
+				WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1`
+
+	res, err := scanErasureRequest(d.db.QueryRowContext(ctx, sqlFind, uuid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user has no erasure request")
+		}
+
+		d.l.ErrorContext(ctx, "unable to find erasure request", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &res, nil
+}
+
+// CancelErasureRequest cancels the open erasure request of a user, pending or approved and not carried out yet.
+// Returns 404 if the user has no open request.
+func (d *UserRepoDB) CancelErasureRequest(ctx context.Context, uuid string) (*ErasureRequest, lib.APIError) {
+	sqlCancel := `UPDATE erasure_requests SET status = 'cancelled'
+				  WHERE user_id = $1 AND status IN ('pending', 'approved')
+				  RETURNING ` + sqlErasureRequestColumns
+
+	res, err := scanErasureRequest(d.db.QueryRowContext(ctx, sqlCancel, uuid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user has no open erasure request")
+		}
+
+		d.l.ErrorContext(ctx, "unable to cancel erasure request", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &res, nil
+}
+
+func (d *UserRepoDB) exportUser(ctx context.Context, tx *sql.Tx, uuid string) (*User, lib.APIError) {
+	sqlUser := `SELECT id, user_id, username, email, status, role, created_at, updated_at, email_verified_at,
+				erased_at FROM users WHERE user_id = $1`
+
+	var u User
+
+	err := tx.QueryRowContext(ctx, sqlUser, uuid).Scan(&u.ID, &u.UserID, &u.UserName, &u.Email, &u.Status, &u.Role,
+		&u.CreatedAt, &u.UpdatedAt, &u.EmailVerifiedAt, &u.ErasedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by uuid")
+		}
+
+		d.l.ErrorContext(ctx, "unable to export user", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &u, nil
+}
+
+// exportProfile sets the profile of the exported user, it's left nil if the user has none.
+func (d *UserRepoDB) exportProfile(ctx context.Context, tx *sql.Tx, export *Export) lib.APIError {
+	sqlProfile := `SELECT first_name, last_name, gender, address, created_at, updated_at, version
+				   FROM user_profiles WHERE user_id = $1`
+
+	var up Profile
+
+	err := tx.QueryRowContext(ctx, sqlProfile, export.User.ID).Scan(&up.FirstName, &up.LastName, &up.Gender,
+		&up.Address, &up.CreatedAt, &up.UpdatedAt, &up.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		d.l.ErrorContext(ctx, "unable to export profile", "err", err.Error())
+
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	export.Profile = &up
+
+	return nil
+}
+
+// exportRecords sets the sessions, auth events, admin actions and erasure requests of the exported user.
+func exportRecords(ctx context.Context, tx *sql.Tx, uuid string, export *Export) error {
+	sqlSessions := `SELECT id, ip, user_agent, created_at, last_seen_at, revoked_at FROM auth_sessions
+					WHERE user_id = $1 ORDER BY created_at`
+	sqlAuthEvents := `SELECT event, reason, identifier, ip, user_agent, created_at FROM auth_events
+					  WHERE user_id = $1 ORDER BY created_at`
+	sqlAdminActions := `SELECT admin_id, action, old_value, new_value, reason_code, note, created_at
+						FROM admin_audit_log WHERE user_id = $1 ORDER BY created_at`
+	sqlErasureRequests := `SELECT ` + sqlErasureRequestColumns + ` FROM erasure_requests
+						   WHERE user_id = $1 ORDER BY created_at`
+
+	var err error
+
+	export.Sessions, err = queryAll(ctx, tx, sqlSessions, uuid, func(rows *sql.Rows) (Session, error) {
+		var s Session
+		err := rows.Scan(&s.SessionID, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+
+		return s, err
+	})
+	if err != nil {
+		return err
+	}
+
+	export.AuthEvents, err = queryAll(ctx, tx, sqlAuthEvents, uuid, func(rows *sql.Rows) (AuthEvent, error) {
+		var e AuthEvent
+		err := rows.Scan(&e.Event, &e.Reason, &e.Identifier, &e.IP, &e.UserAgent, &e.CreatedAt)
+
+		return e, err
+	})
+	if err != nil {
+		return err
+	}
+
+	export.AdminActions, err = queryAll(ctx, tx, sqlAdminActions, uuid, func(rows *sql.Rows) (AdminAction, error) {
+		var a AdminAction
+		err := rows.Scan(&a.AdminID, &a.Action, &a.OldValue, &a.NewValue, &a.ReasonCode, &a.Note, &a.CreatedAt)
+
+		return a, err
+	})
+	if err != nil {
+		return err
+	}
+
+	export.ErasureRequests, err = queryAll(ctx, tx, sqlErasureRequests, uuid,
+		func(rows *sql.Rows) (ErasureRequest, error) { return scanErasureRequest(rows) })
+
+	return err
+}
+
+// queryAll returns every row of a query by user id, scanned with scan.
+func queryAll[T any](ctx context.Context, tx *sql.Tx, query string, uuid string,
+	scan func(rows *sql.Rows) (T, error)) ([]T, error) {
+	rows, err := tx.QueryContext(ctx, query, uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []T{}
+
+	for rows.Next() {
+		var v T
+		if v, err = scan(rows); err != nil {
+			return nil, err
+		}
+
+		res = append(res, v)
+	}
+
+	return res, rows.Err()
+}
+
+// scanErasureRequest scans the sqlErasureRequestColumns of a row.
+func scanErasureRequest(row interface{ Scan(dest ...any) error }) (ErasureRequest, error) {
+	var er ErasureRequest
+	err := row.Scan(&er.RequestID, &er.UserID, &er.RequestedBy, &er.ImpersonatedBy, &er.Reason, &er.Status,
+		&er.DecidedBy, &er.DecidedAt, &er.DecisionNote, &er.EraseAfter, &er.CompletedAt, &er.CreatedAt)
+
+	return er, err
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+)
+
+// InsertBatch inserts users with a single multi-row insert in a transaction, users whose username or email
+// is taken are skipped. It returns the lowercase usernames of the users inserted. The ids of the users inserted
+// are recorded in the audit log in the same transaction, attributed to the actor of ctx.
+// With dryRun the transaction is rolled back, so it only reports which users would be inserted.
+func (d *UserRepoDB) InsertBatch(ctx context.Context, users []User, dryRun bool) (map[string]bool, lib.APIError) {
+	if len(users) == 0 {
+		return map[string]bool{}, nil
+	}
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.Rollback(tx, d.l)
+
+	userIDs, err := insertUsers(ctx, tx, users)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to insert users", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	inserted := make(map[string]bool, len(userIDs))
+	ids := make([]string, 0, len(userIDs))
+
+	for userName, userID := range userIDs {
+		inserted[userName] = true
+		ids = append(ids, userID)
+	}
+
+	if dryRun {
+		return inserted, nil
+	}
+
+	slices.Sort(ids)
+
+	actor := audit.ActorFromContext(ctx)
+	if _, err = audit.AppendTx(ctx, tx, audit.Entry{Actor: actor.ID, Action: audit.ActionUserImport,
+		Diff: audit.Diff{"userIds": {After: ids}}, IP: actor.IP}); err != nil {
+		d.l.ErrorContext(ctx, "unable to append audit log entry", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return inserted, nil
+}
+
+// insertUsers inserts users in one statement, on conflict of any unique column a user is skipped,
+// so is a user repeating the username or email of one before it. It returns the ids of the users inserted
+// by lowercase username.
+func insertUsers(ctx context.Context, tx *sql.Tx, users []User) (map[string]string, error) {
+	const columns = 5
+
+	values := make([]string, len(users))
+	args := make([]any, 0, len(users)*columns)
+
+	for i, u := range users {
+		n := i * columns
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, u.UserName, u.Email, u.HashedPass, u.Status, u.Role)
+	}
+
+	sqlInsert := `INSERT INTO users (username, email, hashed_pass, status, role) VALUES ` +
+		strings.Join(values, ", ") + ` ON CONFLICT DO NOTHING RETURNING lower(username), user_id`
+
+	rows, err := tx.QueryContext(ctx, sqlInsert, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]string, len(users))
+
+	for rows.Next() {
+		var userName, userID string
+		if err = rows.Scan(&userName, &userID); err != nil {
+			return nil, err
+		}
+
+		inserted[userName] = userID
+	}
+
+	return inserted, rows.Err()
+}
@@ -15,6 +15,14 @@ type User struct {
 	HashedPass string
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+
+	// EmailVerifiedAt and ErasedAt are only set by FindByUUID and Export, ErasedAt once the user's personal data
+	// is erased, its username and email are pseudonyms then.
+	EmailVerifiedAt sql.NullTime
+	ErasedAt        sql.NullTime
+
+	// Profile is only set when listing users with embedded profiles, nil if the user has none.
+	Profile *Profile
 }
 
 type Profile struct {
@@ -24,13 +32,47 @@ type Profile struct {
 	Address   sql.NullString
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// Version is incremented on every update, it's the ETag of the profile.
+	Version int64
+}
+
+// Columns users can be listed by, FindUsersOptions.AfterValue holds the last value of the sorted column.
+const (
+	SortByID        = "id"
+	SortByCreatedAt = "createdAt"
+	SortByUsername  = "username"
+	SortByEmail     = "email"
+)
+
+// FindUsersOptions selects a page of users, zero valued filters don't filter.
+// Pages are keyset paginated over users.id, after the user with AfterID if it's set.
+type FindUsersOptions struct {
+	Status         string
+	Role           string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	UsernamePrefix string
+	EmailPrefix    string
+
+	SortBy       string
+	Desc         bool
+	AfterID      int64
+	AfterValue   string
+	Limit        int
+	EmbedProfile bool
 }
 
-// AuthorizedUser will be used to map jwt claims to this struct
+// AuthorizedUser will be used to map jwt claims to this struct,
+// for service client tokens only ClientID is set, for API keys APIKeyID is set too.
 type AuthorizedUser struct {
 	UserID   string
 	UserName string
 	Email    string
 	Status   string
 	Role     string
+	ClientID string
+	APIKeyID string
+	// ImpersonatorID is the admin acting as the user with an impersonation token, ImpersonationID its session.
+	ImpersonatorID  string
+	ImpersonationID string
 }
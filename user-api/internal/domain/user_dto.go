@@ -2,6 +2,8 @@ package domain
 
 import (
 	"time"
+
+	"github.com/ashtishad/instabid-wallet/lib/audit"
 )
 
 type UserRespDTO struct {
@@ -12,6 +14,8 @@ type UserRespDTO struct {
 	Role      string    `binding:"required" json:"role"`
 	CreatedAt time.Time `binding:"required" json:"createdAt"`
 	UpdatedAt time.Time `binding:"required" json:"updatedAt"`
+
+	Profile *ProfileRespDTO `binding:"-" json:"profile,omitempty"`
 }
 
 type NewUserReqDTO struct {
@@ -25,6 +29,13 @@ type NewUserReqDTO struct {
 	Role   string `binding:"-" json:"role"`
 }
 
+// UpdateUserReqDTO replaces the username and email of a user with PUT, with PATCH empty fields are unchanged.
+// Statuses are changed by admins with the admin-api.
+type UpdateUserReqDTO struct {
+	UserName string `binding:"-" json:"userName"`
+	Email    string `binding:"-" json:"email"`
+}
+
 type ProfileRespDTO struct {
 	FirstName string    `binding:"required" json:"firstName"`
 	LastName  string    `binding:"required" json:"lastName"`
@@ -32,6 +43,9 @@ type ProfileRespDTO struct {
 	Address   string    `binding:"-"        json:"address,omitempty"`
 	CreatedAt time.Time `binding:"-"        json:"createdAt"`
 	UpdatedAt time.Time `binding:"-"        json:"updatedAt"`
+
+	// Version is sent as the ETag header instead.
+	Version int64 `binding:"-" json:"-"`
 }
 
 type NewProfileReqDTO struct {
@@ -40,3 +54,86 @@ type NewProfileReqDTO struct {
 	Gender    string `binding:"required" json:"gender"`
 	Address   string `binding:"-"        json:"address,omitempty"`
 }
+
+// UpdateProfileReqDTO replaces a profile with PUT, where first name, last name and gender are required
+// and a missing address is cleared. With PATCH missing fields are unchanged, an empty address clears it.
+type UpdateProfileReqDTO struct {
+	FirstName *string `binding:"-" json:"firstName"`
+	LastName  *string `binding:"-" json:"lastName"`
+	Gender    *string `binding:"-" json:"gender"`
+	Address   *string `binding:"-" json:"address"`
+}
+
+type ResendVerificationReqDTO struct {
+	Email string `binding:"required" json:"email"`
+}
+
+// FindUsersReqDTO are the query parameters of listing users, sort is one of id, createdAt, username or email,
+// prefixed with "-" for descending order, embed=profile includes user profiles.
+type FindUsersReqDTO struct {
+	Limit          int    `form:"limit"`
+	Cursor         string `form:"cursor"`
+	Status         string `form:"status"`
+	Role           string `form:"role"`
+	CreatedAfter   string `form:"createdAfter"`
+	CreatedBefore  string `form:"createdBefore"`
+	UsernamePrefix string `form:"usernamePrefix"`
+	EmailPrefix    string `form:"emailPrefix"`
+	Sort           string `form:"sort"`
+	Embed          string `form:"embed"`
+}
+
+type UsersPageRespDTO struct {
+	Users      []UserRespDTO `json:"users"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// ExportUserRespDTO is a user as exported, with when its email was verified and its personal data erased.
+type ExportUserRespDTO struct {
+	UserRespDTO
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	ErasedAt        *time.Time `json:"erasedAt,omitempty"`
+}
+
+// ExportRespDTO is everything held about a user, AuditLog has the audit log entries the user acted in
+// or was the target of.
+type ExportRespDTO struct {
+	ExportedAt      time.Time         `json:"exportedAt"`
+	User            ExportUserRespDTO `json:"user"`
+	Profile         *ProfileRespDTO   `json:"profile"`
+	Sessions        []Session         `json:"sessions"`
+	AuthEvents      []AuthEvent       `json:"authEvents"`
+	AdminActions    []AdminAction     `json:"adminActions"`
+	ErasureRequests []ErasureRequest  `json:"erasureRequests"`
+	AuditLog        []audit.Entry     `json:"auditLog"`
+}
+
+// ErasureReqDTO requests the erasure of the personal data of a user, with an optional reason.
+type ErasureReqDTO struct {
+	Reason string `binding:"-" json:"reason"`
+}
+
+// ImportUsersReqDTO are the query parameters of a bulk import, the users are read from the request body in format,
+// csv or jsonl. A dry run validates every line and reports what would be imported, without importing anything.
+type ImportUsersReqDTO struct {
+	Format string `form:"format"`
+	DryRun bool   `form:"dryRun"`
+}
+
+// ImportLineErrorDTO is why a line of a bulk import wasn't imported, lines are numbered from 1.
+type ImportLineErrorDTO struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReportRespDTO is the result of a bulk import, Total counts the users read, Imported those imported,
+// or that would be in a dry run, and Failed those that weren't, with why in Errors.
+// Error tells why the import stopped before the end of the input, if it did.
+type ImportReportRespDTO struct {
+	DryRun   bool                 `json:"dryRun"`
+	Total    int                  `json:"total"`
+	Imported int                  `json:"imported"`
+	Failed   int                  `json:"failed"`
+	Errors   []ImportLineErrorDTO `json:"errors"`
+	Error    string               `json:"error,omitempty"`
+}
@@ -9,8 +9,20 @@ import (
 type UserRepository interface {
 	Insert(ctx context.Context, u User) (*User, lib.APIError)
 	InsertProfile(ctx context.Context, uuid string, up Profile) (*Profile, lib.APIError)
+	FindByEmail(ctx context.Context, email string) (*User, lib.APIError)
+	VerifyEmail(ctx context.Context, uuid string, email string) (*User, lib.APIError)
+	FindAll(ctx context.Context, opts FindUsersOptions) ([]User, lib.APIError)
+	FindByUUID(ctx context.Context, uuid string) (*User, lib.APIError)
+	Update(ctx context.Context, uuid string, u User) (*User, lib.APIError)
+	SoftDelete(ctx context.Context, uuid string) lib.APIError
+	FindProfile(ctx context.Context, uuid string) (*Profile, lib.APIError)
+	UpdateProfile(ctx context.Context, uuid string, up Profile, version int64) (*Profile, lib.APIError)
+	Export(ctx context.Context, uuid string) (*Export, lib.APIError)
+	InsertErasureRequest(ctx context.Context, er ErasureRequest) (*ErasureRequest, lib.APIError)
+	FindErasureRequest(ctx context.Context, uuid string) (*ErasureRequest, lib.APIError)
+	CancelErasureRequest(ctx context.Context, uuid string) (*ErasureRequest, lib.APIError)
+	InsertBatch(ctx context.Context, users []User, dryRun bool) (map[string]bool, lib.APIError)
 
-	findByUUID(ctx context.Context, uuid string) (*User, lib.APIError)
 	findProfile(ctx context.Context, id int64) (*Profile, lib.APIError)
 	checkExists(ctx context.Context, email, username string) lib.APIError
 }
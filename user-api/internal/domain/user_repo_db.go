@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/ashtishad/instabid-wallet/lib"
 )
@@ -36,7 +37,7 @@ func (d *UserRepoDB) Insert(ctx context.Context, u User) (*User, lib.APIError) {
 		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
 	}
 
-	defer rollbackOnError(tx, &err, d.l)
+	defer lib.RollbackOnError(tx, &err, d.l)
 
 	if apiErr := d.checkExists(ctx, u.Email, u.UserName); apiErr != nil {
 		return nil, apiErr
@@ -53,7 +54,7 @@ func (d *UserRepoDB) Insert(ctx context.Context, u User) (*User, lib.APIError) {
 		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
 	}
 
-	return d.findByUUID(ctx, u.UserID)
+	return d.FindByUUID(ctx, u.UserID)
 }
 
 // InsertProfile takes uuid as string of user, and inserts profile to that specific user.
@@ -71,7 +72,7 @@ func (d *UserRepoDB) InsertProfile(ctx context.Context, uuid string, up Profile)
 		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
 	}
 
-	defer rollbackOnError(tx, &err, d.l)
+	defer lib.RollbackOnError(tx, &err, d.l)
 
 	sqlInsertProfile := `INSERT into user_profiles (user_id, first_name, last_name, gender, address) 
 						values ($1, $2, $3, $4, $5)`
@@ -107,6 +108,338 @@ func (d *UserRepoDB) InsertProfile(ctx context.Context, uuid string, up Profile)
 	return d.findProfile(ctx, id)
 }
 
+// FindByEmail retrieves a user by email, returns 404 if the user is not found.
+func (d *UserRepoDB) FindByEmail(ctx context.Context, email string) (*User, lib.APIError) {
+	sqlFindByEmail := `SELECT user_id, username, email, status, role, created_at, updated_at
+					  from users where email = $1`
+
+	var u User
+	row := d.db.QueryRowContext(ctx, sqlFindByEmail, email)
+
+	err := row.Scan(&u.UserID, &u.UserName, &u.Email, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by email")
+		}
+
+		d.l.ErrorContext(ctx, "failed to find user by email", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &u, nil
+}
+
+// VerifyEmail marks the email of a user as verified if it's still the given one and activates unverified users,
+// users an admin deactivated stay inactive. Verifying again is a no-op, returns 400 if the email doesn't match.
+func (d *UserRepoDB) VerifyEmail(ctx context.Context, uuid string, email string) (*User, lib.APIError) {
+	sqlVerifyEmail := `UPDATE users SET email_verified_at = coalesce(email_verified_at, now()),
+						status = CASE WHEN status = 'unverified' THEN 'active'::user_status ELSE status END,
+						updated_at = now()
+					  WHERE user_id::text = $1 AND email = $2 AND status <> 'deleted' RETURNING user_id`
+
+	var userID string
+
+	err := d.db.QueryRowContext(ctx, sqlVerifyEmail, uuid, email).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.BadRequestError("verification token is invalid or has expired")
+		}
+
+		d.l.ErrorContext(ctx, "failed to verify email", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return d.FindByUUID(ctx, userID)
+}
+
+// Update changes the username and email of a user, empty fields are left unchanged.
+// A changed email must be verified again, its verification is reset and an active user becomes unverified.
+// Returns 409 if the username or email is taken, also by a concurrent update, 404 for unknown or deleted users.
+func (d *UserRepoDB) Update(ctx context.Context, uuid string, u User) (*User, lib.APIError) {
+	sqlUpdate := `UPDATE users SET username = coalesce(nullif($2::text, ''), username),
+					email = coalesce(nullif($3::text, ''), email),
+					status = CASE WHEN $3::text <> '' AND $3::citext <> email AND status = 'active'
+								  THEN 'unverified'::user_status
+								  ELSE status END,
+					email_verified_at = CASE WHEN $3::text <> '' AND $3::citext <> email THEN NULL
+											 ELSE email_verified_at END
+				  WHERE user_id = $1 AND status <> 'deleted' RETURNING user_id`
+
+	if apiErr := d.checkExists(ctx, u.Email, u.UserName); apiErr != nil {
+		return nil, apiErr
+	}
+
+	var userID string
+
+	err := d.db.QueryRowContext(ctx, sqlUpdate, uuid, u.UserName, u.Email).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user not found by uuid")
+		}
+
+		// taken meanwhile, after checkExists
+		if lib.IsUniqueViolation(err) {
+			return nil, lib.ConflictError("username or email is taken")
+		}
+
+		d.l.ErrorContext(ctx, "failed to update user", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return d.FindByUUID(ctx, userID)
+}
+
+// SoftDelete marks a user as deleted and ends every session of the user, refresh tokens are revoked
+// and access tokens issued until now are rejected once the revocation lists sync.
+// The user's row is kept, so its username and email stay taken. Returns 404 if already deleted.
+func (d *UserRepoDB) SoftDelete(ctx context.Context, uuid string) lib.APIError {
+	sqlDelete := `UPDATE users SET status = 'deleted' WHERE user_id = $1 AND status <> 'deleted'`
+	sqlRevokeRefreshTokens := `UPDATE refresh_tokens SET revoked_at = now()
+							   WHERE user_id = $1 AND revoked_at IS NULL`
+	sqlRevokeAccessTokens := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, now())
+							  ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXBegin, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	defer lib.RollbackOnError(tx, &err, d.l)
+
+	var res sql.Result
+	if res, err = tx.ExecContext(ctx, sqlDelete, uuid); err != nil {
+		d.l.ErrorContext(ctx, "failed to delete user", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	var ra int64
+	if ra, err = res.RowsAffected(); err != nil {
+		d.l.ErrorContext(ctx, "unable to get rows affected", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if ra == 0 {
+		err = errors.New("user not found")
+		return lib.NotFoundError("user not found by uuid")
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlRevokeRefreshTokens, uuid); err != nil {
+		d.l.ErrorContext(ctx, "failed to revoke refresh tokens", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlRevokeAccessTokens, uuid); err != nil {
+		d.l.ErrorContext(ctx, "failed to revoke access tokens", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		d.l.ErrorContext(ctx, lib.ErrTXCommit, "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return nil
+}
+
+// FindProfile retrieves the profile of a user by the user's uuid, returns 404 if the user is deleted
+// or has no profile.
+func (d *UserRepoDB) FindProfile(ctx context.Context, uuid string) (*Profile, lib.APIError) {
+	sqlFindProfile := `SELECT p.first_name, p.last_name, p.gender, p.address, p.created_at, p.updated_at, p.version
+					  FROM user_profiles p JOIN users u ON u.id = p.user_id
+					  WHERE u.user_id = $1 AND u.status <> 'deleted'`
+
+	var up Profile
+	row := d.db.QueryRowContext(ctx, sqlFindProfile, uuid)
+
+	err := row.Scan(&up.FirstName, &up.LastName, &up.Gender, &up.Address, &up.CreatedAt, &up.UpdatedAt, &up.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, lib.NotFoundError("user profile not found by user id")
+		}
+
+		d.l.ErrorContext(ctx, "failed to find user profile", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &up, nil
+}
+
+// UpdateProfile replaces the profile of a user if it's still at the given version, and increments the version.
+// Returns 412 if the profile was changed meanwhile, 404 if the user is deleted or has no profile.
+func (d *UserRepoDB) UpdateProfile(ctx context.Context, uuid string, up Profile, version int64) (*Profile,
+	lib.APIError) {
+	sqlUpdateProfile := `UPDATE user_profiles p SET first_name = $2, last_name = $3, gender = $4, address = $5,
+							version = p.version + 1
+						FROM users u
+						WHERE u.id = p.user_id AND u.user_id = $1 AND u.status <> 'deleted' AND p.version = $6
+						RETURNING p.first_name, p.last_name, p.gender, p.address, p.created_at, p.updated_at,
+							p.version`
+
+	var res Profile
+	row := d.db.QueryRowContext(ctx, sqlUpdateProfile, uuid, up.FirstName, up.LastName, up.Gender, up.Address,
+		version)
+
+	err := row.Scan(&res.FirstName, &res.LastName, &res.Gender, &res.Address, &res.CreatedAt, &res.UpdatedAt,
+		&res.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, apiErr := d.FindProfile(ctx, uuid); apiErr != nil {
+				return nil, apiErr
+			}
+
+			return nil, lib.PreconditionFailedError("profile was changed since it was read, fetch it again")
+		}
+
+		d.l.ErrorContext(ctx, "failed to update user profile", "err", err.Error())
+
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return &res, nil
+}
+
+// FindAll returns a page of users matching opts, sorted by opts.SortBy then id, with up to opts.Limit+1 users,
+// so the caller can tell whether there's a next page. Username and email prefixes match case insensitively.
+func (d *UserRepoDB) FindAll(ctx context.Context, opts FindUsersOptions) ([]User, lib.APIError) {
+	sortColumns := map[string]string{
+		SortByID:        "u.id",
+		SortByCreatedAt: "u.created_at",
+		SortByUsername:  "u.username",
+		SortByEmail:     "u.email",
+	}
+	sortCasts := map[string]string{
+		SortByCreatedAt: "::timestamptz",
+		SortByUsername:  "::citext",
+		SortByEmail:     "::citext",
+	}
+
+	column, ok := sortColumns[opts.SortBy]
+	if !ok {
+		column = "u.id"
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+
+	where := func(format string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if opts.Status != "" {
+		where("u.status = %s", opts.Status)
+	}
+
+	if opts.Role != "" {
+		where("u.role = %s", opts.Role)
+	}
+
+	if !opts.CreatedAfter.IsZero() {
+		where("u.created_at >= %s", opts.CreatedAfter)
+	}
+
+	if !opts.CreatedBefore.IsZero() {
+		where("u.created_at < %s", opts.CreatedBefore)
+	}
+
+	if opts.UsernamePrefix != "" {
+		where(`lower(u.username::text) LIKE %s ESCAPE '\'`, likePrefix(opts.UsernamePrefix))
+	}
+
+	if opts.EmailPrefix != "" {
+		where(`lower(u.email::text) LIKE %s ESCAPE '\'`, likePrefix(opts.EmailPrefix))
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if opts.AfterID > 0 {
+		if column == "u.id" {
+			where("u.id "+comparison+" %s", opts.AfterID)
+		} else {
+			where("("+column+", u.id) "+comparison+" (%s"+sortCasts[opts.SortBy]+", %s)", opts.AfterValue, opts.AfterID)
+		}
+	}
+
+	sqlFindAll := `SELECT u.id, u.user_id, u.username, u.email, u.status, u.role, u.created_at, u.updated_at`
+	if opts.EmbedProfile {
+		sqlFindAll += `, p.first_name, p.last_name, p.gender, p.address, p.created_at, p.updated_at
+					   FROM users u LEFT JOIN user_profiles p ON p.user_id = u.id`
+	} else {
+		sqlFindAll += ` FROM users u`
+	}
+
+	if len(conditions) > 0 {
+		sqlFindAll += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, opts.Limit+1)
+	sqlFindAll += fmt.Sprintf(" ORDER BY %s %s, u.id %s LIMIT $%d", column, direction, direction, len(args))
+
+	rows, err := d.db.QueryContext(ctx, sqlFindAll, args...)
+	if err != nil {
+		d.l.ErrorContext(ctx, "unable to query users", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, opts.Limit+1)
+
+	for rows.Next() {
+		var u User
+
+		dest := []any{&u.ID, &u.UserID, &u.UserName, &u.Email, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt}
+
+		var (
+			firstName, lastName, gender sql.NullString
+			address                     sql.NullString
+			createdAt, updatedAt        sql.NullTime
+		)
+
+		if opts.EmbedProfile {
+			dest = append(dest, &firstName, &lastName, &gender, &address, &createdAt, &updatedAt)
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			d.l.ErrorContext(ctx, "unable to scan user", "err", err.Error())
+			return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+		}
+
+		if firstName.Valid {
+			u.Profile = &Profile{FirstName: firstName.String, LastName: lastName.String, Gender: gender.String,
+				Address: address, CreatedAt: createdAt.Time, UpdatedAt: updatedAt.Time}
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		d.l.ErrorContext(ctx, "unable to iterate users", "err", err.Error())
+		return nil, lib.InternalServerError(lib.ErrUnexpectedDatabase, err)
+	}
+
+	return users, nil
+}
+
+// likePrefix returns a LIKE pattern matching strings starting with prefix, lowercased, wildcards in it are escaped.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix)) + "%"
+}
+
 // findIDByUUID retrieves user id int64 from user uuid
 // returns 404 and 500 if error happens.
 func (d *UserRepoDB) findIDByUUID(ctx context.Context, userID string) (int64, lib.APIError) {
@@ -127,16 +460,17 @@ func (d *UserRepoDB) findIDByUUID(ctx context.Context, userID string) (int64, li
 	return id, nil
 }
 
-// findByUUID retrieves a user by their UUID from the database.
+// FindByUUID retrieves a user by their UUID from the database, deleted users included.
 // If the user is not found, a NotFoundError is returned, Any other errors result in an InternalServerError.
-func (d *UserRepoDB) findByUUID(ctx context.Context, uuid string) (*User, lib.APIError) {
-	sqlFindByUUID := `SELECT user_id, username, email, status, role, created_at, updated_at 
-					 from users where user_id= $1`
+func (d *UserRepoDB) FindByUUID(ctx context.Context, uuid string) (*User, lib.APIError) {
+	sqlFindByUUID := `SELECT id, user_id, username, email, status, role, created_at, updated_at, email_verified_at,
+					 erased_at from users where user_id = $1`
 
 	var u User
 	row := d.db.QueryRowContext(ctx, sqlFindByUUID, uuid)
 
-	err := row.Scan(&u.UserID, &u.UserName, &u.Email, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	err := row.Scan(&u.ID, &u.UserID, &u.UserName, &u.Email, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt,
+		&u.EmailVerifiedAt, &u.ErasedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, lib.NotFoundError("user not found by uuid")
@@ -151,13 +485,13 @@ func (d *UserRepoDB) findByUUID(ctx context.Context, uuid string) (*User, lib.AP
 // findProfile retrieves a user profile by their user id from the database.
 // If the user profile is not found, a NotFoundError is returned, Any other errors result in an InternalServerError.
 func (d *UserRepoDB) findProfile(ctx context.Context, id int64) (*Profile, lib.APIError) {
-	sqlFindByUUID := `SELECT  first_name, last_name, gender, address, created_at, updated_at
+	sqlFindByUUID := `SELECT  first_name, last_name, gender, address, created_at, updated_at, version
 					 from user_profiles where user_id= $1`
 
 	var up Profile
 	row := d.db.QueryRowContext(ctx, sqlFindByUUID, id)
 
-	err := row.Scan(&up.FirstName, &up.LastName, &up.Gender, &up.Address, &up.CreatedAt, &up.UpdatedAt)
+	err := row.Scan(&up.FirstName, &up.LastName, &up.Gender, &up.Address, &up.CreatedAt, &up.UpdatedAt, &up.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, lib.NotFoundError("user profile not found by user id")
@@ -196,13 +530,3 @@ func (d *UserRepoDB) checkExists(ctx context.Context, email, username string) li
 		return nil
 	}
 }
-
-// rollbackOnError attempts to roll back the transaction if an error is present.
-// It logs a warning if the rollback itself fails.
-func rollbackOnError(tx *sql.Tx, err *error, l *slog.Logger) {
-	if *err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			l.Warn(lib.ErrTXRollback, "rbErr", rbErr)
-		}
-	}
-}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/hashpass"
+	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/userimport"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/utils"
+)
+
+// ImportUsers imports the users read from r in the format of req, validated like new users, ImportChunkSize
+// users at a time, a transaction each. Users are unverified unless another status is given, no verification
+// links are sent, users can request them. Lines that can't be imported are reported and skipped,
+// a username or email already taken included, so an import stopped halfway can be run again as it is.
+// If the input can't be read to the end, the users read so far are imported and the report tells why it stopped,
+// so does it if inserting a chunk fails, with the users imported before it. Admins and moderators can't be imported.
+// Every chunk is recorded in the audit log with the ids of its users.
+func (s *DefaultUserService) ImportUsers(ctx context.Context, r io.Reader,
+	req domain.ImportUsersReqDTO) (*domain.ImportReportRespDTO, lib.APIError) {
+	reader, err := userimport.NewReader(r, req.Format)
+	if err != nil {
+		return nil, lib.BadRequestError(err.Error())
+	}
+
+	imp := importer{s: s, dryRun: req.DryRun, seen: make(map[string]int),
+		report: domain.ImportReportRespDTO{DryRun: req.DryRun, Errors: []domain.ImportLineErrorDTO{}}}
+
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			imp.report.Error = "the input couldn't be read to the end: " + err.Error()
+			break
+		}
+
+		if imp.report.Total == utils.MaxImportLines {
+			imp.report.Error = fmt.Sprintf("imports can't exceed %d users, the rest of the input was not read",
+				utils.MaxImportLines)
+
+			break
+		}
+
+		if apiErr := imp.add(ctx, rec); apiErr != nil {
+			imp.stop(ctx, apiErr)
+			break
+		}
+	}
+
+	// the chunk is empty if the import stopped on an error
+	if apiErr := imp.flush(ctx); apiErr != nil {
+		imp.stop(ctx, apiErr)
+	}
+
+	// lines are only known to fail on insert once their chunk is inserted, after later lines failed validation
+	slices.SortStableFunc(imp.report.Errors, func(a, b domain.ImportLineErrorDTO) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+
+	if !req.DryRun {
+		s.l.InfoContext(ctx, "users imported", "total", imp.report.Total, "imported", imp.report.Imported,
+			"failed", imp.report.Failed)
+	}
+
+	return &imp.report, nil
+}
+
+// pendingUser is a valid user of an import waiting to be inserted with the rest of its chunk.
+type pendingUser struct {
+	line     int
+	user     domain.User
+	password string
+}
+
+// importer validates the users of an import and inserts them a chunk at a time.
+type importer struct {
+	s      *DefaultUserService
+	dryRun bool
+	report domain.ImportReportRespDTO
+	// seen holds the line of every username and email read, by "username:" or "email:" and its lowercase value
+	seen  map[string]int
+	chunk []pendingUser
+}
+
+// add validates a user and adds it to the chunk, it's reported as failed if it's invalid,
+// or uses the username or email of a line before it.
+func (imp *importer) add(ctx context.Context, rec userimport.Record) lib.APIError {
+	imp.report.Total++
+
+	if rec.Err != nil {
+		imp.fail(rec.Line, rec.Err.Error())
+		return nil
+	}
+
+	if apiErr := utils.ValidateCreateUserInput(rec.User); apiErr != nil {
+		imp.fail(rec.Line, apiErr.Error())
+		return nil
+	}
+
+	// elevated roles are granted by admins one user at a time, with admin-api
+	if rec.User.Role == utils.UserRoleAdmin || rec.User.Role == utils.UserRoleModerator {
+		imp.fail(rec.Line, "admin and moderator users can't be imported")
+		return nil
+	}
+
+	req := rec.User
+	if violations := imp.s.passwordPolicy.Check(ctx, req.Password, req.UserName, req.Email); len(violations) > 0 {
+		imp.fail(rec.Line, "password doesn't meet the password policy: "+violations.Error())
+		return nil
+	}
+
+	if msg := imp.repeated(rec.Line, strings.ToLower(req.UserName), strings.ToLower(req.Email)); msg != "" {
+		imp.fail(rec.Line, msg)
+		return nil
+	}
+
+	if req.Status == "" {
+		req.Status = utils.UserStatusUnverified
+	}
+
+	if req.Role == "" {
+		req.Role = utils.UserRoleUser
+	}
+
+	u := domain.User{
+		UserName: strings.ToLower(req.UserName),
+		Email:    strings.ToLower(req.Email),
+		Status:   req.Status,
+		Role:     req.Role,
+	}
+
+	imp.chunk = append(imp.chunk, pendingUser{line: rec.Line, user: u, password: req.Password})
+
+	if len(imp.chunk) == utils.ImportChunkSize {
+		return imp.flush(ctx)
+	}
+
+	return nil
+}
+
+// repeated records the username and email of a line, it returns why the line can't be imported
+// if a line before it has the same username or email.
+func (imp *importer) repeated(line int, userName string, email string) string {
+	if first, ok := imp.seen["username:"+userName]; ok {
+		return fmt.Sprintf("username is already used on line %d", first)
+	}
+
+	if first, ok := imp.seen["email:"+email]; ok {
+		return fmt.Sprintf("email is already used on line %d", first)
+	}
+
+	imp.seen["username:"+userName] = line
+	imp.seen["email:"+email] = line
+
+	return ""
+}
+
+// flush inserts the users of the chunk, those whose username or email is taken are reported as failed.
+// Passwords aren't hashed in a dry run, nothing is inserted.
+func (imp *importer) flush(ctx context.Context) lib.APIError {
+	if len(imp.chunk) == 0 {
+		return nil
+	}
+
+	if !imp.dryRun {
+		if apiErr := hashPasswords(ctx, imp.chunk, imp.s.l); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	users := make([]domain.User, len(imp.chunk))
+	for i := range imp.chunk {
+		users[i] = imp.chunk[i].user
+	}
+
+	inserted, apiErr := imp.s.repo.InsertBatch(ctx, users, imp.dryRun)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	for _, p := range imp.chunk {
+		if inserted[p.user.UserName] {
+			imp.report.Imported++
+		} else {
+			imp.fail(p.line, "username or email already exists")
+		}
+	}
+
+	imp.chunk = imp.chunk[:0]
+
+	return nil
+}
+
+// stop ends an import on an error, the users of the chunk being inserted aren't imported,
+// the report keeps the users imported before it.
+func (imp *importer) stop(ctx context.Context, apiErr lib.APIError) {
+	imp.s.l.ErrorContext(ctx, "user import stopped", "err", apiErr.WithCauses(), "imported", imp.report.Imported)
+
+	imp.report.Error = fmt.Sprintf("the import stopped on an error after %d users were imported: %s",
+		imp.report.Imported, apiErr.Error())
+	imp.chunk = imp.chunk[:0]
+}
+
+func (imp *importer) fail(line int, msg string) {
+	imp.report.Failed++
+	imp.report.Errors = append(imp.report.Errors, domain.ImportLineErrorDTO{Line: line, Error: msg})
+}
+
+// hashPasswords hashes the passwords of the users, as many at once as there are CPUs.
+func hashPasswords(ctx context.Context, pending []pendingUser, l *slog.Logger) lib.APIError {
+	errs := make([]lib.APIError, len(pending))
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	var wg sync.WaitGroup
+
+	for i := range pending {
+		wg.Add(1)
+
+		sem <- struct{}{}
+
+		go func(p *pendingUser, apiErr *lib.APIError) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			p.user.HashedPass, *apiErr = hashpass.Generate(ctx, p.password, l)
+		}(&pending[i], &errs[i])
+	}
+
+	wg.Wait()
+
+	for _, apiErr := range errs {
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return nil
+}
@@ -3,37 +3,99 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/ashtishad/instabid-wallet/lib"
+	"github.com/ashtishad/instabid-wallet/lib/audit"
+	"github.com/ashtishad/instabid-wallet/lib/hashpass"
+	"github.com/ashtishad/instabid-wallet/lib/notifier"
+	"github.com/ashtishad/instabid-wallet/lib/password"
+	"github.com/ashtishad/instabid-wallet/lib/ratelimit"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
-	"github.com/ashtishad/instabid-wallet/user-api/pkg/hashpass"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/cursor"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/emailtoken"
+	"github.com/ashtishad/instabid-wallet/user-api/pkg/etag"
 	"github.com/ashtishad/instabid-wallet/user-api/pkg/utils"
 )
 
 type UserService interface {
 	NewUser(ctx context.Context, req domain.NewUserReqDTO) (*domain.UserRespDTO, lib.APIError)
 	NewProfile(ctx context.Context, uuid string, req domain.NewProfileReqDTO) (*domain.ProfileRespDTO, lib.APIError)
+	VerifyEmail(ctx context.Context, token string) (*domain.UserRespDTO, lib.APIError)
+	ResendVerification(ctx context.Context, req domain.ResendVerificationReqDTO, clientIP string) lib.APIError
+	FindUsers(ctx context.Context, req domain.FindUsersReqDTO) (*domain.UsersPageRespDTO, lib.APIError)
+	FindUser(ctx context.Context, by *domain.AuthorizedUser, uuid string) (*domain.UserRespDTO, lib.APIError)
+	UpdateUser(ctx context.Context, uuid string, req domain.UpdateUserReqDTO, replace bool) (*domain.UserRespDTO,
+		lib.APIError)
+	DeleteUser(ctx context.Context, uuid string) lib.APIError
+	FindProfile(ctx context.Context, uuid string) (*domain.ProfileRespDTO, lib.APIError)
+	UpdateProfile(ctx context.Context, uuid string, ifMatch string, req domain.UpdateProfileReqDTO,
+		replace bool) (*domain.ProfileRespDTO, lib.APIError)
+	ExportUser(ctx context.Context, uuid string) (*domain.ExportRespDTO, lib.APIError)
+	RequestErasure(ctx context.Context, by *domain.AuthorizedUser, uuid string,
+		req domain.ErasureReqDTO) (*domain.ErasureRequest, lib.APIError)
+	FindErasureRequest(ctx context.Context, uuid string) (*domain.ErasureRequest, lib.APIError)
+	CancelErasure(ctx context.Context, uuid string) (*domain.ErasureRequest, lib.APIError)
+	ImportUsers(ctx context.Context, r io.Reader, req domain.ImportUsersReqDTO) (*domain.ImportReportRespDTO,
+		lib.APIError)
+}
+
+// AuditLog records changes of users and finds the entries about them for their data exports.
+type AuditLog interface {
+	audit.Log
+	audit.SubjectFinder
 }
 
 type DefaultUserService struct {
-	repo domain.UserRepository
-	l    *slog.Logger
+	repo               domain.UserRepository
+	notifier           notifier.Notifier
+	verificationSecret []byte
+	passwordPolicy     password.Policy
+	emailRate          *ratelimit.Limiter
+	ipRate             *ratelimit.Limiter
+	auditLog           AuditLog
+	l                  *slog.Logger
 }
 
-func NewUserService(repo domain.UserRepository, l *slog.Logger) *DefaultUserService {
-	return &DefaultUserService{repo: repo, l: l}
+// NewUserService returns a user service sending verification links through n,
+// signed with verificationSecret, passwords of new users must meet passwordPolicy.
+// Changes of users and profiles are recorded in auditLog.
+func NewUserService(repo domain.UserRepository, n notifier.Notifier, verificationSecret []byte,
+	passwordPolicy password.Policy, auditLog AuditLog, l *slog.Logger) *DefaultUserService {
+	return &DefaultUserService{
+		repo:               repo,
+		notifier:           n,
+		verificationSecret: verificationSecret,
+		passwordPolicy:     passwordPolicy,
+		emailRate:          ratelimit.New(utils.ResendVerificationPerEmail, utils.ResendVerificationWindow),
+		ipRate:             ratelimit.New(utils.ResendVerificationPerIP, utils.ResendVerificationWindow),
+		auditLog:           auditLog,
+		l:                  l,
+	}
 }
 
+// NewUser creates a user, unless it's created inactive it's unverified and gets an email verification link.
+// A failure to send the link doesn't fail the registration, the link can be resent.
 func (s *DefaultUserService) NewUser(ctx context.Context,
 	req domain.NewUserReqDTO) (*domain.UserRespDTO, lib.APIError) {
 	if apiErr := utils.ValidateCreateUserInput(req); apiErr != nil {
 		return nil, apiErr
 	}
 
+	if violations := s.passwordPolicy.Check(ctx, req.Password, req.UserName, req.Email); len(violations) > 0 {
+		return nil, lib.ValidationError("password doesn't meet the password policy", violations)
+	}
+
 	if req.Status == "" {
-		req.Status = utils.UserStatusActive
+		req.Status = utils.UserStatusUnverified
 	}
 
 	if req.Role == "" {
@@ -58,17 +120,83 @@ func (s *DefaultUserService) NewUser(ctx context.Context,
 		return nil, err
 	}
 
-	userDTO := domain.UserRespDTO{
-		UserID:    user.UserID,
-		UserName:  user.UserName,
-		Email:     user.Email,
-		Status:    user.Status,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+	if user.Status == utils.UserStatusUnverified {
+		if apiErr := s.sendVerification(ctx, user); apiErr != nil {
+			s.l.ErrorContext(ctx, "user created but the verification link was not sent", "err", apiErr.WithCauses(),
+				"userId", user.UserID)
+		}
+	}
+
+	return newUserRespDTO(user), nil
+}
+
+// VerifyEmail confirms the email a verification token was sent to and activates the unverified user.
+func (s *DefaultUserService) VerifyEmail(ctx context.Context, token string) (*domain.UserRespDTO, lib.APIError) {
+	userID, email, err := emailtoken.Parse(s.verificationSecret, token)
+	if err != nil {
+		return nil, lib.BadRequestError(err.Error())
+	}
+
+	user, apiErr := s.repo.VerifyEmail(ctx, userID, email)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	return &userDTO, err
+	return newUserRespDTO(user), nil
+}
+
+// ResendVerification sends a new verification link to an unverified user found by email.
+// To avoid revealing which accounts exist, it succeeds without sending anything for unknown or verified users.
+// Requests are rate limited per email and per client ip.
+func (s *DefaultUserService) ResendVerification(ctx context.Context, req domain.ResendVerificationReqDTO,
+	clientIP string) lib.APIError {
+	if err := lib.ValidateEmail(req.Email); err != nil {
+		return lib.BadRequestError(err.Error())
+	}
+
+	email := strings.ToLower(req.Email)
+
+	if !s.emailRate.Allow(email) || !s.ipRate.Allow(clientIP) {
+		return lib.RateLimitError("too many verification requests, try again later")
+	}
+
+	user, apiErr := s.repo.FindByEmail(ctx, email)
+	if apiErr != nil {
+		if apiErr.Code() == http.StatusNotFound {
+			return nil
+		}
+
+		return apiErr
+	}
+
+	if user.Status != utils.UserStatusUnverified {
+		return nil
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+// sendVerification mails the user a link with a signed token, earlier links stay valid until they expire.
+func (s *DefaultUserService) sendVerification(ctx context.Context, user *domain.User) lib.APIError {
+	token, err := emailtoken.Issue(s.verificationSecret, user.UserID, user.Email, utils.EmailVerificationTokenDuration)
+	if err != nil {
+		s.l.ErrorContext(ctx, "failed issuing verification token", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	msg := notifier.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use the link below to verify your email, it expires in %s.\n%s/verify-email?token=%s",
+			utils.EmailVerificationTokenDuration, os.Getenv("APP_URL"), url.QueryEscape(token)),
+	}
+
+	if err = s.notifier.Notify(ctx, msg); err != nil {
+		s.l.ErrorContext(ctx, "unable to send verification link", "err", err.Error())
+		return lib.InternalServerError(lib.ErrUnexpected, err)
+	}
+
+	return nil
 }
 
 func (s *DefaultUserService) NewProfile(ctx context.Context, uuid string,
@@ -95,17 +223,440 @@ func (s *DefaultUserService) NewProfile(ctx context.Context, uuid string,
 		return nil, apiErr
 	}
 
-	resDto := domain.ProfileRespDTO{
-		FirstName: res.FirstName,
-		LastName:  res.LastName,
-		Gender:    res.Gender,
-		CreatedAt: res.CreatedAt,
-		UpdatedAt: res.UpdatedAt,
+	diff := profileDiff(nil, res)
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionProfileCreate, Diff: diff,
+		Personal: diff.SplitPersonal(profilePersonalFields...)})
+
+	return newProfileRespDTO(res), nil
+}
+
+// FindUsers returns a page of users matching the filters of req, DefaultPageSize users unless a limit is given.
+// The next page starts after the cursor of the previous one, which is only valid for the same sort.
+func (s *DefaultUserService) FindUsers(ctx context.Context,
+	req domain.FindUsersReqDTO) (*domain.UsersPageRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateFindUsersInput(req); apiErr != nil {
+		return nil, apiErr
+	}
+
+	opts, _ := utils.ParseSort(req.Sort)
+	opts.Status = req.Status
+	opts.Role = req.Role
+	opts.CreatedAfter, _ = time.Parse(time.RFC3339, req.CreatedAfter)
+	opts.CreatedBefore, _ = time.Parse(time.RFC3339, req.CreatedBefore)
+	opts.UsernamePrefix = req.UsernamePrefix
+	opts.EmailPrefix = req.EmailPrefix
+	opts.EmbedProfile = req.Embed == utils.EmbedProfile
+
+	opts.Limit = req.Limit
+	if opts.Limit == 0 {
+		opts.Limit = utils.DefaultPageSize
+	}
+
+	if req.Cursor != "" {
+		c, err := cursor.Decode(req.Cursor, req.Sort)
+		if err != nil {
+			return nil, lib.BadRequestError(err.Error())
+		}
+
+		opts.AfterID, opts.AfterValue = c.ID, c.Value
+	}
+
+	users, apiErr := s.repo.FindAll(ctx, opts)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	page := domain.UsersPageRespDTO{Users: make([]domain.UserRespDTO, 0, len(users))}
+
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+		page.NextCursor = cursor.Encode(nextCursor(users[len(users)-1], opts.SortBy, req.Sort))
+	}
+
+	for i := range users {
+		u := newUserRespDTO(&users[i])
+		if users[i].Profile != nil {
+			u.Profile = newProfileRespDTO(users[i].Profile)
+		}
+
+		page.Users = append(page.Users, *u)
+	}
+
+	return &page, nil
+}
+
+// FindUser returns a user by uuid, deleted users are only found by admins.
+func (s *DefaultUserService) FindUser(ctx context.Context, by *domain.AuthorizedUser,
+	uuid string) (*domain.UserRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	user, apiErr := s.repo.FindByUUID(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if user.Status == utils.UserStatusDeleted && by.Role != utils.UserRoleAdmin {
+		return nil, lib.NotFoundError("user not found by uuid")
+	}
+
+	return newUserRespDTO(user), nil
+}
+
+// UpdateUser changes the username or email of a user, replace requires both username and email (PUT),
+// otherwise only the given fields change (PATCH).
+// A changed email must be verified again, the user gets a verification link to the new address.
+func (s *DefaultUserService) UpdateUser(ctx context.Context, uuid string,
+	req domain.UpdateUserReqDTO, replace bool) (*domain.UserRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if apiErr := utils.ValidateUpdateUserInput(req, replace); apiErr != nil {
+		return nil, apiErr
+	}
+
+	current, apiErr := s.repo.FindByUUID(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if current.Status == utils.UserStatusDeleted {
+		return nil, lib.NotFoundError("user not found by uuid")
+	}
+
+	// unchanged fields are left out, so they aren't reported as taken by the user itself
+	var u domain.User
+	if userName := strings.ToLower(req.UserName); userName != current.UserName {
+		u.UserName = userName
+	}
+
+	if email := strings.ToLower(req.Email); email != current.Email {
+		u.Email = email
+	}
+
+	user, apiErr := s.repo.Update(ctx, uuid, u)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	diff := audit.Diff{}
+	diff.Add("userName", current.UserName, user.UserName)
+	diff.Add("email", current.Email, user.Email)
+	diff.Add("status", current.Status, user.Status)
+
+	if len(diff) > 0 {
+		s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionUserUpdate, Diff: diff,
+			Personal: diff.SplitPersonal("userName", "email")})
+	}
+
+	if u.Email != "" {
+		if apiErr = s.sendVerification(ctx, user); apiErr != nil {
+			s.l.ErrorContext(ctx, "email changed but the verification link was not sent", "err", apiErr.WithCauses(),
+				"userId", user.UserID)
+		}
+	}
+
+	return newUserRespDTO(user), nil
+}
+
+// DeleteUser soft deletes a user, its sessions end and it can't log in anymore.
+func (s *DefaultUserService) DeleteUser(ctx context.Context, uuid string) lib.APIError {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return apiErr
+	}
+
+	if apiErr := s.repo.SoftDelete(ctx, uuid); apiErr != nil {
+		return apiErr
+	}
+
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionUserDelete,
+		Diff: audit.Diff{"status": {After: utils.UserStatusDeleted}}})
+
+	return nil
+}
+
+// FindProfile returns the profile of a user, its Version is the ETag to update it with.
+func (s *DefaultUserService) FindProfile(ctx context.Context, uuid string) (*domain.ProfileRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	up, apiErr := s.repo.FindProfile(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	return newProfileRespDTO(up), nil
+}
+
+// UpdateProfile updates the profile of a user if ifMatch, the If-Match header, has its current ETag or is "*".
+// replace requires first name, last name and gender (PUT), otherwise only the given fields change (PATCH).
+// Returns 428 without ifMatch and 412 if the profile was changed since the ETag was read,
+// so concurrent edits can't overwrite each other.
+func (s *DefaultUserService) UpdateProfile(ctx context.Context, uuid string, ifMatch string,
+	req domain.UpdateProfileReqDTO, replace bool) (*domain.ProfileRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	version, err := etag.ParseIfMatch(ifMatch)
+	if err != nil {
+		if errors.Is(err, etag.ErrMissing) {
+			return nil, lib.PreconditionRequiredError(err.Error())
+		}
+
+		return nil, lib.PreconditionFailedError(err.Error())
+	}
+
+	if replace && (req.FirstName == nil || req.LastName == nil || req.Gender == nil) {
+		return nil, lib.BadRequestError("firstName, lastName and gender are required, use PATCH to update some of them")
+	}
+
+	current, apiErr := s.repo.FindProfile(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if version != etag.Any && version != current.Version {
+		return nil, lib.PreconditionFailedError("profile was changed since it was read, fetch it again")
+	}
+
+	// the update is made from the version read, so it still fails if the profile changes before it's saved
+	merged := domain.NewProfileReqDTO{FirstName: current.FirstName, LastName: current.LastName,
+		Gender: current.Gender}
+	if current.Address.Valid && !replace {
+		merged.Address = current.Address.String
+	}
+
+	mergeProfileUpdate(&merged, req)
+
+	if apiErr = utils.ValidateCreateProfileInput(merged); apiErr != nil {
+		return nil, apiErr
+	}
+
+	up := domain.Profile{
+		FirstName: merged.FirstName,
+		LastName:  merged.LastName,
+		Gender:    merged.Gender,
+		Address:   sql.NullString{String: merged.Address, Valid: merged.Address != ""},
+	}
+
+	res, apiErr := s.repo.UpdateProfile(ctx, uuid, up, current.Version)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	diff := profileDiff(current, res)
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionProfileUpdate, Diff: diff,
+		Personal: diff.SplitPersonal(profilePersonalFields...)})
+
+	return newProfileRespDTO(res), nil
+}
+
+// ExportUser returns everything held about a user, for data subject access requests.
+// Exports are recorded in the audit log, as they disclose personal data.
+func (s *DefaultUserService) ExportUser(ctx context.Context, uuid string) (*domain.ExportRespDTO, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	export, apiErr := s.repo.Export(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	entries, apiErr := s.auditLog.FindBySubject(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	res := domain.ExportRespDTO{
+		ExportedAt: time.Now().UTC(),
+		User: domain.ExportUserRespDTO{
+			UserRespDTO:     *newUserRespDTO(export.User),
+			EmailVerifiedAt: nullTimePtr(export.User.EmailVerifiedAt),
+			ErasedAt:        nullTimePtr(export.User.ErasedAt),
+		},
+		Sessions:        export.Sessions,
+		AuthEvents:      export.AuthEvents,
+		AdminActions:    export.AdminActions,
+		ErasureRequests: export.ErasureRequests,
+		AuditLog:        entries,
+	}
+
+	if export.Profile != nil {
+		res.Profile = newProfileRespDTO(export.Profile)
+	}
+
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionDataExport})
+
+	return &res, nil
+}
+
+// RequestErasure requests the erasure of the personal data of a user, carried out once an admin approves it
+// and the cooling-off period after that ends. Returns 409 if the user has an open request or is already erased.
+func (s *DefaultUserService) RequestErasure(ctx context.Context, by *domain.AuthorizedUser, uuid string,
+	req domain.ErasureReqDTO) (*domain.ErasureRequest, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if apiErr := utils.ValidateErasureInput(req); apiErr != nil {
+		return nil, apiErr
+	}
+
+	user, apiErr := s.repo.FindByUUID(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if user.ErasedAt.Valid {
+		return nil, lib.ConflictError("personal data of the user is already erased")
+	}
+
+	er := domain.ErasureRequest{UserID: uuid, Reason: strings.TrimSpace(req.Reason)}
+	if by.UserID != "" {
+		er.RequestedBy = &by.UserID
+	}
+
+	if by.ImpersonatorID != "" {
+		er.ImpersonatedBy = &by.ImpersonatorID
+	}
+
+	res, apiErr := s.repo.InsertErasureRequest(ctx, er)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionErasureCreate,
+		Diff: audit.Diff{"requestId": {After: res.RequestID}, "status": {After: res.Status}}})
+
+	return res, nil
+}
+
+// FindErasureRequest returns the latest erasure request of a user.
+func (s *DefaultUserService) FindErasureRequest(ctx context.Context, uuid string) (*domain.ErasureRequest,
+	lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return s.repo.FindErasureRequest(ctx, uuid)
+}
+
+// CancelErasure cancels the open erasure request of a user, approved requests can be cancelled until
+// their cooling-off period ends.
+func (s *DefaultUserService) CancelErasure(ctx context.Context, uuid string) (*domain.ErasureRequest, lib.APIError) {
+	if apiErr := utils.ValidateUUID(uuid); apiErr != nil {
+		return nil, apiErr
+	}
+
+	current, apiErr := s.repo.FindErasureRequest(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	res, apiErr := s.repo.CancelErasureRequest(ctx, uuid)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	if res.Address.Valid {
-		resDto.Address = res.Address.String
+	s.auditLog.Record(ctx, audit.Entry{Target: uuid, Action: audit.ActionErasureCancel,
+		Diff: audit.Diff{"requestId": {After: res.RequestID}, "status": {Before: current.Status, After: res.Status}}})
+
+	return res, nil
+}
+
+// profilePersonalFields are the fields of a profile diff kept apart from the audit log's hash chain, so they're
+// erased with the user's data, see audit.Diff.SplitPersonal.
+var profilePersonalFields = []string{"firstName", "lastName", "gender", "address"}
+
+// profileDiff returns the changed fields of a profile, before is nil for a new profile.
+func profileDiff(before *domain.Profile, after *domain.Profile) audit.Diff {
+	if before == nil {
+		before = &domain.Profile{}
 	}
 
-	return &resDto, nil
+	diff := audit.Diff{}
+	diff.Add("firstName", before.FirstName, after.FirstName)
+	diff.Add("lastName", before.LastName, after.LastName)
+	diff.Add("gender", before.Gender, after.Gender)
+	diff.Add("address", before.Address.String, after.Address.String)
+
+	return diff
+}
+
+// mergeProfileUpdate sets the fields given in req on profile.
+func mergeProfileUpdate(profile *domain.NewProfileReqDTO, req domain.UpdateProfileReqDTO) {
+	if req.FirstName != nil {
+		profile.FirstName = *req.FirstName
+	}
+
+	if req.LastName != nil {
+		profile.LastName = *req.LastName
+	}
+
+	if req.Gender != nil {
+		profile.Gender = *req.Gender
+	}
+
+	if req.Address != nil {
+		profile.Address = *req.Address
+	}
+}
+
+// nullTimePtr returns the time of t, nil if it's null.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// nextCursor returns the cursor of the page after the last user, with its value of the sorted column.
+func nextCursor(last domain.User, sortBy string, sort string) cursor.Cursor {
+	c := cursor.Cursor{ID: last.ID, Sort: sort}
+
+	switch sortBy {
+	case domain.SortByCreatedAt:
+		c.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case domain.SortByUsername:
+		c.Value = last.UserName
+	case domain.SortByEmail:
+		c.Value = last.Email
+	}
+
+	return c
+}
+
+func newProfileRespDTO(up *domain.Profile) *domain.ProfileRespDTO {
+	res := domain.ProfileRespDTO{
+		FirstName: up.FirstName,
+		LastName:  up.LastName,
+		Gender:    up.Gender,
+		CreatedAt: up.CreatedAt,
+		UpdatedAt: up.UpdatedAt,
+		Version:   up.Version,
+	}
+
+	if up.Address.Valid {
+		res.Address = up.Address.String
+	}
+
+	return &res
+}
+
+func newUserRespDTO(user *domain.User) *domain.UserRespDTO {
+	return &domain.UserRespDTO{
+		UserID:    user.UserID,
+		UserName:  user.UserName,
+		Email:     user.Email,
+		Status:    user.Status,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
 }
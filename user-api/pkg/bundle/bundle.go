@@ -0,0 +1,35 @@
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// File is a file of a bundle, Content is written as indented JSON.
+type File struct {
+	Name    string
+	Content any
+}
+
+// WriteZip writes the files as a zip archive to w, each modified at modTime.
+func WriteZip(w io.Writer, files []File, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+
+		if err = enc.Encode(f.Content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,50 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriteZip(t *testing.T) {
+	files := []File{
+		{Name: "user.json", Content: map[string]string{"userName": "alice"}},
+		{Name: "sessions.json", Content: []string{}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, files, time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	if len(zr.File) != len(files) {
+		t.Fatalf("archive has %d files, want %d", len(zr.File), len(files))
+	}
+
+	for i, zf := range zr.File {
+		if zf.Name != files[i].Name {
+			t.Errorf("file %d is %q, want %q", i, zf.Name, files[i].Name)
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+
+		want, _ := json.MarshalIndent(files[i].Content, "", "  ")
+		if got := bytes.TrimSpace(b); !bytes.Equal(got, want) {
+			t.Errorf("file %q = %s, want %s", zf.Name, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// Cursor is the position after the last item of a page in keyset pagination, the id of the item
+// and, unless sorted by id, its value of the sorted column. Sort binds the cursor to the order it was made for.
+type Cursor struct {
+	ID    int64  `json:"id"`
+	Value string `json:"v,omitempty"`
+	Sort  string `json:"s"`
+}
+
+// Encode returns the cursor as an opaque url safe string.
+func Encode(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode parses an encoded cursor, it must have been made for sort.
+func Decode(s string, sort string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(b, &c); err != nil || c.ID <= 0 || c.Sort != sort {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
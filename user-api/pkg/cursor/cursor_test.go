@@ -0,0 +1,37 @@
+package cursor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	valid := Encode(Cursor{ID: 42, Value: "2024-01-02T03:04:05Z", Sort: "createdAt"})
+
+	tests := []struct {
+		name    string
+		cursor  string
+		sort    string
+		want    Cursor
+		wantErr error
+	}{
+		{name: "Valid", cursor: valid, sort: "createdAt",
+			want: Cursor{ID: 42, Value: "2024-01-02T03:04:05Z", Sort: "createdAt"}},
+		{name: "Other_Sort", cursor: valid, sort: "-createdAt", wantErr: ErrInvalidCursor},
+		{name: "Not_Base64", cursor: "%%%", sort: "createdAt", wantErr: ErrInvalidCursor},
+		{name: "No_ID", cursor: Encode(Cursor{Sort: "id"}), sort: "id", wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.cursor, tt.sort)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
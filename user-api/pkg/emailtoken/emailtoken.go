@@ -0,0 +1,58 @@
+package emailtoken
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const purposeEmailVerification = "email_verification"
+
+var ErrInvalidToken = errors.New("verification token is invalid or has expired")
+
+// claims of a verification token, the email binds it to the address it was sent to,
+// so it can't verify an address the user changed to later.
+type claims struct {
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// Issue returns an HS256 signed token proving the owner of userID received mail at email, valid for ttl.
+func Issue(secret []byte, userID string, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Email:   email,
+		Purpose: purposeEmailVerification,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+
+	tokenStr, err := token.SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign verification token: %w", err)
+	}
+
+	return tokenStr, nil
+}
+
+// Parse validates a token issued by Issue and returns the user id and email it verifies,
+// tampered, expired or otherwise invalid tokens return ErrInvalidToken.
+func Parse(secret []byte, tokenStr string) (string, string, error) {
+	var c claims
+
+	_, err := jwt.ParseWithClaims(tokenStr, &c, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || c.ExpiresAt == nil || c.Purpose != purposeEmailVerification || c.Subject == "" || c.Email == "" {
+		return "", "", ErrInvalidToken
+	}
+
+	return c.Subject, c.Email, nil
+}
@@ -0,0 +1,53 @@
+package emailtoken
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	secret := []byte("test-secret")
+
+	valid, err := Issue(secret, "user-1", "john@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() unexpected error = %v", err)
+	}
+
+	expired, err := Issue(secret, "user-1", "john@example.com", -time.Minute)
+	if err != nil {
+		t.Fatalf("Issue() unexpected error = %v", err)
+	}
+
+	otherSecret, err := Issue([]byte("other-secret"), "user-1", "john@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantUser  string
+		wantEmail string
+		wantErr   error
+	}{
+		{name: "Valid", token: valid, wantUser: "user-1", wantEmail: "john@example.com"},
+		{name: "Expired", token: expired, wantErr: ErrInvalidToken},
+		{name: "Other_Secret", token: otherSecret, wantErr: ErrInvalidToken},
+		{name: "Tampered", token: valid + "x", wantErr: ErrInvalidToken},
+		{name: "Empty", token: "", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, email, err := Parse(secret, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if userID != tt.wantUser || email != tt.wantEmail {
+				t.Errorf("Parse() = %q, %q, want %q, %q", userID, email, tt.wantUser, tt.wantEmail)
+			}
+		})
+	}
+}
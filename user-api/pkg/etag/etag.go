@@ -0,0 +1,45 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Any is the version If-Match: * stands for, it matches whatever the current version is.
+const Any int64 = 0
+
+var (
+	ErrMissing = errors.New("if-match header is required")
+	ErrInvalid = errors.New("if-match header doesn't match any version")
+)
+
+// Format returns the strong entity tag of a resource version, e.g. "3".
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ParseIfMatch returns the version an If-Match header requires, Any for "*".
+// Weak tags never match under the strong comparison If-Match uses, so they are invalid like malformed ones.
+func ParseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+
+	switch {
+	case header == "":
+		return 0, ErrMissing
+	case header == "*":
+		return Any, nil
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil || !strings.HasPrefix(header, `"`) {
+		return 0, ErrInvalid
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalid
+	}
+
+	return version, nil
+}
@@ -0,0 +1,36 @@
+package etag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    int64
+		wantErr error
+	}{
+		{name: "Valid", header: Format(3), want: 3},
+		{name: "Any", header: "*", want: Any},
+		{name: "Missing", header: "", wantErr: ErrMissing},
+		{name: "Weak", header: `W/"3"`, wantErr: ErrInvalid},
+		{name: "Unquoted", header: "3", wantErr: ErrInvalid},
+		{name: "Not_A_Version", header: `"abc"`, wantErr: ErrInvalid},
+		{name: "Zero", header: `"0"`, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIfMatch(tt.header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseIfMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseIfMatch() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
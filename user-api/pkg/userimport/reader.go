@@ -0,0 +1,167 @@
+// Package userimport reads users to import from CSV or JSON Lines, one at a time, so inputs of any size
+// are streamed rather than loaded at once.
+package userimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+
+	// MaxLineSize limits the lines of JSON Lines inputs.
+	MaxLineSize = 64 * 1024
+)
+
+// Record is a user read from a line of the input, Err is set instead if the line couldn't be parsed.
+type Record struct {
+	Line int
+	User domain.NewUserReqDTO
+	Err  error
+}
+
+// Reader reads the users of an input, Next returns io.EOF once every line was read.
+// Lines that can't be parsed are returned as records with Err set, reading goes on after them,
+// errors returned by Next itself end the input.
+type Reader interface {
+	Next() (Record, error)
+}
+
+// NewReader returns a reader of the input in format, CSV inputs must start with a header naming their columns.
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	default:
+		return nil, fmt.Errorf("format must be one of: %s, %s", FormatCSV, FormatJSONL)
+	}
+}
+
+// csvColumns are the columns of CSV inputs by lowercase header, userName, password and email are required.
+var csvColumns = map[string]func(u *domain.NewUserReqDTO, v string){
+	"username": func(u *domain.NewUserReqDTO, v string) { u.UserName = v },
+	"password": func(u *domain.NewUserReqDTO, v string) { u.Password = v },
+	"email":    func(u *domain.NewUserReqDTO, v string) { u.Email = v },
+	"status":   func(u *domain.NewUserReqDTO, v string) { u.Status = v },
+	"role":     func(u *domain.NewUserReqDTO, v string) { u.Role = v },
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []func(u *domain.NewUserReqDTO, v string)
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv input is empty, it must start with a header")
+		}
+
+		return nil, fmt.Errorf("unable to read csv header: %w", err)
+	}
+
+	seen := make(map[string]bool, len(header))
+	columns := make([]func(u *domain.NewUserReqDTO, v string), len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+
+		set, ok := csvColumns[name]
+		if !ok || seen[name] {
+			return nil, fmt.Errorf("csv header has an unknown or repeated column %q", header[i])
+		}
+
+		seen[name] = true
+		columns[i] = set
+	}
+
+	if !seen["username"] || !seen["password"] || !seen["email"] {
+		return nil, errors.New("csv header must have the columns userName, password and email")
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) Next() (Record, error) {
+	fields, err := r.r.Read()
+
+	var parseErr *csv.ParseError
+
+	switch {
+	case errors.As(err, &parseErr):
+		return Record{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	case err != nil:
+		return Record{}, err
+	}
+
+	var rec Record
+	rec.Line, _ = r.r.FieldPos(0)
+
+	for i, v := range fields {
+		r.columns[i](&rec.User, strings.TrimSpace(v))
+	}
+
+	return rec, nil
+}
+
+type jsonlReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxLineSize)
+
+	return &jsonlReader{s: s}
+}
+
+func (r *jsonlReader) Next() (Record, error) {
+	for r.s.Scan() {
+		r.line++
+
+		b := bytes.TrimSpace(r.s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		rec := Record{Line: r.line}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&rec.User); err != nil {
+			rec.Err = fmt.Errorf("invalid json: %w", err)
+		} else if dec.More() {
+			rec.Err = errors.New("invalid json: a line must hold a single object")
+		}
+
+		return rec, nil
+	}
+
+	if err := r.s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return Record{}, fmt.Errorf("line %d exceeds %d bytes", r.line+1, MaxLineSize)
+		}
+
+		return Record{}, err
+	}
+
+	return Record{}, io.EOF
+}
@@ -0,0 +1,116 @@
+package userimport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
+)
+
+func TestReader(t *testing.T) {
+	alice := domain.NewUserReqDTO{UserName: "alice01", Password: "p4ssw0rd!", Email: "alice@test.com"}
+	bob := domain.NewUserReqDTO{UserName: "bob0001", Password: "s3cret!!", Email: "bob@test.com", Role: "merchant"}
+
+	tests := []struct {
+		name      string
+		format    string
+		input     string
+		want      []Record
+		wantErrs  []int
+		newErrMsg string
+	}{
+		{name: "CSV", format: FormatCSV,
+			input: "\ufeffEmail,userName,password,role\nalice@test.com,alice01,p4ssw0rd!,\n" +
+				"bob@test.com, bob0001 ,s3cret!!,merchant\n",
+			want: []Record{{Line: 2, User: alice}, {Line: 3, User: bob}}},
+		{name: "CSV_Bad_Lines", format: FormatCSV,
+			input: "username,password,email\nalice01,p4ssw0rd!\nbob\"0001,s3cret!!,bob@test.com\n" +
+				"alice01,p4ssw0rd!,alice@test.com\n",
+			want: []Record{{Line: 2}, {Line: 3}, {Line: 4, User: alice}}, wantErrs: []int{2, 3}},
+		{name: "CSV_Missing_Column", format: FormatCSV, input: "username,email\n",
+			newErrMsg: "csv header must have the columns userName, password and email"},
+		{name: "CSV_Unknown_Column", format: FormatCSV, input: "username,password,email,phone\n",
+			newErrMsg: `csv header has an unknown or repeated column "phone"`},
+		{name: "CSV_Empty", format: FormatCSV, newErrMsg: "csv input is empty, it must start with a header"},
+		{name: "JSONL", format: FormatJSONL,
+			input: `{"userName":"alice01","password":"p4ssw0rd!","email":"alice@test.com"}` + "\n\n" +
+				`{"userName":"bob0001","password":"s3cret!!","email":"bob@test.com","role":"merchant"}`,
+			want: []Record{{Line: 1, User: alice}, {Line: 3, User: bob}}},
+		{name: "JSONL_Bad_Lines", format: FormatJSONL,
+			input:    `{"userName":"alice01",` + "\n" + `{"userName":"bob0001","phone":"1"}` + "\n" + `{} {}`,
+			want:     []Record{{Line: 1}, {Line: 2, User: domain.NewUserReqDTO{UserName: "bob0001"}}, {Line: 3}},
+			wantErrs: []int{1, 2, 3}},
+		{name: "Unknown_Format", format: "xml", newErrMsg: "format must be one of: csv, jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.input), tt.format)
+			if tt.newErrMsg != "" {
+				if err == nil || err.Error() != tt.newErrMsg {
+					t.Fatalf("NewReader() error = %v, want %q", err, tt.newErrMsg)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+
+			var got []Record
+			var gotErrs []int
+
+			for {
+				rec, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+
+				if rec.Err != nil {
+					gotErrs = append(gotErrs, rec.Line)
+					rec.Err = nil
+				}
+
+				got = append(got, rec)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("record %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+
+			if len(gotErrs) != len(tt.wantErrs) {
+				t.Fatalf("lines with errors = %v, want %v", gotErrs, tt.wantErrs)
+			}
+
+			for i := range gotErrs {
+				if gotErrs[i] != tt.wantErrs[i] {
+					t.Errorf("lines with errors = %v, want %v", gotErrs, tt.wantErrs)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderLineTooLong(t *testing.T) {
+	r, err := NewReader(strings.NewReader(strings.Repeat("a", MaxLineSize+1)), FormatJSONL)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+
+	if _, err = r.Next(); err == nil || err.Error() != "line 1 exceeds 65536 bytes" {
+		t.Errorf("Next() error = %v, want line 1 exceeds 65536 bytes", err)
+	}
+}
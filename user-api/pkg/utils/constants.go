@@ -4,20 +4,40 @@ import "time"
 
 const (
 	UUIDRegex   = `^[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[1-5][a-fA-F0-9]{3}-[89abAB][a-fA-F0-9]{3}-[a-fA-F0-9]{12}$`
-	StatusRegex = `^(active|inactive|deleted)$`
+	StatusRegex = `^(active|inactive|deleted|unverified)$`
 	RoleRegex   = `^(user|admin|moderator|merchant)$`
 
 	DefaultPageSize = 20
+	MaxPageSize     = 100
+	EmbedProfile    = "profile"
 
-	UserStatusActive   = "active"
-	UserStatusInactive = "inactive"
-	UserStatusDeleted  = "deleted"
+	UserStatusActive     = "active"
+	UserStatusInactive   = "inactive"
+	UserStatusDeleted    = "deleted"
+	UserStatusUnverified = "unverified"
 
 	UserRoleAdmin     = "admin"
 	UserRoleUser      = "user"
 	UserRoleModerator = "moderator"
 	UserRoleMerchant  = "merchant"
 
+	EmailVerificationTokenDuration = 24 * time.Hour
+	ResendVerificationPerEmail     = 3
+	ResendVerificationPerIP        = 10
+	ResendVerificationWindow       = time.Hour
+
 	TimeoutCreateUser        = 200 * time.Millisecond
 	TimeoutCreateUserProfile = 200 * time.Millisecond
+	TimeoutFindUsers         = 500 * time.Millisecond
+	TimeoutUser              = 200 * time.Millisecond
+	TimeoutExport            = 2 * time.Second
+
+	ExportFormatJSON = "json"
+	ExportFormatZip  = "zip"
+	MaxErasureReason = 512
+
+	// users of a bulk import are inserted ImportChunkSize at a time, a transaction each
+	ImportChunkSize = 500
+	MaxImportSize   = 64 << 20
+	MaxImportLines  = 100_000
 )
@@ -2,7 +2,10 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/ashtishad/instabid-wallet/lib"
 	"github.com/ashtishad/instabid-wallet/user-api/internal/domain"
@@ -10,9 +13,9 @@ import (
 
 // ValidateCreateUserInput validates the input dto for creating a new user with the following criteria:
 //   - Email: Must match the specified regex pattern (EmailRegex).
-//   - Password: Must be at least 8 characters long and no more than 32 characters.
+//   - Password: Must be at least 8 characters long and no more than 128 characters.
 //   - Username: Must be 7-64 alphanumeric characters with no spaces.
-//   - Status: If provided, must be one of 'active', 'inactive', or 'deleted'.
+//   - Status: If provided, must be one of 'unverified' or 'inactive', new users verify their email to become active.
 //   - Role: If provided, must be one of 'user', 'admin', 'moderator', or 'merchant'.
 func ValidateCreateUserInput(input domain.NewUserReqDTO) lib.APIError {
 	var errs error
@@ -30,7 +33,7 @@ func ValidateCreateUserInput(input domain.NewUserReqDTO) lib.APIError {
 		errs = errors.Join(errs, err)
 	}
 
-	if err = validateStatus(input.Status); err != nil {
+	if err = validateNewUserStatus(input.Status); err != nil {
 		errs = errors.Join(errs, err)
 	}
 
@@ -45,6 +48,41 @@ func ValidateCreateUserInput(input domain.NewUserReqDTO) lib.APIError {
 	return nil
 }
 
+// ValidateUpdateUserInput validates the input dto for updating a user with the following criteria:
+//   - Username, Email: Required if replace is set, otherwise at least one field must be provided.
+//   - Email: If provided, must match the specified regex pattern (EmailRegex).
+//   - Username: If provided, must be 7-64 alphanumeric characters with no spaces.
+func ValidateUpdateUserInput(input domain.UpdateUserReqDTO, replace bool) lib.APIError {
+	if replace && (input.UserName == "" || input.Email == "") {
+		return lib.BadRequestError("userName and email are required, use PATCH to update some of them")
+	}
+
+	if input.UserName == "" && input.Email == "" {
+		return lib.BadRequestError("nothing to update, provide at least one of: userName, email")
+	}
+
+	var errs error
+	var err error
+
+	if input.Email != "" {
+		if err = lib.ValidateEmail(input.Email); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	if input.UserName != "" {
+		if err = lib.ValidateUserName(input.UserName); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	if errs != nil {
+		return lib.BadRequestError(errs.Error())
+	}
+
+	return nil
+}
+
 // ValidateCreateProfileInput validates the input dto for creating a new profile with the following criteria:
 //   - FirstName: Must be alphabetic and between 1 and 64 characters long.
 //   - LastName: Must be alphabetic, may contain spaces, and be between 1 and 128 characters long.
@@ -77,10 +115,120 @@ func ValidateCreateProfileInput(input domain.NewProfileReqDTO) lib.APIError {
 	return nil
 }
 
-// validateStatus checks status must be one of: active, inactive, deleted
+// ValidateFindUsersInput validates the query of listing users with the following criteria:
+//   - Limit: If provided, must be between 1 and MaxPageSize.
+//   - Status, Role: If provided, must be a known status or role.
+//   - CreatedAfter, CreatedBefore: If provided, must be RFC 3339 timestamps.
+//   - UsernamePrefix, EmailPrefix: If provided, at most 64 and 128 characters, the longest username and email.
+//   - Sort: If provided, one of id, createdAt, username or email, optionally prefixed with "-".
+//   - Embed: If provided, must be 'profile'.
+func ValidateFindUsersInput(input domain.FindUsersReqDTO) lib.APIError {
+	var errs error
+	var err error
+
+	if input.Limit < 0 || input.Limit > MaxPageSize {
+		errs = errors.Join(errs, fmt.Errorf("limit must be between 1 and %d", MaxPageSize))
+	}
+
+	if err = validateStatus(input.Status); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if err = validateRole(input.Role); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if _, err = time.Parse(time.RFC3339, input.CreatedAfter); err != nil && input.CreatedAfter != "" {
+		errs = errors.Join(errs, errors.New("createdAfter must be an RFC 3339 timestamp"))
+	}
+
+	if _, err = time.Parse(time.RFC3339, input.CreatedBefore); err != nil && input.CreatedBefore != "" {
+		errs = errors.Join(errs, errors.New("createdBefore must be an RFC 3339 timestamp"))
+	}
+
+	if len(input.UsernamePrefix) > 64 || len(input.EmailPrefix) > 128 {
+		errs = errors.Join(errs, errors.New("username prefix can't exceed 64, email prefix 128 characters"))
+	}
+
+	if _, ok := ParseSort(input.Sort); !ok {
+		errs = errors.Join(errs, errors.New("sort must be one of: id, createdAt, username, email, "+
+			"optionally prefixed with -"))
+	}
+
+	if input.Embed != "" && input.Embed != EmbedProfile {
+		errs = errors.Join(errs, errors.New("embed must be: profile"))
+	}
+
+	if errs != nil {
+		return lib.BadRequestError(errs.Error())
+	}
+
+	return nil
+}
+
+// ParseSort returns the column of a sort parameter and whether it's descending, "-createdAt" sorts
+// by creation time descending, an empty sort by id ascending. It returns false for unknown columns.
+func ParseSort(sort string) (domain.FindUsersOptions, bool) {
+	opts := domain.FindUsersOptions{SortBy: domain.SortByID}
+
+	if sort == "" {
+		return opts, true
+	}
+
+	column, desc := strings.CutPrefix(sort, "-")
+
+	switch column {
+	case domain.SortByID, domain.SortByCreatedAt, domain.SortByUsername, domain.SortByEmail:
+		opts.SortBy = column
+		opts.Desc = desc
+
+		return opts, true
+	default:
+		return opts, false
+	}
+}
+
+// ValidateUUID checks a user id from the path is a uuid, so malformed ids are rejected before querying.
+func ValidateUUID(uuid string) lib.APIError {
+	if !regexp.MustCompile(UUIDRegex).MatchString(uuid) {
+		return lib.BadRequestError("user id must be a valid uuid")
+	}
+
+	return nil
+}
+
+// ValidateExportFormat checks the format of a data export is json, the default, or zip.
+func ValidateExportFormat(format string) lib.APIError {
+	if format != "" && format != ExportFormatJSON && format != ExportFormatZip {
+		return lib.BadRequestError(fmt.Sprintf("format must be one of: %s, %s", ExportFormatJSON, ExportFormatZip))
+	}
+
+	return nil
+}
+
+// ValidateErasureInput checks the reason of an erasure request doesn't exceed MaxErasureReason characters.
+func ValidateErasureInput(input domain.ErasureReqDTO) lib.APIError {
+	if len(input.Reason) > MaxErasureReason {
+		return lib.BadRequestError(fmt.Sprintf("reason can't exceed %d characters", MaxErasureReason))
+	}
+
+	return nil
+}
+
+// validateStatus checks status must be one of: active, inactive, deleted, unverified
 func validateStatus(status string) error {
 	if matched := regexp.MustCompile(StatusRegex).MatchString(status); !matched && status != "" {
-		return errors.New("status must be one of: active, inactive, deleted")
+		return errors.New("status must be one of: active, inactive, deleted, unverified")
+	}
+
+	return nil
+}
+
+// validateNewUserStatus allows new users to start unverified or inactive only,
+// they become active by verifying their email or through the admin-api.
+func validateNewUserStatus(status string) error {
+	if status != "" && status != UserStatusUnverified && status != UserStatusInactive {
+		return errors.New("status of a new user must be one of: unverified, inactive")
 	}
 
 	return nil
@@ -21,7 +21,7 @@ func TestValidateCreateUserInput(t *testing.T) {
 				UserName: "testUser",
 				Password: "password123",
 				Email:    "email@test.com",
-				Status:   "active",
+				Status:   "unverified",
 				Role:     "user",
 			},
 			wantErr: false,
@@ -32,7 +32,7 @@ func TestValidateCreateUserInput(t *testing.T) {
 				UserName: "testUser",
 				Password: "password123",
 				Email:    "invalid-email",
-				Status:   "active",
+				Status:   "unverified",
 				Role:     "user",
 			},
 			wantErr: true,
@@ -44,7 +44,7 @@ func TestValidateCreateUserInput(t *testing.T) {
 				UserName: "testUsr@",
 				Password: "password123",
 				Email:    "email@test.com",
-				Status:   "active",
+				Status:   "unverified",
 				Role:     "user",
 			},
 			wantErr: true,
@@ -56,11 +56,11 @@ func TestValidateCreateUserInput(t *testing.T) {
 				UserName: "testUser",
 				Password: "short",
 				Email:    "email@test.com",
-				Status:   "active",
+				Status:   "unverified",
 				Role:     "user",
 			},
 			wantErr: true,
-			errText: "password must be at least 8 characters long and no more than 32 characters",
+			errText: "password must be at least 8 characters long and no more than 128 characters",
 		},
 		{
 			name: "Invalid status",
@@ -72,15 +72,27 @@ func TestValidateCreateUserInput(t *testing.T) {
 				Role:     "user",
 			},
 			wantErr: true,
-			errText: "status must be one of: active, inactive, deleted",
+			errText: "status of a new user must be one of: unverified, inactive",
 		},
 		{
-			name: "Invalid role",
+			name: "Active status",
 			input: domain.NewUserReqDTO{
 				UserName: "testUser",
 				Password: "password123",
 				Email:    "email@test.com",
 				Status:   "active",
+				Role:     "user",
+			},
+			wantErr: true,
+			errText: "status of a new user must be one of: unverified, inactive",
+		},
+		{
+			name: "Invalid role",
+			input: domain.NewUserReqDTO{
+				UserName: "testUser",
+				Password: "password123",
+				Email:    "email@test.com",
+				Status:   "unverified",
 				Role:     "alien",
 			},
 			wantErr: true,
@@ -96,7 +108,7 @@ func TestValidateCreateUserInput(t *testing.T) {
 				Role:     "alien",
 			},
 			wantErr: true,
-			errText: "invalid email, you entered invalid-email\npassword must be at least 8 characters long and no more than 32 characters\ninvalid username: must be 7-64 alphanumeric characters with no spaces\nstatus must be one of: active, inactive, deleted\nrole must be one of: user, admin, moderator, merchant",
+			errText: "invalid email, you entered invalid-email\npassword must be at least 8 characters long and no more than 128 characters\ninvalid username: must be 7-64 alphanumeric characters with no spaces\nstatus of a new user must be one of: unverified, inactive\nrole must be one of: user, admin, moderator, merchant",
 		},
 	}
 
@@ -115,6 +127,62 @@ func TestValidateCreateUserInput(t *testing.T) {
 	}
 }
 
+// TestValidateUpdateUserInput tests ValidateUpdateUserInput function.
+func TestValidateUpdateUserInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   domain.UpdateUserReqDTO
+		replace bool
+		wantErr bool
+		errText string
+	}{
+		{
+			name:    "Valid replace",
+			input:   domain.UpdateUserReqDTO{UserName: "testUser", Email: "email@test.com"},
+			replace: true,
+			wantErr: false,
+		},
+		{
+			name:    "Valid partial",
+			input:   domain.UpdateUserReqDTO{Email: "email@test.com"},
+			wantErr: false,
+		},
+		{
+			name:    "Replace missing email",
+			input:   domain.UpdateUserReqDTO{UserName: "testUser"},
+			replace: true,
+			wantErr: true,
+			errText: "userName and email are required, use PATCH to update some of them",
+		},
+		{
+			name:    "Nothing to update",
+			input:   domain.UpdateUserReqDTO{},
+			wantErr: true,
+			errText: "nothing to update, provide at least one of: userName, email",
+		},
+		{
+			name:    "Multiple invalid fields",
+			input:   domain.UpdateUserReqDTO{UserName: "a", Email: "invalid-email"},
+			wantErr: true,
+			errText: "invalid email, you entered invalid-email\ninvalid username: must be 7-64 alphanumeric characters with no spaces",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := ValidateUpdateUserInput(tt.input, tt.replace)
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdateUserInput() error = %v, wantErr %v", gotErr, tt.wantErr)
+				return
+			}
+
+			if gotErr != nil && gotErr.Error() != tt.errText {
+				t.Errorf("ValidateUpdateUserInput() got error text = %v, want %v", gotErr.Error(), tt.errText)
+			}
+		})
+	}
+}
+
 // TestValidateCreateProfileInput tests ValidateCreateProfileInput function.
 func TestValidateCreateProfileInput(t *testing.T) {
 	tests := []struct {
@@ -210,3 +278,89 @@ func TestValidateCreateProfileInput(t *testing.T) {
 		})
 	}
 }
+
+// TestValidateFindUsersInput tests ValidateFindUsersInput function.
+func TestValidateFindUsersInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   domain.FindUsersReqDTO
+		wantErr bool
+		errText string
+	}{
+		{
+			name:    "Empty input",
+			input:   domain.FindUsersReqDTO{},
+			wantErr: false,
+		},
+		{
+			name: "Valid input",
+			input: domain.FindUsersReqDTO{
+				Limit:          50,
+				Status:         "active",
+				Role:           "merchant",
+				CreatedAfter:   "2023-01-01T00:00:00Z",
+				CreatedBefore:  "2023-12-31T23:59:59+06:00",
+				UsernamePrefix: "ash",
+				Sort:           "-createdAt",
+				Embed:          "profile",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Limit too large",
+			input:   domain.FindUsersReqDTO{Limit: 101},
+			wantErr: true,
+			errText: "limit must be between 1 and 100",
+		},
+		{
+			name:    "Unknown sort",
+			input:   domain.FindUsersReqDTO{Sort: "-password"},
+			wantErr: true,
+			errText: "sort must be one of: id, createdAt, username, email, optionally prefixed with -",
+		},
+		{
+			name: "Multiple invalid fields",
+			input: domain.FindUsersReqDTO{
+				Status:       "unknown",
+				CreatedAfter: "2023-01-01",
+				Embed:        "wallet",
+			},
+			wantErr: true,
+			errText: "status must be one of: active, inactive, deleted, unverified\ncreatedAfter must be an RFC 3339 timestamp\nembed must be: profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := ValidateFindUsersInput(tt.input)
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("ValidateFindUsersInput() error = %v, wantErr %v", gotErr, tt.wantErr)
+				return
+			}
+
+			if gotErr != nil && gotErr.Error() != tt.errText {
+				t.Errorf("ValidateFindUsersInput() got error text = %v, want %v", gotErr.Error(), tt.errText)
+			}
+		})
+	}
+}
+
+func TestValidateExportFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: "", wantErr: false},
+		{format: "json", wantErr: false},
+		{format: "zip", wantErr: false},
+		{format: "csv", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if gotErr := ValidateExportFormat(tt.format); (gotErr != nil) != tt.wantErr {
+				t.Errorf("ValidateExportFormat(%q) error = %v, wantErr %v", tt.format, gotErr, tt.wantErr)
+			}
+		})
+	}
+}